@@ -0,0 +1,42 @@
+// Package config loads server configuration, such as rest.Config and
+// grpc.Config, from struct tag defaults, files and the environment.
+package config
+
+import (
+	"github.com/kelseyhightower/envconfig"
+)
+
+// PrefixDefaulter is implemented by configs that derive defaults from the
+// prefix they were loaded with, e.g. a metrics namespace that defaults to the
+// prefix.
+type PrefixDefaulter interface {
+	SetPrefixDefaults(prefix string)
+}
+
+// LoadConfig loads a T from the environment variables under prefix, using
+// the envconfig struct tags of T.
+func LoadConfig[T any](prefix string) (T, error) {
+	var c T
+	if err := envconfig.Process(prefix, &c); err != nil {
+		return c, err
+	}
+
+	if d, ok := any(&c).(PrefixDefaulter); ok {
+		d.SetPrefixDefaults(prefix)
+	}
+
+	return c, nil
+}
+
+// LoadConfigFromDotenv is like LoadConfig, but first loads the .env file at
+// path into the environment. Variables already set in the environment take
+// precedence over the file, and a missing file is ignored, so the same code
+// runs locally and in deployments configured by their environment.
+func LoadConfigFromDotenv[T any](prefix, path string) (T, error) {
+	if err := LoadDotenv(path); err != nil {
+		var c T
+		return c, err
+	}
+
+	return LoadConfig[T](prefix)
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/stretchr/testify/assert"
+)
+
+type testConfig struct {
+	Host      string        `default:"0.0.0.0:3000"`
+	Timeout   time.Duration `default:"5s"`
+	Build     string        `default:"dev"`
+	Namespace string
+}
+
+func (c *testConfig) SetPrefixDefaults(prefix string) {
+	if c.Namespace == "" {
+		c.Namespace = prefix
+	}
+}
+
+func TestLoadConfigFromDotenv(t *testing.T) {
+	// We cannot run this in parallel because it modifies environment variables
+	// t.Parallel()
+
+	tests := map[string]struct {
+		prefix  string
+		dotenv  string
+		env     map[string]string
+		want    testConfig
+		wantErr bool
+	}{
+		"file sets values": {
+			prefix: "test_dotenv_file",
+			dotenv: "TEST_DOTENV_FILE_HOST=127.0.0.1:8080\nTEST_DOTENV_FILE_TIMEOUT=10s\n",
+			want: testConfig{
+				Host:      "127.0.0.1:8080",
+				Timeout:   10 * time.Second,
+				Build:     "dev",
+				Namespace: "test_dotenv_file",
+			},
+		},
+		"environment takes precedence over file": {
+			prefix: "test_dotenv_env",
+			dotenv: "TEST_DOTENV_ENV_HOST=127.0.0.1:8080\nTEST_DOTENV_ENV_BUILD=local\n",
+			env: map[string]string{
+				"TEST_DOTENV_ENV_BUILD": "prod",
+			},
+			want: testConfig{
+				Host:      "127.0.0.1:8080",
+				Timeout:   5 * time.Second,
+				Build:     "prod",
+				Namespace: "test_dotenv_env",
+			},
+		},
+		"missing file uses environment and defaults": {
+			prefix: "test_dotenv_missing",
+			env: map[string]string{
+				"TEST_DOTENV_MISSING_NAMESPACE": "custom",
+			},
+			want: testConfig{
+				Host:      "0.0.0.0:3000",
+				Timeout:   5 * time.Second,
+				Build:     "dev",
+				Namespace: "custom",
+			},
+		},
+		"invalid value in file": {
+			prefix:  "test_dotenv_invalid",
+			dotenv:  "TEST_DOTENV_INVALID_TIMEOUT=soon\n",
+			wantErr: true,
+		},
+		"malformed file": {
+			prefix:  "test_dotenv_malformed",
+			dotenv:  "TEST_DOTENV_MALFORMED_HOST\n",
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			path := filepath.Join(t.TempDir(), ".env")
+			if tt.dotenv != "" {
+				assert.NoError(t, os.WriteFile(path, []byte(tt.dotenv), 0o600))
+			}
+
+			// Restore whatever the file sets once the test is done
+			vars, _ := parseDotenvString(tt.dotenv)
+			for _, v := range vars {
+				if _, ok := tt.env[v.key]; !ok {
+					t.Setenv(v.key, "")
+					os.Unsetenv(v.key)
+				}
+			}
+
+			got, err := LoadConfigFromDotenv[testConfig](tt.prefix, path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// envconfigTestConfig uses envconfig features the Load sources don't support.
+type envconfigTestConfig struct {
+	APIKey      string `required:"true"`
+	ReadTimeout string `split_words:"true"`
+	Region      string `envconfig:"REGION"`
+}
+
+func TestLoadConfigEnvconfig(t *testing.T) {
+	// We cannot run this in parallel because it modifies environment variables
+	// t.Parallel()
+
+	tests := map[string]struct {
+		prefix  string
+		env     map[string]string
+		want    envconfigTestConfig
+		wantErr string
+	}{
+		"split words and unprefixed fallback": {
+			prefix: "test_envconfig_ok",
+			env: map[string]string{
+				"TEST_ENVCONFIG_OK_APIKEY":       "secret",
+				"TEST_ENVCONFIG_OK_READ_TIMEOUT": "5s",
+				"REGION":                         "us-east",
+			},
+			want: envconfigTestConfig{
+				APIKey:      "secret",
+				ReadTimeout: "5s",
+				Region:      "us-east",
+			},
+		},
+		"required field missing": {
+			prefix:  "test_envconfig_required",
+			wantErr: "required key TEST_ENVCONFIG_REQUIRED_APIKEY missing value",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := LoadConfig[envconfigTestConfig](tt.prefix)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadConfigParseError(t *testing.T) {
+	t.Setenv("TEST_ENVCONFIG_PARSE_TIMEOUT", "soon")
+
+	_, err := LoadConfig[testConfig]("test_envconfig_parse")
+
+	var parseErr *envconfig.ParseError
+	if assert.ErrorAs(t, err, &parseErr) {
+		assert.Equal(t, "Timeout", parseErr.FieldName)
+	}
+}
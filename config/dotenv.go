@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// LoadDotenv sets the variables in the .env file at path that aren't already
+// set in the environment. A missing file is not an error.
+//
+// Each line holds KEY=value, optionally preceded by "export". Blank lines and
+// lines starting with # are skipped, and values may be wrapped in single or
+// double quotes.
+func LoadDotenv(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open dotenv file: %w", err)
+	}
+	defer f.Close()
+
+	vars, err := parseDotenv(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, v := range vars {
+		if _, ok := os.LookupEnv(v.key); ok {
+			continue
+		}
+		if err := os.Setenv(v.key, v.value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", v.key, err)
+		}
+	}
+
+	return nil
+}
+
+type dotenvVar struct {
+	key   string
+	value string
+}
+
+// parseDotenv parses the variables of a .env file in file order.
+func parseDotenv(r io.Reader) ([]dotenvVar, error) {
+	var vars []dotenvVar
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=value", n)
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		vars = append(vars, dotenvVar{key: key, value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dotenv file: %w", err)
+	}
+
+	return vars, nil
+}
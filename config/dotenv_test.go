@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// parseDotenvString parses the .env file contents s.
+func parseDotenvString(s string) ([]dotenvVar, error) {
+	return parseDotenv(strings.NewReader(s))
+}
+
+func TestParseDotenv(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input   string
+		want    []dotenvVar
+		wantErr string
+	}{
+		"plain values": {
+			input: "A=1\nB=two words\n",
+			want:  []dotenvVar{{"A", "1"}, {"B", "two words"}},
+		},
+		"comments and blank lines": {
+			input: "# local settings\n\nA=1\n  # indented comment\n",
+			want:  []dotenvVar{{"A", "1"}},
+		},
+		"export prefix": {
+			input: "export A=1\n",
+			want:  []dotenvVar{{"A", "1"}},
+		},
+		"quoted values": {
+			input: "A=\"double quoted\"\nB='single quoted'\nC=\"unbalanced'\n",
+			want:  []dotenvVar{{"A", "double quoted"}, {"B", "single quoted"}, {"C", "\"unbalanced'"}},
+		},
+		"whitespace around key and value": {
+			input: " A = 1 \n",
+			want:  []dotenvVar{{"A", "1"}},
+		},
+		"empty value": {
+			input: "A=\n",
+			want:  []dotenvVar{{"A", ""}},
+		},
+		"value containing equals": {
+			input: "DSN=user=app dbname=orders\n",
+			want:  []dotenvVar{{"DSN", "user=app dbname=orders"}},
+		},
+		"missing equals": {
+			input:   "A=1\nB\n",
+			wantErr: "line 2: expected KEY=value",
+		},
+		"missing key": {
+			input:   "=1\n",
+			wantErr: "line 1: expected KEY=value",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := parseDotenvString(tt.input)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source sets the config fields it has values for, leaving the others as
+// earlier sources set them. Sources see every exported field not tagged
+// ignored:"true", keyed by its envconfig tag or name. Unlike LoadConfig, they
+// don't support the required and split_words tags, the unprefixed key
+// fallback, or envconfig.Decoder and encoding.TextUnmarshaler fields.
+type Source interface {
+	Apply(cfg any) error
+}
+
+// SourceFunc adapts a function to a Source, e.g. to load flags.
+type SourceFunc func(cfg any) error
+
+// Apply implements Source.
+func (f SourceFunc) Apply(cfg any) error {
+	return f(cfg)
+}
+
+// Load builds a T by applying sources in order, so later sources override
+// earlier ones. If *T implements PrefixDefaulter, it is called with the
+// prefix of the last EnvSource or FileSource once every source is applied.
+func Load[T any](sources ...Source) (T, error) {
+	var c T
+	prefix := ""
+	for _, source := range sources {
+		if err := source.Apply(&c); err != nil {
+			return c, err
+		}
+		if p, ok := source.(prefixed); ok {
+			prefix = p.prefix()
+		}
+	}
+
+	if d, ok := any(&c).(PrefixDefaulter); ok {
+		d.SetPrefixDefaults(prefix)
+	}
+
+	return c, nil
+}
+
+// prefixed is implemented by sources whose keys carry a prefix.
+type prefixed interface {
+	prefix() string
+}
+
+// lookupSource sets fields from a key lookup.
+type lookupSource struct {
+	keyPrefix string
+	lookup    func(key string) (string, bool)
+}
+
+func (s lookupSource) prefix() string {
+	return s.keyPrefix
+}
+
+// Apply implements Source.
+func (s lookupSource) Apply(cfg any) error {
+	return eachField(cfg, func(name string, field reflect.StructField, v reflect.Value) error {
+		key := strings.ToUpper(name)
+		if s.keyPrefix != "" {
+			key = strings.ToUpper(s.keyPrefix + "_" + name)
+		}
+
+		value, ok := s.lookup(key)
+		if !ok {
+			return nil
+		}
+		if err := setField(v, value); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		return nil
+	})
+}
+
+// EnvSource sets fields from the environment variables under prefix, e.g.
+// APP_APIHOST for the APIHost field with the prefix "app".
+func EnvSource(prefix string) Source {
+	return lookupSource{keyPrefix: prefix, lookup: os.LookupEnv}
+}
+
+// FileSource sets fields from the .env format file at path, keyed like
+// EnvSource. Unlike LoadDotenv, it leaves the environment untouched and a
+// missing file is an error.
+func FileSource(prefix, path string) Source {
+	return fileSource{keyPrefix: prefix, path: path}
+}
+
+type fileSource struct {
+	keyPrefix string
+	path      string
+}
+
+func (s fileSource) prefix() string {
+	return s.keyPrefix
+}
+
+// Apply implements Source.
+func (s fileSource) Apply(cfg any) error {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	vars, err := parseDotenv(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+
+	values := make(map[string]string, len(vars))
+	for _, v := range vars {
+		values[v.key] = v.value
+	}
+
+	return lookupSource{keyPrefix: s.keyPrefix, lookup: func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}}.Apply(cfg)
+}
+
+// DefaultsSource sets every field that has a default tag to its default.
+func DefaultsSource() Source {
+	return SourceFunc(func(cfg any) error {
+		return eachField(cfg, func(name string, field reflect.StructField, v reflect.Value) error {
+			def, ok := field.Tag.Lookup("default")
+			if !ok {
+				return nil
+			}
+			if err := setField(v, def); err != nil {
+				return fmt.Errorf("default for %s: %w", field.Name, err)
+			}
+			return nil
+		})
+	})
+}
+
+// eachField calls fn with the key name, type and value of every settable
+// field of the struct cfg points to.
+func eachField(cfg any, fn func(name string, field reflect.StructField, v reflect.Value) error) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("config must be a pointer to a struct")
+	}
+	v = v.Elem()
+
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() || field.Tag.Get("ignored") == "true" {
+			continue
+		}
+
+		name := field.Name
+		if tag := field.Tag.Get("envconfig"); tag != "" {
+			name = tag
+		}
+		if err := fn(name, field, v.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setField parses value into v the way envconfig does: slices are comma
+// separated, and maps are comma separated key:value pairs.
+func setField(v reflect.Value, value string) error {
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 0, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		slice := reflect.MakeSlice(v.Type(), 0, 0)
+		if strings.TrimSpace(value) != "" {
+			for _, item := range strings.Split(value, ",") {
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := setField(elem, item); err != nil {
+					return err
+				}
+				slice = reflect.Append(slice, elem)
+			}
+		}
+		v.Set(slice)
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		if strings.TrimSpace(value) != "" {
+			for _, pair := range strings.Split(value, ",") {
+				k, val, ok := strings.Cut(pair, ":")
+				if !ok {
+					return fmt.Errorf("invalid map item: %q", pair)
+				}
+				key := reflect.New(v.Type().Key()).Elem()
+				if err := setField(key, k); err != nil {
+					return err
+				}
+				elem := reflect.New(v.Type().Elem()).Elem()
+				if err := setField(elem, val); err != nil {
+					return err
+				}
+				m.SetMapIndex(key, elem)
+			}
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+
+	return nil
+}
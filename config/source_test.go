@@ -0,0 +1,180 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	// We cannot run this in parallel because it modifies environment variables
+	// t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "app.env")
+	file := "TEST_LOAD_HOST=file:3000\nTEST_LOAD_TIMEOUT=10s\nTEST_LOAD_BUILD=file\n"
+	assert.NoError(t, os.WriteFile(path, []byte(file), 0o600))
+
+	tests := map[string]struct {
+		sources []Source
+		env     map[string]string
+		want    testConfig
+	}{
+		"defaults only": {
+			sources: []Source{DefaultsSource()},
+			want: testConfig{
+				Host:    "0.0.0.0:3000",
+				Timeout: 5 * time.Second,
+				Build:   "dev",
+			},
+		},
+		"file overrides defaults": {
+			sources: []Source{DefaultsSource(), FileSource("test_load", path)},
+			want: testConfig{
+				Host:      "file:3000",
+				Timeout:   10 * time.Second,
+				Build:     "file",
+				Namespace: "test_load",
+			},
+		},
+		"env overrides file overrides defaults": {
+			sources: []Source{DefaultsSource(), FileSource("test_load", path), EnvSource("test_load")},
+			env: map[string]string{
+				"TEST_LOAD_BUILD": "env",
+			},
+			want: testConfig{
+				Host:      "file:3000",
+				Timeout:   10 * time.Second,
+				Build:     "env",
+				Namespace: "test_load",
+			},
+		},
+		"order decides precedence": {
+			sources: []Source{EnvSource("test_load"), FileSource("test_load", path), DefaultsSource()},
+			env: map[string]string{
+				"TEST_LOAD_NAMESPACE": "env",
+			},
+			want: testConfig{
+				Host:      "0.0.0.0:3000",
+				Timeout:   5 * time.Second,
+				Build:     "dev",
+				Namespace: "env",
+			},
+		},
+		"custom source": {
+			sources: []Source{
+				DefaultsSource(),
+				EnvSource("test_load"),
+				SourceFunc(func(cfg any) error {
+					cfg.(*testConfig).Build = "flag"
+					return nil
+				}),
+			},
+			env: map[string]string{
+				"TEST_LOAD_BUILD": "env",
+			},
+			want: testConfig{
+				Host:      "0.0.0.0:3000",
+				Timeout:   5 * time.Second,
+				Build:     "flag",
+				Namespace: "test_load",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			got, err := Load[testConfig](tt.sources...)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sources []Source
+		wantErr string
+	}{
+		"missing file": {
+			sources: []Source{FileSource("test_load", filepath.Join(t.TempDir(), "missing.env"))},
+			wantErr: "failed to open config file",
+		},
+		"invalid value": {
+			sources: []Source{lookupSource{keyPrefix: "app", lookup: func(key string) (string, bool) {
+				return "soon", key == "APP_TIMEOUT"
+			}}},
+			wantErr: `APP_TIMEOUT: time: invalid duration "soon"`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Load[testConfig](tt.sources...)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestSetField(t *testing.T) {
+	t.Parallel()
+
+	type kinds struct {
+		String   string
+		Named    testOrder
+		Bool     bool
+		Int      int
+		Uint     uint16
+		Float    float64
+		Duration time.Duration
+		Slice    []string
+		Map      map[string]time.Duration
+		Ignored  string `ignored:"true"`
+		Renamed  string `envconfig:"OTHER"`
+	}
+
+	values := map[string]string{
+		"APP_STRING":   "text",
+		"APP_NAMED":    "first",
+		"APP_BOOL":     "true",
+		"APP_INT":      "-42",
+		"APP_UINT":     "8080",
+		"APP_FLOAT":    "1.5",
+		"APP_DURATION": "1m30s",
+		"APP_SLICE":    "a,b,c",
+		"APP_MAP":      "GET:5s,POST:30s",
+		"APP_IGNORED":  "set",
+		"APP_OTHER":    "renamed",
+	}
+	source := lookupSource{keyPrefix: "app", lookup: func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}}
+
+	got, err := Load[kinds](source)
+	assert.NoError(t, err)
+	assert.Equal(t, kinds{
+		String:   "text",
+		Named:    "first",
+		Bool:     true,
+		Int:      -42,
+		Uint:     8080,
+		Float:    1.5,
+		Duration: 90 * time.Second,
+		Slice:    []string{"a", "b", "c"},
+		Map:      map[string]time.Duration{"GET": 5 * time.Second, "POST": 30 * time.Second},
+		Renamed:  "renamed",
+	}, got)
+}
+
+type testOrder string
@@ -0,0 +1,54 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+)
+
+// GCStats reports the heap before and after a forced garbage collection.
+type GCStats struct {
+	HeapAllocBefore   uint64 `json:"heap_alloc_before"`
+	HeapAllocAfter    uint64 `json:"heap_alloc_after"`
+	HeapObjectsBefore uint64 `json:"heap_objects_before"`
+	HeapObjectsAfter  uint64 `json:"heap_objects_after"`
+}
+
+// NewMux creates a ServeMux with the debug endpoints registered.
+func NewMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/memstats", MemStats)
+	mux.HandleFunc("POST /debug/gc", GC)
+
+	return mux
+}
+
+// MemStats writes the current runtime.MemStats as JSON.
+func MemStats(w http.ResponseWriter, r *http.Request) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	writeJSON(w, m)
+}
+
+// GC forces a garbage collection and writes the heap size before and after as JSON.
+func GC(w http.ResponseWriter, r *http.Request) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	writeJSON(w, GCStats{
+		HeapAllocBefore:   before.HeapAlloc,
+		HeapAllocAfter:    after.HeapAlloc,
+		HeapObjectsBefore: before.HeapObjects,
+		HeapObjectsAfter:  after.HeapObjects,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
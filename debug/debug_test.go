@@ -0,0 +1,95 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// garbage keeps an allocation reachable until the test drops it
+var garbage [][]byte
+
+func TestMemStats(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method string
+		want   int
+	}{
+		"GET returns memstats": {
+			method: http.MethodGet,
+			want:   http.StatusOK,
+		},
+		"POST not allowed": {
+			method: http.MethodPost,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(tt.method, "/debug/memstats", nil)
+			rec := httptest.NewRecorder()
+
+			NewMux().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var got runtime.MemStats
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.NotZero(t, got.HeapAlloc)
+			assert.NotZero(t, got.Sys)
+		})
+	}
+}
+
+func TestGC(t *testing.T) {
+	tests := map[string]struct {
+		method string
+		want   int
+	}{
+		"POST forces GC": {
+			method: http.MethodPost,
+			want:   http.StatusOK,
+		},
+		"GET not allowed": {
+			method: http.MethodGet,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Allocate ~64MB and make it unreachable so the forced GC has something to collect
+			for i := 0; i < 64; i++ {
+				garbage = append(garbage, make([]byte, 1<<20))
+			}
+			garbage = nil
+
+			req := httptest.NewRequest(tt.method, "/debug/gc", nil)
+			rec := httptest.NewRecorder()
+
+			NewMux().ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			var got GCStats
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &got))
+			assert.Less(t, got.HeapAllocAfter, got.HeapAllocBefore)
+		})
+	}
+}
@@ -33,9 +33,10 @@ func main() {
 	}
 
 	// 3. Define Registration Function
-	register := func(s *googlegrpc.Server) {
+	register := func(s *googlegrpc.Server) error {
 		helloworld.RegisterGreeterServer(s, &server{})
 		logger.Info("registering services", "service", "Greeter")
+		return nil
 	}
 
 	// 4. Create Server
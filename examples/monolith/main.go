@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/rabellamy/server/grpc"
+	"github.com/rabellamy/server/rest"
+	googlegrpc "google.golang.org/grpc"
+)
+
+// greeter is used to implement helloworld.GreeterServer.
+type greeter struct {
+	helloworld.UnimplementedGreeterServer
+}
+
+// SayHello implements helloworld.GreeterServer
+func (g *greeter) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	return &helloworld.HelloReply{Message: fmt.Sprintf("Hello %s", in.GetName())}, nil
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintf(w, "Hello from REST! You requested: %s", r.URL.Path)
+}
+
+func main() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	// Both servers record into one registry, served by the REST server's
+	// metrics endpoint.
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	restConfig, err := rest.LoadConfig("monolith")
+	if err != nil {
+		logger.Error("config loading failed", "err", err)
+		os.Exit(1)
+	}
+	restConfig.Registry = reg
+
+	grpcConfig, err := grpc.LoadConfig("monolith")
+	if err != nil {
+		logger.Error("config loading failed", "err", err)
+		os.Exit(1)
+	}
+	grpcConfig.DisableMetricsServer = true
+	grpcConfig.Registry = reg
+
+	restServer, err := rest.NewServer(context.Background(), restConfig, rest.Routes{"/hello": hello}, logger)
+	if err != nil {
+		logger.Error("server instantiation failed", "err", err)
+		os.Exit(1)
+	}
+
+	register := func(s *googlegrpc.Server) error {
+		helloworld.RegisterGreeterServer(s, &greeter{})
+		return nil
+	}
+	grpcServer, err := grpc.NewServer(context.Background(), grpcConfig, register, logger)
+	if err != nil {
+		logger.Error("server instantiation failed", "err", err)
+		os.Exit(1)
+	}
+
+	// Both servers stop on SIGINT/SIGTERM; wait for both to finish.
+	errs := make(chan error, 2)
+	go func() { errs <- restServer.Run() }()
+	go func() { errs <- grpcServer.Run() }()
+
+	for range 2 {
+		if err := <-errs; err != nil {
+			logger.Error("server failed", "err", err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/stats"
+)
+
+// activeRPCs is a stats.Handler counting the RPCs currently in flight, so
+// shutdown can report how many are still draining.
+type activeRPCs struct {
+	count atomic.Int64
+}
+
+func (a *activeRPCs) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (a *activeRPCs) HandleRPC(_ context.Context, s stats.RPCStats) {
+	switch s.(type) {
+	case *stats.Begin:
+		a.count.Add(1)
+	case *stats.End:
+		a.count.Add(-1)
+	}
+}
+
+func (a *activeRPCs) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (a *activeRPCs) HandleConn(context.Context, stats.ConnStats) {}
+
+// Load returns the number of RPCs in flight.
+func (a *activeRPCs) Load() int64 {
+	return a.count.Load()
+}
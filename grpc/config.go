@@ -1,33 +1,138 @@
 package grpc
 
 import (
+	"fmt"
+	"regexp"
 	"time"
 
-	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/server/config"
 )
 
+// DefaultShutdownTimeout is used when Config.ShutdownTimeout is zero, which
+// would otherwise leave no time at all to shut down gracefully.
+const DefaultShutdownTimeout = 20 * time.Second
+
+// ShutdownOrder controls when the metrics server shuts down relative to the
+// gRPC server.
+type ShutdownOrder string
+
+const (
+	// ShutdownMetricsLast shuts the metrics server down once the gRPC
+	// server has drained, so metrics recorded during the drain can still be
+	// scraped.
+	ShutdownMetricsLast ShutdownOrder = "metrics-last"
+
+	// ShutdownMetricsFirst shuts the metrics server down before the gRPC
+	// server, to stop scrapes of an instance that is going away.
+	ShutdownMetricsFirst ShutdownOrder = "metrics-first"
+
+	// ShutdownConcurrent shuts all servers down at once.
+	ShutdownConcurrent ShutdownOrder = "concurrent"
+)
+
+// parseShutdownOrder validates order, defaulting an empty one to
+// ShutdownMetricsLast.
+func parseShutdownOrder(order ShutdownOrder) (ShutdownOrder, error) {
+	switch order {
+	case "":
+		return ShutdownMetricsLast, nil
+	case ShutdownMetricsLast, ShutdownMetricsFirst, ShutdownConcurrent:
+		return order, nil
+	default:
+		return "", fmt.Errorf("invalid shutdown order %q", order)
+	}
+}
+
 type Config struct {
-	ShutdownTimeout time.Duration `default:"20s"`
-	APIHost         string        `default:"0.0.0.0:50051"`
-	DebugHost       string        `default:"0.0.0.0:3010"`
-	MetricsHost     string        `default:"0.0.0.0:2112"`
-	Build           string        `default:"dev"`
-	Desc            string        `default:"example grpc server"`
-	Namespace       string        `default:"test"`
-	Version         string        `default:"test"`
-	Name            string        `default:"test"`
+	ShutdownTimeout             time.Duration `default:"20s"`
+	QuietShutdown               bool          `default:"false"`
+	ShutdownOrder               ShutdownOrder `default:"metrics-last"`
+	ShutdownProgressInterval    time.Duration `default:"5s"`
+	DrainWindow                 time.Duration `default:"5s"`
+	ConnectionTimeout           time.Duration `default:"120s"`
+	MaxConnectionIdle           time.Duration `default:"0s"`
+	MaxConnectionAge            time.Duration `default:"0s"`
+	MaxConnectionAgeGrace       time.Duration `default:"0s"`
+	KeepaliveTime               time.Duration `default:"0s"`
+	KeepaliveTimeout            time.Duration `default:"0s"`
+	KeepaliveMinTime            time.Duration `default:"0s"`
+	KeepaliveWithoutStream      bool          `default:"false"`
+	MaxHeaderListSize           uint32        `default:"1048576"`
+	SlowRequestThreshold        time.Duration `default:"0s"`
+	APIHost                     string        `default:"0.0.0.0:50051"`
+	DebugHost                   string        `default:"0.0.0.0:3010"`
+	EnableDebug                 bool          `default:"false"`
+	MetricsHost                 string        `default:"0.0.0.0:2112"`
+	ReusePort                   bool          `default:"false"`
+	ListenNetwork               string        `default:"tcp"`
+	ListenRetries               int           `default:"0"`
+	ListenRetryBackoff          time.Duration `default:"100ms"`
+	TCPKeepAlive                time.Duration `default:"3m"`
+	Build                       string        `default:"dev"`
+	Desc                        string        `default:"example grpc server"`
+	Version                     string        `default:"test"`
+	DisableReflection           bool          `default:"false"`
+	DisableMetricsServer        bool          `default:"false"`
+	DisableMetrics              bool          `default:"false"`
+	DisableRecovery             bool          `default:"false"`
+	MetricsInheritTLS           bool          `default:"false"`
+	MetricsScrapeTimeout        time.Duration `default:"0s"`
+	MetricsMaxRequestsInFlight  int           `default:"0"`
+	TraceIDMetadata             bool          `default:"false"`
+	RequestIDMetadata           bool          `default:"false"`
+	GRPCLogs                    bool          `default:"false"`
+	NativeHistogramBucketFactor float64       `default:"0"`
+	DrainLastMethods            []string
+	RequiredMetadata            []string
+	RequiredMetadataSkip        []string
+	TLSCertFile                 string
+	TLSKeyFile                  string
+	TLSClientCAFile             string
+	MetricsTLSCertFile          string
+	MetricsTLSKeyFile           string
+	MetricsSnapshotFile         string
+	Name                        string
+	HealthServiceName           string
+	Namespace                   string
+	LogAttrs                    map[string]string
+	MetricConstLabels           map[string]string
+
+	// Registry, when set, is used instead of the Prometheus default registry
+	// for the server's metrics and its /metrics endpoint.
+	Registry *prometheus.Registry `ignored:"true"`
+
+	// OnPanic, when set, is called with every panic recovered from an RPC
+	// handler, before the error is returned to the client.
+	OnPanic PanicHandler `ignored:"true"`
+
+	// ErrorMapper, when set, converts every error returned by an RPC handler
+	// before it is recorded in metrics and sent to the client. See
+	// DefaultErrorMapper.
+	ErrorMapper ErrorMapper `ignored:"true"`
 }
 
 func LoadConfig(prefix string) (Config, error) {
-	var c Config
-	err := envconfig.Process(prefix, &c)
-	if err != nil {
-		return c, err
-	}
+	return config.LoadConfig[Config](prefix)
+}
 
+// SetPrefixDefaults implements config.PrefixDefaulter: Name and Namespace
+// default to the prefix rather than a fixed value, and Namespace follows an
+// explicitly set Name.
+func (c *Config) SetPrefixDefaults(prefix string) {
+	if c.Name == "" {
+		c.Name = prefix
+	}
 	if c.Namespace == "" {
-		c.Namespace = prefix
+		c.Namespace = namespaceFromName(c.Name)
 	}
+}
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 
-	return c, nil
+// namespaceFromName turns a service name into a metric namespace by replacing
+// characters Prometheus doesn't allow, e.g. "order-service" becomes
+// "order_service".
+func namespaceFromName(name string) string {
+	return invalidNamespaceChars.ReplaceAllString(name, "_")
 }
@@ -19,15 +19,23 @@ func TestLoadConfig(t *testing.T) {
 			prefix: "test",
 			env:    map[string]string{},
 			want: Config{
-				ShutdownTimeout: 20 * time.Second,
-				APIHost:         "0.0.0.0:50051",
-				DebugHost:       "0.0.0.0:3010",
-				MetricsHost:     "0.0.0.0:2112",
-				Build:           "dev",
-				Desc:            "example grpc server",
-				Namespace:       "test",
-				Version:         "test",
-				Name:            "test",
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        120 * time.Second,
+				MaxHeaderListSize:        1 << 20,
+				APIHost:                  "0.0.0.0:50051",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "test",
+				Version:                  "test",
+				Name:                     "test",
 			},
 		},
 		"env vars set": {
@@ -37,15 +45,23 @@ func TestLoadConfig(t *testing.T) {
 				"TEST_NAME":    "custom-name",
 			},
 			want: Config{
-				ShutdownTimeout: 20 * time.Second,
-				APIHost:         "1.2.3.4:5678",
-				DebugHost:       "0.0.0.0:3010",
-				MetricsHost:     "0.0.0.0:2112",
-				Build:           "dev",
-				Desc:            "example grpc server",
-				Namespace:       "test",
-				Version:         "test",
-				Name:            "custom-name",
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        120 * time.Second,
+				MaxHeaderListSize:        1 << 20,
+				APIHost:                  "1.2.3.4:5678",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "custom_name",
+				Version:                  "test",
+				Name:                     "custom-name",
 			},
 		},
 		"explicit namespace": {
@@ -54,15 +70,122 @@ func TestLoadConfig(t *testing.T) {
 				"TEST_NAMESPACE": "custom-ns",
 			},
 			want: Config{
-				ShutdownTimeout: 20 * time.Second,
-				APIHost:         "0.0.0.0:50051",
-				DebugHost:       "0.0.0.0:3010",
-				MetricsHost:     "0.0.0.0:2112",
-				Build:           "dev",
-				Desc:            "example grpc server",
-				Namespace:       "custom-ns",
-				Version:         "test",
-				Name:            "test",
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        120 * time.Second,
+				MaxHeaderListSize:        1 << 20,
+				APIHost:                  "0.0.0.0:50051",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "custom-ns",
+				Version:                  "test",
+				Name:                     "test",
+			},
+		},
+		"name and namespace from prefix": {
+			prefix: "orders",
+			env:    map[string]string{},
+			want: Config{
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        120 * time.Second,
+				MaxHeaderListSize:        1 << 20,
+				APIHost:                  "0.0.0.0:50051",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "orders",
+				Version:                  "test",
+				Name:                     "orders",
+			},
+		},
+		"explicit name and namespace": {
+			prefix: "test",
+			env: map[string]string{
+				"TEST_NAME":      "order-service",
+				"TEST_NAMESPACE": "shop",
+			},
+			want: Config{
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        120 * time.Second,
+				MaxHeaderListSize:        1 << 20,
+				APIHost:                  "0.0.0.0:50051",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "shop",
+				Version:                  "test",
+				Name:                     "order-service",
+			},
+		},
+		"connection timeout": {
+			prefix: "test",
+			env: map[string]string{
+				"TEST_CONNECTIONTIMEOUT": "5s",
+			},
+			want: Config{
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        5 * time.Second,
+				MaxHeaderListSize:        1 << 20,
+				APIHost:                  "0.0.0.0:50051",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "test",
+				Version:                  "test",
+				Name:                     "test",
+			},
+		},
+		"max header list size": {
+			prefix: "test",
+			env: map[string]string{
+				"TEST_MAXHEADERLISTSIZE": "8192",
+			},
+			want: Config{
+				ShutdownTimeout:          20 * time.Second,
+				ShutdownOrder:            ShutdownMetricsLast,
+				ShutdownProgressInterval: 5 * time.Second,
+				DrainWindow:              5 * time.Second,
+				ConnectionTimeout:        120 * time.Second,
+				MaxHeaderListSize:        8192,
+				APIHost:                  "0.0.0.0:50051",
+				ListenNetwork:            "tcp",
+				ListenRetryBackoff:       100 * time.Millisecond,
+				TCPKeepAlive:             3 * time.Minute,
+				DebugHost:                "0.0.0.0:3010",
+				MetricsHost:              "0.0.0.0:2112",
+				Build:                    "dev",
+				Desc:                     "example grpc server",
+				Namespace:                "test",
+				Version:                  "test",
+				Name:                     "test",
 			},
 		},
 		"invalid duration": {
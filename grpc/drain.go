@@ -0,0 +1,79 @@
+package grpc
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// drainer rejects new calls to a set of methods once shutdown begins, so
+// in-flight calls to the remaining methods get the whole grace period.
+type drainer struct {
+	methods  map[string]struct{}
+	draining atomic.Bool
+}
+
+// newDrainer returns a drainer for the given full method names, e.g.
+// "/helloworld.Greeter/SayHello".
+func newDrainer(methods []string) *drainer {
+	d := &drainer{methods: make(map[string]struct{}, len(methods))}
+	for _, method := range methods {
+		d.methods[method] = struct{}{}
+	}
+
+	return d
+}
+
+// start makes the drainer reject new calls to its methods.
+func (d *drainer) start() {
+	d.draining.Store(true)
+}
+
+// check returns an Unavailable error when method is being drained.
+func (d *drainer) check(method string) error {
+	if !d.draining.Load() {
+		return nil
+	}
+	if _, ok := d.methods[method]; !ok {
+		return nil
+	}
+
+	return status.Error(codes.Unavailable, "server is shutting down")
+}
+
+// unaryInterceptor returns a gRPC unary interceptor rejecting calls to
+// drained methods.
+func (d *drainer) unaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := d.check(info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// streamInterceptor returns a gRPC stream interceptor rejecting calls to
+// drained methods.
+func (d *drainer) streamInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := d.check(info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,155 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestDrainerInterceptors(t *testing.T) {
+	t.Parallel()
+
+	const batch = "/batch.Service/Export"
+
+	tests := map[string]struct {
+		stream   bool
+		draining bool
+		method   string
+		wantCode codes.Code
+	}{
+		"unary before shutdown": {
+			method:   batch,
+			wantCode: codes.OK,
+		},
+		"unary drain-last method during shutdown": {
+			draining: true,
+			method:   batch,
+			wantCode: codes.Unavailable,
+		},
+		"unary other method during shutdown": {
+			draining: true,
+			method:   "/helloworld.Greeter/SayHello",
+			wantCode: codes.OK,
+		},
+		"stream before shutdown": {
+			stream:   true,
+			method:   batch,
+			wantCode: codes.OK,
+		},
+		"stream drain-last method during shutdown": {
+			stream:   true,
+			draining: true,
+			method:   batch,
+			wantCode: codes.Unavailable,
+		},
+		"stream other method during shutdown": {
+			stream:   true,
+			draining: true,
+			method:   "/helloworld.Greeter/SayHello",
+			wantCode: codes.OK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d := newDrainer([]string{batch})
+			if tt.draining {
+				d.start()
+			}
+
+			called := false
+			var err error
+			if tt.stream {
+				info := &grpc.StreamServerInfo{FullMethod: tt.method}
+				err = d.streamInterceptor()(nil, &mockServerStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+					called = true
+					return nil
+				})
+			} else {
+				info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+				_, err = d.unaryInterceptor()(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					called = true
+					return nil, nil
+				})
+			}
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCode == codes.OK, called)
+		})
+	}
+}
+
+func TestDrainLastMethods(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:        "test_drain_last_methods",
+		APIHost:          addr,
+		MetricsHost:      "127.0.0.1:0",
+		ShutdownTimeout:  5 * time.Second,
+		DrainLastMethods: []string{"/helloworld.Greeter/SayHello"},
+		DrainWindow:      500 * time.Millisecond,
+		Registry:         prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	register := func(s *grpc.Server) error {
+		helloworld.RegisterGreeterServer(s, greeterServer{})
+		return nil
+	}
+	server, err := NewServer(context.Background(), config, register, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	signals := make(chan os.Signal, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(signals)
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	greeter := helloworld.NewGreeterClient(conn)
+	_, err = greeter.SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	assert.NoError(t, err)
+
+	// Shut down as on SIGTERM, then call while the drain window is open
+	signals <- syscall.SIGTERM
+	time.Sleep(100 * time.Millisecond)
+
+	_, err = greeter.SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+
+	assert.NoError(t, <-errChan)
+}
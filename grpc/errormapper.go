@@ -0,0 +1,72 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorMapper converts an error returned by an RPC handler into the error
+// sent to the client, so handlers can return plain or domain errors and have
+// them turned into statuses with meaningful codes in one place. Returning
+// the error unchanged leaves it to gRPC, which reports errors without a
+// status as codes.Unknown.
+type ErrorMapper func(error) error
+
+// DefaultErrorMapper maps context cancellation and deadline errors to
+// codes.Canceled and codes.DeadlineExceeded. Other errors, including those
+// that already carry a status, are returned unchanged. Custom mappers can
+// fall back to it for errors they don't recognize.
+func DefaultErrorMapper(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return err
+	}
+}
+
+// UnaryErrorMapperInterceptor returns a gRPC unary interceptor that passes
+// every error returned by the handler through mapper.
+func UnaryErrorMapperInterceptor(mapper ErrorMapper) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			err = mapper(err)
+		}
+
+		return resp, err
+	}
+}
+
+// StreamErrorMapperInterceptor returns a gRPC stream interceptor that passes
+// every error returned by the handler through mapper.
+func StreamErrorMapperInterceptor(mapper ErrorMapper) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		err := handler(srv, ss)
+		if err != nil {
+			err = mapper(err)
+		}
+
+		return err
+	}
+}
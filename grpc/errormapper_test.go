@@ -0,0 +1,204 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var errGreetingNotFound = errors.New("greeting not found")
+
+// mapNotFound maps errGreetingNotFound to codes.NotFound and falls back to
+// DefaultErrorMapper.
+func mapNotFound(err error) error {
+	if errors.Is(err, errGreetingNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+
+	return DefaultErrorMapper(err)
+}
+
+func TestDefaultErrorMapper(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		err      error
+		wantCode codes.Code
+	}{
+		"canceled": {
+			err:      context.Canceled,
+			wantCode: codes.Canceled,
+		},
+		"wrapped deadline exceeded": {
+			err:      fmt.Errorf("querying: %w", context.DeadlineExceeded),
+			wantCode: codes.DeadlineExceeded,
+		},
+		"status kept": {
+			err:      status.Error(codes.PermissionDenied, "denied"),
+			wantCode: codes.PermissionDenied,
+		},
+		"plain error unchanged": {
+			err:      errGreetingNotFound,
+			wantCode: codes.Unknown,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := DefaultErrorMapper(tt.err)
+			assert.Equal(t, tt.wantCode, status.Code(got))
+			if tt.wantCode == codes.Unknown {
+				assert.Equal(t, tt.err, got)
+			}
+		})
+	}
+}
+
+func TestErrorMapperInterceptors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		stream   bool
+		err      error
+		wantCode codes.Code
+	}{
+		"unary sentinel error": {
+			err:      fmt.Errorf("loading: %w", errGreetingNotFound),
+			wantCode: codes.NotFound,
+		},
+		"unary no error": {
+			wantCode: codes.OK,
+		},
+		"stream sentinel error": {
+			stream:   true,
+			err:      errGreetingNotFound,
+			wantCode: codes.NotFound,
+		},
+		"stream context error": {
+			stream:   true,
+			err:      context.Canceled,
+			wantCode: codes.Canceled,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var err error
+			if tt.stream {
+				info := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+				err = StreamErrorMapperInterceptor(mapNotFound)(nil, &mockServerStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+					return tt.err
+				})
+			} else {
+				info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+				_, err = UnaryErrorMapperInterceptor(mapNotFound)(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					return nil, tt.err
+				})
+			}
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+		})
+	}
+}
+
+// notFoundGreeter fails every call with errGreetingNotFound.
+type notFoundGreeter struct {
+	helloworld.UnimplementedGreeterServer
+}
+
+func (notFoundGreeter) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	return nil, errGreetingNotFound
+}
+
+func TestErrorMapper(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	reg := prometheus.NewRegistry()
+	config := Config{
+		Namespace:       "test_error_mapper",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        reg,
+		ErrorMapper:     mapNotFound,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	register := func(s *grpc.Server) error {
+		helloworld.RegisterGreeterServer(s, notFoundGreeter{})
+		return nil
+	}
+	server, err := NewServer(ctx, config, register, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	// The RED error label reflects the mapped code
+	family, err := gatherFamily(reg, "test_error_mapper_grpc_errors_total")
+	if assert.NoError(t, err) && assert.Len(t, family.GetMetric(), 1) {
+		labels := family.GetMetric()[0].GetLabel()
+		assert.Equal(t, "error", labels[0].GetName())
+		assert.Equal(t, codes.NotFound.String(), labels[0].GetValue())
+	}
+}
+
+// gatherFamily gathers reg and returns the metric family called name.
+func gatherFamily(reg *prometheus.Registry, name string) (*dto.MetricFamily, error) {
+	families, err := reg.Gather()
+	if err != nil {
+		return nil, err
+	}
+	for _, family := range families {
+		if family.GetName() == name {
+			return family, nil
+		}
+	}
+
+	return nil, fmt.Errorf("metric %s not found", name)
+}
@@ -0,0 +1,75 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"google.golang.org/grpc/grpclog"
+)
+
+// slogLogger adapts a slog.Logger to grpclog.LoggerV2, so grpc-go's internal
+// logs share the server's log output.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewGRPCLogger returns a grpclog.LoggerV2 that writes grpc-go's internal
+// logs to logger, tagged component=grpc. grpc-go logs connection-level
+// details at info, so they are written at debug level to keep them out of
+// the server's own info logs. Install it with grpclog.SetLoggerV2 before the
+// server is created; it isn't safe to swap while gRPC is running.
+func NewGRPCLogger(logger *slog.Logger) grpclog.LoggerV2 {
+	return &slogLogger{logger: logger.With("component", "grpc")}
+}
+
+func (l *slogLogger) log(level slog.Level, msg string) {
+	l.logger.Log(context.Background(), level, msg)
+}
+
+func (l *slogLogger) Info(args ...any)   { l.log(slog.LevelDebug, fmt.Sprint(args...)) }
+func (l *slogLogger) Infoln(args ...any) { l.log(slog.LevelDebug, sprintln(args...)) }
+func (l *slogLogger) Infof(format string, args ...any) {
+	l.log(slog.LevelDebug, fmt.Sprintf(format, args...))
+}
+
+func (l *slogLogger) Warning(args ...any)   { l.log(slog.LevelWarn, fmt.Sprint(args...)) }
+func (l *slogLogger) Warningln(args ...any) { l.log(slog.LevelWarn, sprintln(args...)) }
+func (l *slogLogger) Warningf(format string, args ...any) {
+	l.log(slog.LevelWarn, fmt.Sprintf(format, args...))
+}
+
+func (l *slogLogger) Error(args ...any)   { l.log(slog.LevelError, fmt.Sprint(args...)) }
+func (l *slogLogger) Errorln(args ...any) { l.log(slog.LevelError, sprintln(args...)) }
+func (l *slogLogger) Errorf(format string, args ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+}
+
+// Fatal logs at error level and exits, as grpclog.LoggerV2 requires.
+func (l *slogLogger) Fatal(args ...any) {
+	l.log(slog.LevelError, fmt.Sprint(args...))
+	os.Exit(1)
+}
+
+func (l *slogLogger) Fatalln(args ...any) {
+	l.log(slog.LevelError, sprintln(args...))
+	os.Exit(1)
+}
+
+func (l *slogLogger) Fatalf(format string, args ...any) {
+	l.log(slog.LevelError, fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// V reports whether verbose logs at level are enabled. Only level 0 is, as
+// with grpc-go's default logger, once the handler is enabled for debug.
+func (l *slogLogger) V(level int) bool {
+	return level <= 0 && l.logger.Enabled(context.Background(), slog.LevelDebug)
+}
+
+// sprintln formats args like fmt.Sprintln, without the trailing newline.
+func sprintln(args ...any) string {
+	s := fmt.Sprintln(args...)
+	return s[:len(s)-1]
+}
@@ -0,0 +1,105 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/grpclog"
+)
+
+func TestNewGRPCLogger(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		log       func(grpclog.LoggerV2)
+		wantLevel string
+		wantMsg   string
+	}{
+		"info is debug": {
+			log:       func(l grpclog.LoggerV2) { l.Info("transport ", "closing") },
+			wantLevel: "DEBUG",
+			wantMsg:   "transport closing",
+		},
+		"infoln": {
+			log:       func(l grpclog.LoggerV2) { l.Infoln("transport", "closing") },
+			wantLevel: "DEBUG",
+			wantMsg:   "transport closing",
+		},
+		"warningf": {
+			log:       func(l grpclog.LoggerV2) { l.Warningf("retrying in %d", 3) },
+			wantLevel: "WARN",
+			wantMsg:   "retrying in 3",
+		},
+		"error": {
+			log:       func(l grpclog.LoggerV2) { l.Error("handshake failed") },
+			wantLevel: "ERROR",
+			wantMsg:   "handshake failed",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			tt.log(NewGRPCLogger(logger))
+
+			var entry map[string]any
+			if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+				assert.Equal(t, tt.wantLevel, entry["level"])
+				assert.Equal(t, tt.wantMsg, entry["msg"])
+				assert.Equal(t, "grpc", entry["component"])
+			}
+		})
+	}
+
+	t.Run("verbosity", func(t *testing.T) {
+		t.Parallel()
+
+		debug := NewGRPCLogger(slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		assert.True(t, debug.V(0))
+		assert.False(t, debug.V(2))
+
+		info := NewGRPCLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+		assert.False(t, info.V(0))
+	})
+}
+
+// TestGRPCLogs swaps the process-wide grpclog logger, so it doesn't run in
+// parallel with the other tests.
+func TestGRPCLogs(t *testing.T) {
+	defer grpclog.SetLoggerV2(grpclog.NewLoggerV2(io.Discard, io.Discard, os.Stderr))
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	config := Config{
+		APIHost:        "127.0.0.1:0",
+		GRPCLogs:       true,
+		DisableMetrics: true,
+		Registry:       prometheus.NewRegistry(),
+	}
+	_, err := NewServer(context.Background(), config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf.Reset()
+	grpclog.Component("core").Warning("internal warning")
+
+	var entry map[string]any
+	if assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry)) {
+		assert.Equal(t, "WARN", entry["level"])
+		assert.Equal(t, "grpc", entry["component"])
+		assert.Contains(t, entry["msg"], "internal warning")
+	}
+}
@@ -0,0 +1,71 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthServer wraps health.Server so Watch streams end cleanly when the
+// server shuts down. Otherwise a watching client holds GracefulStop open
+// until the shutdown timeout forces a Stop, and sees a connection reset
+// instead of the end of the stream.
+type healthServer struct {
+	*health.Server
+
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+func newHealthServer() *healthServer {
+	return &healthServer{
+		Server:   health.NewServer(),
+		shutdown: make(chan struct{}),
+	}
+}
+
+// endWatches ends all current and future Watch streams.
+func (h *healthServer) endWatches() {
+	h.shutdownOnce.Do(func() {
+		close(h.shutdown)
+	})
+}
+
+// Watch implements grpc_health_v1.HealthServer. On shutdown it sends a final
+// NOT_SERVING and ends the stream without an error.
+func (h *healthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-h.shutdown:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	err := h.Server.Watch(req, &watchStream{Health_WatchServer: stream, ctx: ctx})
+
+	select {
+	case <-h.shutdown:
+		return stream.Send(&grpc_health_v1.HealthCheckResponse{
+			Status: grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+		})
+	default:
+		return err
+	}
+}
+
+// watchStream overrides a Watch stream's context so it can be cancelled on
+// shutdown.
+type watchStream struct {
+	grpc_health_v1.Health_WatchServer
+	ctx context.Context
+}
+
+func (s *watchStream) Context() context.Context {
+	return s.ctx
+}
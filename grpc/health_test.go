@@ -0,0 +1,238 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestHealthWatchShutdown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace  string
+		sendSignal bool
+	}{
+		"context cancellation": {
+			namespace: "test_health_watch_ctx",
+		},
+		"signal": {
+			namespace:  "test_health_watch_signal",
+			sendSignal: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:         tt.namespace,
+				Name:              "watched",
+				HealthServiceName: "watched",
+				APIHost:           addr,
+				MetricsHost:       "127.0.0.1:0",
+				ShutdownTimeout:   5 * time.Second,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			assert.NoError(t, err)
+
+			shutdownChan := make(chan os.Signal, 1)
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(shutdownChan)
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			stream, err := grpc_health_v1.NewHealthClient(conn).Watch(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: config.HealthServiceName})
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			resp, err := stream.Recv()
+			assert.NoError(t, err)
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus())
+
+			start := time.Now()
+			if tt.sendSignal {
+				shutdownChan <- os.Interrupt
+			} else {
+				cancel()
+			}
+
+			// The stream ends cleanly, with NOT_SERVING as the last status
+			last := resp.GetStatus()
+			for {
+				resp, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				last = resp.GetStatus()
+			}
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, last)
+
+			// Shutdown doesn't wait for the timeout to force the stream closed
+			assert.NoError(t, <-errChan)
+			assert.Less(t, time.Since(start), config.ShutdownTimeout)
+		})
+	}
+}
+
+func TestSetHealthStatus(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_set_health_status",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+	client := grpc_health_v1.NewHealthClient(conn)
+
+	for _, status := range []grpc_health_v1.HealthCheckResponse_ServingStatus{
+		grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN,
+		grpc_health_v1.HealthCheckResponse_NOT_SERVING,
+		grpc_health_v1.HealthCheckResponse_SERVING,
+	} {
+		server.SetHealthStatus("payments", status)
+
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "payments"})
+		if assert.NoError(t, err) {
+			assert.Equal(t, status, resp.GetStatus())
+		}
+	}
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestHealthServiceName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace         string
+		healthServiceName string
+	}{
+		"overall": {
+			namespace: "test_health_service_overall",
+		},
+		"named": {
+			namespace:         "test_health_service_named",
+			healthServiceName: "orders",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Name:              "test",
+				Namespace:         tt.namespace,
+				HealthServiceName: tt.healthServiceName,
+				APIHost:           addr,
+				MetricsHost:       "127.0.0.1:0",
+				ShutdownTimeout:   5 * time.Second,
+				Registry:          prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			status := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+				resp, err := server.healthServer.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+				if err != nil {
+					return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN
+				}
+				return resp.GetStatus()
+			}
+
+			// Not serving until the server runs
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, status(tt.healthServiceName))
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, status(tt.healthServiceName))
+			// Name no longer doubles as the health service key
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, status(config.Name))
+
+			cancel()
+			assert.NoError(t, <-errChan)
+
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, status(tt.healthServiceName))
+		})
+	}
+}
@@ -2,28 +2,102 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/rabellamy/promstrap/strategy"
+	"github.com/rabellamy/server/metrics"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+// ErrInvalidMethod is returned when a gRPC full method name can't be split
+// into its service and method.
+var ErrInvalidMethod = errors.New("invalid gRPC method")
+
+// InterceptorOption configures the RED interceptors.
+type InterceptorOption func(*interceptorOptions)
+
+type interceptorOptions struct {
+	logger        *slog.Logger
+	clock         metrics.Clock
+	slowThreshold time.Duration
+	onPanic       PanicHandler
+	sampleEvery   int
+}
+
+func newInterceptorOptions(opts []InterceptorOption) interceptorOptions {
+	o := interceptorOptions{
+		logger: slog.Default(),
+		clock:  metrics.RealClock{},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+// WithLogger sets the logger the interceptors report internal failures to.
+// Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.logger = logger
+	}
+}
+
+// WithClock sets the clock used to measure RPC durations. Defaults to the
+// wall clock.
+func WithClock(clock metrics.Clock) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.clock = clock
+	}
+}
+
+// WithSlowThreshold makes the interceptors log a warning, tagged slow=true,
+// for calls that take longer than threshold. Zero disables slow call logging.
+func WithSlowThreshold(threshold time.Duration) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.slowThreshold = threshold
+	}
+}
+
+// logSlow warns about a call that took longer than the slow threshold.
+func (o interceptorOptions) logSlow(service, method string, elapsed time.Duration, err error) {
+	if o.slowThreshold <= 0 || elapsed <= o.slowThreshold {
+		return
+	}
+
+	o.logger.Warn("rpc", "slow", true, "service", service, "method", method, "code", status.Code(err).String(), "duration", elapsed)
+}
+
+// invalidMethodError logs a method extraction failure and converts it into the
+// status returned to the client. A malformed method name is a server bug, not
+// a client one, so it is reported as codes.Internal.
+func invalidMethodError(logger *slog.Logger, err error) error {
+	logger.Error("interceptor", "status", "failed to extract service and method", "err", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
 // UnaryREDInterceptor returns a gRPC unary interceptor that records RED metrics.
-func UnaryREDInterceptor(red *strategy.RED) grpc.UnaryServerInterceptor {
+func UnaryREDInterceptor(red *strategy.RED, opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	o := newInterceptorOptions(opts)
+
 	return func(
 		ctx context.Context,
 		req interface{},
 		info *grpc.UnaryServerInfo,
 		handler grpc.UnaryHandler,
 	) (interface{}, error) {
-		start := time.Now()
+		start := o.clock.Now()
 
 		service, method, err := extractServiceMethod(info.FullMethod)
 		if err != nil {
-			return nil, err
+			return nil, invalidMethodError(o.logger, err)
 		}
 
 		// Record the request (Rate)
@@ -33,7 +107,9 @@ func UnaryREDInterceptor(red *strategy.RED) grpc.UnaryServerInterceptor {
 		resp, err := handler(ctx, req)
 
 		// Record duration
-		duration := time.Since(start).Seconds()
+		elapsed := o.clock.Now().Sub(start)
+		duration := elapsed.Seconds()
+		o.logSlow(service, method, elapsed, err)
 		if red.Duration.Histogram != nil {
 			red.Duration.Histogram.WithLabelValues(service, method).Observe(duration)
 		}
@@ -54,18 +130,20 @@ func UnaryREDInterceptor(red *strategy.RED) grpc.UnaryServerInterceptor {
 // StreamREDInterceptor returns a gRPC stream interceptor that records RED metrics.
 // Note: This only records the start of the stream as a request and the final status as an error if applicable.
 // True stream metrics often require more granular tracking (messages sent/received).
-func StreamREDInterceptor(red *strategy.RED) grpc.StreamServerInterceptor {
+func StreamREDInterceptor(red *strategy.RED, opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	o := newInterceptorOptions(opts)
+
 	return func(
 		srv interface{},
 		ss grpc.ServerStream,
 		info *grpc.StreamServerInfo,
 		handler grpc.StreamHandler,
 	) error {
-		start := time.Now()
+		start := o.clock.Now()
 
 		service, method, err := extractServiceMethod(info.FullMethod)
 		if err != nil {
-			return err
+			return invalidMethodError(o.logger, err)
 		}
 
 		// Record the request (Rate)
@@ -73,13 +151,11 @@ func StreamREDInterceptor(red *strategy.RED) grpc.StreamServerInterceptor {
 
 		// Call the handler
 		err = handler(srv, ss)
-		if err != nil {
-			st, _ := status.FromError(err)
-			red.Errors.WithLabelValues(st.Code().String()).Inc()
-		}
 
 		// Record duration
-		duration := time.Since(start).Seconds()
+		elapsed := o.clock.Now().Sub(start)
+		duration := elapsed.Seconds()
+		o.logSlow(service, method, elapsed, err)
 		if red.Duration.Histogram != nil {
 			red.Duration.Histogram.WithLabelValues(service, method).Observe(duration)
 		}
@@ -100,23 +176,23 @@ func StreamREDInterceptor(red *strategy.RED) grpc.StreamServerInterceptor {
 // Extract service and method from FullMethod (e.g., "/helloworld.Greeter/SayHello")
 func extractServiceMethod(fullMethod string) (string, string, error) {
 	if !strings.HasPrefix(fullMethod, "/") {
-		return "", "", fmt.Errorf("invalid gRPC method format: %s", fullMethod)
+		return "", "", fmt.Errorf("%w format: %s", ErrInvalidMethod, fullMethod)
 	}
 
 	lastSlash := strings.LastIndex(fullMethod, "/")
 	if lastSlash <= 0 {
-		return "", "", fmt.Errorf("invalid gRPC method format: %s", fullMethod)
+		return "", "", fmt.Errorf("%w format: %s", ErrInvalidMethod, fullMethod)
 	}
 
 	service := fullMethod[1:lastSlash]
 	method := fullMethod[lastSlash+1:]
 
 	if service == "" {
-		return "", "", fmt.Errorf("invalid gRPC method: service name missing in %s", fullMethod)
+		return "", "", fmt.Errorf("%w: service name missing in %s", ErrInvalidMethod, fullMethod)
 	}
 
 	if method == "" {
-		return "", "", fmt.Errorf("invalid gRPC method: method name missing in %s", fullMethod)
+		return "", "", fmt.Errorf("%w: method name missing in %s", ErrInvalidMethod, fullMethod)
 	}
 
 	return service, method, nil
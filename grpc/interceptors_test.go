@@ -1,13 +1,21 @@
 package grpc
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"log/slog"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/rabellamy/server/metrics"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestUnaryREDInterceptor(t *testing.T) {
@@ -18,6 +26,7 @@ func TestUnaryREDInterceptor(t *testing.T) {
 		fullMethod string
 		handler    grpc.UnaryHandler
 		wantErr    bool
+		wantCode   codes.Code
 	}{
 		"success": {
 			namespace:  "test_unary_success",
@@ -41,7 +50,8 @@ func TestUnaryREDInterceptor(t *testing.T) {
 			handler: func(ctx context.Context, req interface{}) (interface{}, error) {
 				return "ok", nil
 			},
-			wantErr: true,
+			wantErr:  true,
+			wantCode: codes.Internal,
 		},
 	}
 
@@ -52,7 +62,10 @@ func TestUnaryREDInterceptor(t *testing.T) {
 			red, err := metrics.NewRED(tt.namespace, "grpc", []string{"service", "method"}, []string{"service", "method"})
 			assert.NoError(t, err)
 
-			interceptor := UnaryREDInterceptor(red)
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			interceptor := UnaryREDInterceptor(red, WithLogger(logger))
 			info := &grpc.UnaryServerInfo{FullMethod: tt.fullMethod}
 			_, err = interceptor(context.Background(), nil, info, tt.handler)
 
@@ -61,6 +74,10 @@ func TestUnaryREDInterceptor(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 			}
+			if tt.wantCode == codes.Internal {
+				assert.Equal(t, codes.Internal, status.Code(err))
+				assert.Contains(t, logs.String(), "failed to extract service and method")
+			}
 		})
 	}
 }
@@ -73,6 +90,8 @@ func TestStreamREDInterceptor(t *testing.T) {
 		fullMethod string
 		handler    grpc.StreamHandler
 		wantErr    bool
+		wantCode   codes.Code
+		wantErrors float64
 	}{
 		"success": {
 			namespace:  "test_stream_success",
@@ -88,7 +107,8 @@ func TestStreamREDInterceptor(t *testing.T) {
 			handler: func(srv interface{}, stream grpc.ServerStream) error {
 				return errors.New("boom")
 			},
-			wantErr: true,
+			wantErr:    true,
+			wantErrors: 1,
 		},
 		"invalid method": {
 			namespace:  "test_stream_invalid",
@@ -96,7 +116,8 @@ func TestStreamREDInterceptor(t *testing.T) {
 			handler: func(srv interface{}, stream grpc.ServerStream) error {
 				return nil
 			},
-			wantErr: true,
+			wantErr:  true,
+			wantCode: codes.Internal,
 		},
 	}
 
@@ -107,7 +128,10 @@ func TestStreamREDInterceptor(t *testing.T) {
 			red, err := metrics.NewRED(tt.namespace, "grpc", []string{"service", "method"}, []string{"service", "method"})
 			assert.NoError(t, err)
 
-			interceptor := StreamREDInterceptor(red)
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			interceptor := StreamREDInterceptor(red, WithLogger(logger))
 			info := &grpc.StreamServerInfo{FullMethod: tt.fullMethod}
 			err = interceptor(nil, nil, info, tt.handler)
 
@@ -116,6 +140,13 @@ func TestStreamREDInterceptor(t *testing.T) {
 			} else {
 				assert.NoError(t, err)
 			}
+			if tt.wantCode == codes.Internal {
+				assert.Equal(t, codes.Internal, status.Code(err))
+				assert.Contains(t, logs.String(), "failed to extract service and method")
+			}
+
+			// A failed stream is counted as exactly one error
+			assert.Equal(t, tt.wantErrors, testutil.ToFloat64(red.Errors.WithLabelValues(codes.Unknown.String())))
 		})
 	}
 }
@@ -192,7 +223,7 @@ func TestExtractServiceMethod(t *testing.T) {
 
 			svc, mthd, err := extractServiceMethod(tt.fullMethod)
 			if tt.wantErr {
-				assert.Error(t, err)
+				assert.ErrorIs(t, err, ErrInvalidMethod)
 				if tt.wantErrMsg != "" {
 					assert.Equal(t, tt.wantErrMsg, err.Error())
 				}
@@ -204,3 +235,150 @@ func TestExtractServiceMethod(t *testing.T) {
 		})
 	}
 }
+
+// fakeClock is a metrics.Clock that only moves when advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestREDInterceptorClock(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		stream    bool
+		elapsed   time.Duration
+	}{
+		"unary": {
+			namespace: "test_clock_unary",
+			elapsed:   1500 * time.Millisecond,
+		},
+		"stream": {
+			namespace: "test_clock_stream",
+			stream:    true,
+			elapsed:   2 * time.Second,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			red, err := metrics.NewRED(tt.namespace, "grpc", []string{"service", "method"}, []string{"service", "method"})
+			assert.NoError(t, err)
+
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			fullMethod := "/helloworld.Greeter/SayHello"
+
+			if tt.stream {
+				interceptor := StreamREDInterceptor(red, WithClock(clock))
+				info := &grpc.StreamServerInfo{FullMethod: fullMethod}
+				err = interceptor(nil, nil, info, func(srv interface{}, stream grpc.ServerStream) error {
+					clock.Advance(tt.elapsed)
+					return nil
+				})
+			} else {
+				interceptor := UnaryREDInterceptor(red, WithClock(clock))
+				info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+				_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					clock.Advance(tt.elapsed)
+					return "ok", nil
+				})
+			}
+			assert.NoError(t, err)
+
+			var m dto.Metric
+			err = red.Duration.Histogram.WithLabelValues("helloworld.Greeter", "SayHello").(prometheus.Metric).Write(&m)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+			assert.Equal(t, tt.elapsed.Seconds(), m.GetHistogram().GetSampleSum())
+		})
+	}
+}
+
+func TestREDInterceptorSlowThreshold(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		stream    bool
+		threshold time.Duration
+		elapsed   time.Duration
+		wantLog   bool
+	}{
+		"slow unary": {
+			namespace: "test_slow_unary",
+			threshold: 100 * time.Millisecond,
+			elapsed:   250 * time.Millisecond,
+			wantLog:   true,
+		},
+		"slow stream": {
+			namespace: "test_slow_stream",
+			stream:    true,
+			threshold: 100 * time.Millisecond,
+			elapsed:   250 * time.Millisecond,
+			wantLog:   true,
+		},
+		"fast unary": {
+			namespace: "test_fast_unary",
+			threshold: 100 * time.Millisecond,
+			elapsed:   50 * time.Millisecond,
+		},
+		"disabled": {
+			namespace: "test_slow_disabled",
+			elapsed:   time.Hour,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			red, err := metrics.NewRED(tt.namespace, "grpc", []string{"service", "method"}, []string{"service", "method"})
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			opts := []InterceptorOption{
+				WithLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+				WithClock(clock),
+				WithSlowThreshold(tt.threshold),
+			}
+			fullMethod := "/helloworld.Greeter/SayHello"
+
+			if tt.stream {
+				interceptor := StreamREDInterceptor(red, opts...)
+				info := &grpc.StreamServerInfo{FullMethod: fullMethod}
+				err = interceptor(nil, nil, info, func(srv interface{}, stream grpc.ServerStream) error {
+					clock.Advance(tt.elapsed)
+					return nil
+				})
+			} else {
+				interceptor := UnaryREDInterceptor(red, opts...)
+				info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+				_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					clock.Advance(tt.elapsed)
+					return "ok", nil
+				})
+			}
+			assert.NoError(t, err)
+
+			if tt.wantLog {
+				assert.Contains(t, buf.String(), "level=WARN")
+				assert.Contains(t, buf.String(), "slow=true")
+				assert.Contains(t, buf.String(), "method=SayHello")
+				assert.Contains(t, buf.String(), "duration="+tt.elapsed.String())
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
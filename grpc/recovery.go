@@ -0,0 +1,90 @@
+package grpc
+
+import (
+	"context"
+	"runtime/debug"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// PanicHandler is called with the value recovered from a panicking RPC
+// handler and the stack at the point of the panic, e.g. to report it to an
+// error tracker.
+type PanicHandler func(ctx context.Context, recovered any, stack []byte)
+
+// WithPanicHandler sets a callback the recovery interceptors call before
+// returning the error for a recovered panic. A panic in the callback itself is
+// recovered and logged.
+func WithPanicHandler(onPanic PanicHandler) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.onPanic = onPanic
+	}
+}
+
+// UnaryRecoveryInterceptor returns a gRPC unary interceptor that recovers
+// panics in the handler, logs them and returns codes.Internal.
+func UnaryRecoveryInterceptor(opts ...InterceptorOption) grpc.UnaryServerInterceptor {
+	o := newInterceptorOptions(opts)
+
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (resp interface{}, err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = o.recovered(ctx, info.FullMethod, recovered)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecoveryInterceptor returns a gRPC stream interceptor that recovers
+// panics in the handler, logs them and returns codes.Internal.
+func StreamRecoveryInterceptor(opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	o := newInterceptorOptions(opts)
+
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) (err error) {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				err = o.recovered(ss.Context(), info.FullMethod, recovered)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs a recovered panic, calls the panic handler and converts the
+// panic into the status returned to the client.
+func (o interceptorOptions) recovered(ctx context.Context, fullMethod string, recovered any) error {
+	stack := debug.Stack()
+	o.logger.Error("interceptor", "status", "panic recovered", "method", fullMethod, "panic", recovered, "stack", string(stack))
+	o.callOnPanic(ctx, recovered, stack)
+
+	return status.Error(codes.Internal, "internal error")
+}
+
+func (o interceptorOptions) callOnPanic(ctx context.Context, recovered any, stack []byte) {
+	if o.onPanic == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			o.logger.Error("interceptor", "status", "panic handler panicked", "panic", r)
+		}
+	}()
+
+	o.onPanic(ctx, recovered, stack)
+}
@@ -0,0 +1,230 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoveryInterceptors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		stream     bool
+		panics     bool
+		onPanic    func(t *testing.T, called *bool) PanicHandler
+		wantCode   codes.Code
+		wantCalled bool
+		wantLog    string
+	}{
+		"unary no panic": {
+			wantCode: codes.OK,
+		},
+		"unary panic with callback": {
+			panics: true,
+			onPanic: func(t *testing.T, called *bool) PanicHandler {
+				return func(ctx context.Context, recovered any, stack []byte) {
+					*called = true
+					assert.Equal(t, "boom", recovered)
+					assert.Contains(t, string(stack), "recovery_test.go")
+				}
+			},
+			wantCode:   codes.Internal,
+			wantCalled: true,
+			wantLog:    "panic recovered",
+		},
+		"stream panic with callback": {
+			stream: true,
+			panics: true,
+			onPanic: func(t *testing.T, called *bool) PanicHandler {
+				return func(ctx context.Context, recovered any, stack []byte) {
+					*called = true
+					assert.Equal(t, "boom", recovered)
+					assert.Contains(t, string(stack), "recovery_test.go")
+				}
+			},
+			wantCode:   codes.Internal,
+			wantCalled: true,
+			wantLog:    "panic recovered",
+		},
+		"panicking callback": {
+			panics: true,
+			onPanic: func(t *testing.T, called *bool) PanicHandler {
+				return func(ctx context.Context, recovered any, stack []byte) {
+					*called = true
+					panic("callback boom")
+				}
+			},
+			wantCode:   codes.Internal,
+			wantCalled: true,
+			wantLog:    "panic handler panicked",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			var called bool
+			opts := []InterceptorOption{WithLogger(slog.New(slog.NewTextHandler(&buf, nil)))}
+			if tt.onPanic != nil {
+				opts = append(opts, WithPanicHandler(tt.onPanic(t, &called)))
+			}
+
+			var err error
+			if tt.stream {
+				interceptor := StreamRecoveryInterceptor(opts...)
+				info := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+				err = interceptor(nil, &mockServerStream{ctx: context.Background()}, info, func(srv interface{}, stream grpc.ServerStream) error {
+					if tt.panics {
+						panic("boom")
+					}
+					return nil
+				})
+			} else {
+				interceptor := UnaryRecoveryInterceptor(opts...)
+				info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+				_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					if tt.panics {
+						panic("boom")
+					}
+					return "ok", nil
+				})
+			}
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCalled, called)
+			if tt.wantLog != "" {
+				assert.Contains(t, buf.String(), tt.wantLog)
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+// mockServerStream is a grpc.ServerStream that only carries a context.
+type mockServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *mockServerStream) Context() context.Context {
+	return s.ctx
+}
+
+type panickingGreeter struct {
+	helloworld.UnimplementedGreeterServer
+}
+
+func (panickingGreeter) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	panic("boom")
+}
+
+func TestNewServerRecovery(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		disableRecovery bool
+		wantCode        codes.Code
+		wantPropagated  bool
+	}{
+		"panics recovered": {
+			wantCode: codes.Internal,
+		},
+		"recovery disabled": {
+			disableRecovery: true,
+			wantCode:        codes.Unknown,
+			wantPropagated:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       "test_new_server_recovery",
+				APIHost:         addr,
+				ShutdownTimeout: 5 * time.Second,
+				DisableRecovery: tt.disableRecovery,
+				Registry:        prometheus.NewRegistry(),
+			}
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			// The outermost interceptor catches panics that propagate out of
+			// the server's chain, so the test process survives them
+			var propagated atomic.Bool
+			outer := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+				defer func() {
+					if recover() != nil {
+						propagated.Store(true)
+						err = status.Error(codes.Unknown, "propagated")
+					}
+				}()
+
+				return handler(ctx, req)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			register := func(s *grpc.Server) error {
+				helloworld.RegisterGreeterServer(s, panickingGreeter{})
+				return nil
+			}
+			server, err := NewServer(ctx, config, register, logger, grpc.ChainUnaryInterceptor(outer))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantPropagated, propagated.Load())
+
+			if !tt.wantPropagated {
+				assert.Contains(t, logs.String(), `"status":"panic recovered"`)
+				// Recovery sits inside RED, so the panic is counted as an
+				// Internal error
+				assert.Equal(t, 1.0, testutil.ToFloat64(server.RED().Errors.WithLabelValues(codes.Internal.String())))
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
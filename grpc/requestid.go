@@ -0,0 +1,108 @@
+package grpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/rabellamy/server/internal/ctxkey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDKey is the metadata key carrying a call's request ID, in the
+// incoming metadata and the response trailer. It matches the X-Request-Id
+// header gateways forward from HTTP.
+const RequestIDKey = "x-request-id"
+
+// maxRequestIDLen bounds request IDs accepted from clients, so a client
+// can't make the server echo arbitrarily large metadata.
+const maxRequestIDLen = 128
+
+// requestIDKey is the context key under which the request ID interceptors
+// store the call's request ID.
+var requestIDKey = ctxkey.New[string]("request id")
+
+// RequestIDFromContext returns the request ID the request ID interceptors
+// stored in ctx, and whether there was one.
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	return requestIDKey.Value(ctx)
+}
+
+// requestID returns the request ID from the incoming metadata in ctx, or a
+// new one when the client didn't send a valid one.
+func requestID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDKey); len(ids) > 0 && validRequestID(ids[0]) {
+			return ids[0]
+		}
+	}
+
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// validRequestID reports whether id is non-empty, bounded and printable
+// ASCII, so it is safe to log and echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// UnaryRequestIDInterceptor returns a gRPC unary interceptor that reads the
+// request ID from the incoming metadata, generating one when there is none,
+// stores it in the context for RequestIDFromContext and returns it in the
+// response trailer.
+func UnaryRequestIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		id := requestID(ctx)
+		// SetTrailer only fails outside of a server call
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(RequestIDKey, id))
+
+		return handler(requestIDKey.WithValue(ctx, id), req)
+	}
+}
+
+// StreamRequestIDInterceptor returns a gRPC stream interceptor that reads or
+// generates the stream's request ID, stores it in the stream's context and
+// returns it in the response trailer.
+func StreamRequestIDInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		id := requestID(ss.Context())
+		ss.SetTrailer(metadata.Pairs(RequestIDKey, id))
+
+		return handler(srv, &requestIDStream{
+			ServerStream: ss,
+			ctx:          requestIDKey.WithValue(ss.Context(), id),
+		})
+	}
+}
+
+// requestIDStream overrides a stream's context to carry its request ID.
+type requestIDStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *requestIDStream) Context() context.Context {
+	return s.ctx
+}
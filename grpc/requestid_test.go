@@ -0,0 +1,168 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestIDMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace         string
+		requestIDMetadata bool
+		incoming          string
+		want              string
+		wantGenerated     bool
+	}{
+		"passed through": {
+			namespace:         "test_request_id_passed",
+			requestIDMetadata: true,
+			incoming:          "req-123",
+			want:              "req-123",
+		},
+		"generated": {
+			namespace:         "test_request_id_generated",
+			requestIDMetadata: true,
+			wantGenerated:     true,
+		},
+		"invalid is replaced": {
+			namespace:         "test_request_id_invalid",
+			requestIDMetadata: true,
+			incoming:          strings.Repeat("a", maxRequestIDLen+1),
+			wantGenerated:     true,
+		},
+		"disabled": {
+			namespace: "test_request_id_disabled",
+			incoming:  "req-123",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:         tt.namespace,
+				APIHost:           addr,
+				MetricsHost:       "127.0.0.1:0",
+				ShutdownTimeout:   5 * time.Second,
+				RequestIDMetadata: tt.requestIDMetadata,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			assert.NoError(t, err)
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			callCtx := context.Background()
+			if tt.incoming != "" {
+				callCtx = metadata.AppendToOutgoingContext(callCtx, RequestIDKey, tt.incoming)
+			}
+
+			var trailer metadata.MD
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(callCtx, &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer))
+			assert.NoError(t, err)
+
+			got := trailer.Get(RequestIDKey)
+			switch {
+			case tt.wantGenerated:
+				if assert.Len(t, got, 1) {
+					assert.Regexp(t, `^[0-9a-f]{32}$`, got[0])
+				}
+			case tt.want != "":
+				assert.Equal(t, []string{tt.want}, got)
+			default:
+				assert.Empty(t, got)
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
+
+// trailerStream is a grpc.ServerStream that records the trailer set on it.
+type trailerStream struct {
+	mockServerStream
+	trailer metadata.MD
+}
+
+func (s *trailerStream) SetTrailer(md metadata.MD) {
+	s.trailer = metadata.Join(s.trailer, md)
+}
+
+func TestRequestIDFromContext(t *testing.T) {
+	t.Parallel()
+
+	incoming := metadata.NewIncomingContext(context.Background(), metadata.Pairs(RequestIDKey, "req-456"))
+
+	t.Run("unary", func(t *testing.T) {
+		t.Parallel()
+
+		var got string
+		info := &grpc.UnaryServerInfo{FullMethod: "/helloworld.Greeter/SayHello"}
+		_, err := UnaryRequestIDInterceptor()(incoming, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			got, _ = RequestIDFromContext(ctx)
+			return nil, nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "req-456", got)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+
+		var got string
+		ss := &trailerStream{mockServerStream: mockServerStream{ctx: incoming}}
+		info := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/SayHelloStream"}
+		err := StreamRequestIDInterceptor()(nil, ss, info, func(srv interface{}, stream grpc.ServerStream) error {
+			got, _ = RequestIDFromContext(stream.Context())
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "req-456", got)
+		assert.Equal(t, []string{"req-456"}, ss.trailer.Get(RequestIDKey))
+	})
+
+	t.Run("no interceptor", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := RequestIDFromContext(context.Background())
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRequiredMetadataSkip lists the services NewServer exempts from
+// required metadata: health checks and reflection come from tooling that
+// doesn't know about the API's contract.
+var DefaultRequiredMetadataSkip = []string{
+	"grpc.health.v1.Health",
+	"grpc.reflection.v1.ServerReflection",
+	"grpc.reflection.v1alpha.ServerReflection",
+}
+
+// requiredMetadata rejects calls missing any of a set of metadata keys.
+type requiredMetadata struct {
+	keys []string
+	skip map[string]struct{}
+}
+
+// newRequiredMetadata returns a requiredMetadata for keys. skip holds full
+// method names, e.g. "/helloworld.Greeter/SayHello", or service names, e.g.
+// "helloworld.Greeter", whose calls are let through.
+func newRequiredMetadata(keys, skip []string) *requiredMetadata {
+	r := &requiredMetadata{skip: make(map[string]struct{}, len(skip))}
+	for _, key := range keys {
+		r.keys = append(r.keys, strings.ToLower(key))
+	}
+	for _, s := range skip {
+		r.skip[s] = struct{}{}
+	}
+
+	return r
+}
+
+// check returns an InvalidArgument error naming the keys missing from ctx's
+// incoming metadata.
+func (r *requiredMetadata) check(ctx context.Context, fullMethod string) error {
+	if _, ok := r.skip[fullMethod]; ok {
+		return nil
+	}
+	if service, _, err := extractServiceMethod(fullMethod); err == nil {
+		if _, ok := r.skip[service]; ok {
+			return nil
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var missing []string
+	for _, key := range r.keys {
+		if len(md.Get(key)) == 0 {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 {
+		return status.Errorf(codes.InvalidArgument, "missing required metadata: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// UnaryRequiredMetadataInterceptor returns a gRPC unary interceptor that
+// rejects calls missing any of keys from their metadata with
+// codes.InvalidArgument. Calls to the methods or services in skip are let
+// through.
+func UnaryRequiredMetadataInterceptor(keys, skip []string) grpc.UnaryServerInterceptor {
+	r := newRequiredMetadata(keys, skip)
+
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if err := r.check(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamRequiredMetadataInterceptor returns a gRPC stream interceptor that
+// rejects calls missing any of keys from their metadata with
+// codes.InvalidArgument. Calls to the methods or services in skip are let
+// through.
+func StreamRequiredMetadataInterceptor(keys, skip []string) grpc.StreamServerInterceptor {
+	r := newRequiredMetadata(keys, skip)
+
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if err := r.check(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+
+		return handler(srv, ss)
+	}
+}
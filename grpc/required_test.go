@@ -0,0 +1,170 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRequiredMetadataInterceptors(t *testing.T) {
+	t.Parallel()
+
+	const sayHello = "/helloworld.Greeter/SayHello"
+	keys := []string{"x-api-version", "X-Request-ID"}
+	skip := []string{"/helloworld.Greeter/Ping", "grpc.health.v1.Health"}
+
+	tests := map[string]struct {
+		stream   bool
+		method   string
+		md       metadata.MD
+		wantCode codes.Code
+		wantMsg  string
+	}{
+		"unary all present": {
+			method:   sayHello,
+			md:       metadata.Pairs("x-api-version", "2", "x-request-id", "abc"),
+			wantCode: codes.OK,
+		},
+		"unary one missing": {
+			method:   sayHello,
+			md:       metadata.Pairs("x-api-version", "2"),
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "missing required metadata: x-request-id",
+		},
+		"unary no metadata": {
+			method:   sayHello,
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "missing required metadata: x-api-version, x-request-id",
+		},
+		"unary skipped method": {
+			method:   "/helloworld.Greeter/Ping",
+			wantCode: codes.OK,
+		},
+		"unary skipped service": {
+			method:   "/grpc.health.v1.Health/Check",
+			wantCode: codes.OK,
+		},
+		"stream all present": {
+			stream:   true,
+			method:   sayHello,
+			md:       metadata.Pairs("x-api-version", "2", "x-request-id", "abc"),
+			wantCode: codes.OK,
+		},
+		"stream one missing": {
+			stream:   true,
+			method:   sayHello,
+			md:       metadata.Pairs("x-request-id", "abc"),
+			wantCode: codes.InvalidArgument,
+			wantMsg:  "missing required metadata: x-api-version",
+		},
+		"stream skipped service": {
+			stream:   true,
+			method:   "/grpc.health.v1.Health/Watch",
+			wantCode: codes.OK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+
+			called := false
+			var err error
+			if tt.stream {
+				info := &grpc.StreamServerInfo{FullMethod: tt.method}
+				err = StreamRequiredMetadataInterceptor(keys, skip)(nil, &mockServerStream{ctx: ctx}, info, func(srv interface{}, stream grpc.ServerStream) error {
+					called = true
+					return nil
+				})
+			} else {
+				info := &grpc.UnaryServerInfo{FullMethod: tt.method}
+				_, err = UnaryRequiredMetadataInterceptor(keys, skip)(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					called = true
+					return nil, nil
+				})
+			}
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			assert.Equal(t, tt.wantCode == codes.OK, called)
+			if tt.wantMsg != "" {
+				assert.Equal(t, tt.wantMsg, status.Convert(err).Message())
+			}
+		})
+	}
+}
+
+func TestRequiredMetadata(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:        "test_required_metadata",
+		APIHost:          addr,
+		MetricsHost:      "127.0.0.1:0",
+		ShutdownTimeout:  5 * time.Second,
+		RequiredMetadata: []string{"x-api-version"},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	register := func(s *grpc.Server) error {
+		helloworld.RegisterGreeterServer(s, greeterServer{})
+		return nil
+	}
+	server, err := NewServer(ctx, config, register, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	greeter := helloworld.NewGreeterClient(conn)
+	_, err = greeter.SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	withVersion := metadata.AppendToOutgoingContext(context.Background(), "x-api-version", "1")
+	_, err = greeter.SayHello(withVersion, &helloworld.HelloRequest{Name: "world"})
+	assert.NoError(t, err)
+
+	// Health checks are exempt by default
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
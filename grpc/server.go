@@ -2,86 +2,372 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	grpc_prometheus "github.com/grpc-ecosystem/go-grpc-prometheus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/rabellamy/server/debug"
+	"github.com/rabellamy/server/internal/background"
+	"github.com/rabellamy/server/internal/listener"
+	"github.com/rabellamy/server/internal/logattrs"
+	"github.com/rabellamy/server/internal/tlsutil"
 	"github.com/rabellamy/server/metrics"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/grpclog"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 )
 
+// ErrAlreadyRunning is returned by Run when the server has already been run.
+var ErrAlreadyRunning = errors.New("server already running")
+
 type Server struct {
 	grpcServer    *grpc.Server
-	healthServer  *health.Server
+	healthServer  *healthServer
+	services      []string
+	activeRPCs    *activeRPCs
+	drainer       *drainer
+	background    *background.Group
+	shutdownTime  prometheus.Gauge
+	red           *strategy.RED
+	registerer    prometheus.Registerer
+	started       atomic.Bool
 	metricsServer http.Server
+	debugServer   http.Server
 	ctx           context.Context
 	logger        *slog.Logger
 	config        Config
 }
 
-type RegisterFunc func(*grpc.Server)
+// RegisterFunc registers services with the gRPC server. An error, e.g. from a
+// service whose dependencies failed to initialize, makes NewServer fail
+// rather than serve with some services missing.
+type RegisterFunc func(*grpc.Server) error
+
+// Register adapts a registration that can't fail to a RegisterFunc, for
+// registrations written before RegisterFunc returned an error:
+//
+//	grpc.NewServer(ctx, config, grpc.Register(register), logger)
+func Register(register func(*grpc.Server)) RegisterFunc {
+	return func(s *grpc.Server) error {
+		register(s)
+		return nil
+	}
+}
+
+// keepaliveOptions translates the keepalive settings in config into server
+// options. Zero values keep gRPC's defaults, and no option is added when
+// nothing is set.
+func keepaliveOptions(config Config) []grpc.ServerOption {
+	var opts []grpc.ServerOption
+
+	params := keepalive.ServerParameters{
+		MaxConnectionIdle:     config.MaxConnectionIdle,
+		MaxConnectionAge:      config.MaxConnectionAge,
+		MaxConnectionAgeGrace: config.MaxConnectionAgeGrace,
+		Time:                  config.KeepaliveTime,
+		Timeout:               config.KeepaliveTimeout,
+	}
+	if params != (keepalive.ServerParameters{}) {
+		opts = append(opts, grpc.KeepaliveParams(params))
+	}
+
+	policy := keepalive.EnforcementPolicy{
+		MinTime:             config.KeepaliveMinTime,
+		PermitWithoutStream: config.KeepaliveWithoutStream,
+	}
+	if policy != (keepalive.EnforcementPolicy{}) {
+		opts = append(opts, grpc.KeepaliveEnforcementPolicy(policy))
+	}
+
+	return opts
+}
+
+// RegisterAll combines several RegisterFuncs into a single RegisterFunc that
+// calls them in order, stopping at the first error. This lets separately
+// built service modules each contribute their own registration.
+func RegisterAll(funcs ...RegisterFunc) RegisterFunc {
+	return func(s *grpc.Server) error {
+		for _, register := range funcs {
+			if register == nil {
+				continue
+			}
+			if err := register(s); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
 
 func NewServer(ctx context.Context, config Config, register RegisterFunc, logger *slog.Logger, opts ...grpc.ServerOption) (*Server, error) {
-	// Enable gRPC metrics
-	grpcMetrics := grpc_prometheus.NewServerMetrics()
+	logger = logattrs.With(logger, config.LogAttrs)
+
+	// Slow call logging is done by the RED interceptors, which DisableMetrics
+	// leaves out, so it would otherwise be silently dropped
+	if config.DisableMetrics && config.SlowRequestThreshold > 0 {
+		return nil, errors.New("DisableMetrics can't be combined with SlowRequestThreshold")
+	}
 
-	// Custom RED interceptors using promstrap
-	red, err := metrics.NewRED(config.Namespace, "grpc", []string{"service", "method"}, []string{"service", "method"})
+	// grpclog is global, so it has to be routed before any gRPC server or
+	// client is created
+	if config.GRPCLogs {
+		grpclog.SetLoggerV2(NewGRPCLogger(logger))
+	}
+
+	network, err := listener.Network(config.ListenNetwork)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create RED metrics: %w", err)
+		return nil, err
 	}
-	if err := red.Register(); err != nil {
-		return nil, fmt.Errorf("failed to register RED metrics: %w", err)
+	config.ListenNetwork = network
+
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
 	}
 
-	// Default interceptors
-	opts = append(opts,
-		grpc.ChainUnaryInterceptor(
+	order, err := parseShutdownOrder(config.ShutdownOrder)
+	if err != nil {
+		return nil, err
+	}
+	config.ShutdownOrder = order
+
+	// ListenNetwork only applies to the API listener; the metrics and debug
+	// servers always listen on DefaultNetwork
+	hosts := []listener.Addr{{Name: "api", Address: config.APIHost, Network: network}}
+	if !config.DisableMetricsServer {
+		hosts = append(hosts, listener.Addr{Name: "metrics", Address: config.MetricsHost, Network: listener.DefaultNetwork})
+	}
+	if config.EnableDebug {
+		hosts = append(hosts, listener.Addr{Name: "debug", Address: config.DebugHost, Network: listener.DefaultNetwork})
+	}
+	if err := listener.CheckConflicts(hosts...); err != nil {
+		return nil, err
+	}
+
+	mainTLS, err := tlsutil.Load(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("main server: %w", err)
+	}
+
+	metricsTLS, err := tlsutil.Load(config.MetricsTLSCertFile, config.MetricsTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("metrics server: %w", err)
+	}
+	if metricsTLS == nil && config.MetricsInheritTLS {
+		metricsTLS = mainTLS
+	}
+
+	// Client certificates are only required of gRPC clients, not of
+	// scrapers of inherited metrics TLS
+	mainTLS, err = tlsutil.RequireClientCerts(mainTLS, config.TLSClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("main server: %w", err)
+	}
+
+	reg := metrics.Registerer(config.Registry)
+
+	var (
+		unary  []grpc.UnaryServerInterceptor
+		stream []grpc.StreamServerInterceptor
+	)
+
+	// Request IDs come first, so every other interceptor can read them
+	if config.RequestIDMetadata {
+		unary = append(unary, UnaryRequestIDInterceptor())
+		stream = append(stream, StreamRequestIDInterceptor())
+	}
+
+	// With metrics disabled the metric interceptors aren't installed at all,
+	// saving their per-call clock reads and label lookups
+	var (
+		grpcMetrics *grpc_prometheus.ServerMetrics
+		red         *strategy.RED
+	)
+	if !config.DisableMetrics {
+		// Enable gRPC metrics
+		grpcMetrics = grpc_prometheus.NewServerMetrics()
+
+		// Custom RED interceptors using promstrap
+		red, err = metrics.NewRED(config.Namespace, "grpc", []string{"service", "method"}, []string{"service", "method"},
+			metrics.WithNativeHistogram(config.NativeHistogramBucketFactor),
+			metrics.WithConstLabels(config.MetricConstLabels),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create RED metrics: %w", err)
+		}
+
+		unary = append(unary,
 			grpcMetrics.UnaryServerInterceptor(),
-			UnaryREDInterceptor(red),
-		),
-		grpc.ChainStreamInterceptor(
+			UnaryREDInterceptor(red, WithLogger(logger), WithSlowThreshold(config.SlowRequestThreshold)),
+		)
+		stream = append(stream,
 			grpcMetrics.StreamServerInterceptor(),
-			StreamREDInterceptor(red),
-		),
+			StreamREDInterceptor(red, WithLogger(logger), WithSlowThreshold(config.SlowRequestThreshold)),
+		)
+	}
+
+	// Recover inside RED, so panics are recorded as Internal errors
+	if !config.DisableRecovery {
+		unary = append(unary, UnaryRecoveryInterceptor(WithLogger(logger), WithPanicHandler(config.OnPanic)))
+		stream = append(stream, StreamRecoveryInterceptor(WithLogger(logger), WithPanicHandler(config.OnPanic)))
+	}
+
+	// Map errors closest to the handler, so metrics record the mapped codes
+	if config.ErrorMapper != nil {
+		unary = append(unary, UnaryErrorMapperInterceptor(config.ErrorMapper))
+		stream = append(stream, StreamErrorMapperInterceptor(config.ErrorMapper))
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
 	)
 
+	if config.TraceIDMetadata {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryTraceIDInterceptor()),
+			grpc.ChainStreamInterceptor(StreamTraceIDInterceptor()),
+		)
+	}
+
+	// Reject new calls to drain-last methods once shutdown begins
+	var drain *drainer
+	if len(config.DrainLastMethods) > 0 {
+		drain = newDrainer(config.DrainLastMethods)
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(drain.unaryInterceptor()),
+			grpc.ChainStreamInterceptor(drain.streamInterceptor()),
+		)
+	}
+
+	// Enforce the API's metadata contract at the edge
+	if len(config.RequiredMetadata) > 0 {
+		skip := append(slices.Clone(DefaultRequiredMetadataSkip), config.RequiredMetadataSkip...)
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryRequiredMetadataInterceptor(config.RequiredMetadata, skip)),
+			grpc.ChainStreamInterceptor(StreamRequiredMetadataInterceptor(config.RequiredMetadata, skip)),
+		)
+	}
+
+	// Count in-flight RPCs to report shutdown progress
+	active := &activeRPCs{}
+	opts = append(opts, grpc.StatsHandler(active))
+
+	// Bound how long a new connection may take to complete its handshake
+	if config.ConnectionTimeout > 0 {
+		opts = append(opts, grpc.ConnectionTimeout(config.ConnectionTimeout))
+	}
+
+	opts = append(opts, keepaliveOptions(config)...)
+
+	// Bound the size of request headers, guarding against header bombs
+	if config.MaxHeaderListSize > 0 {
+		opts = append(opts, grpc.MaxHeaderListSize(config.MaxHeaderListSize))
+	}
+
+	if mainTLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(mainTLS)))
+	}
+
 	s := grpc.NewServer(opts...)
 
 	// Register services
 	if register != nil {
-		register(s)
+		if err := register(s); err != nil {
+			return nil, fmt.Errorf("failed to register services: %w", err)
+		}
+	}
+
+	// Metrics are registered only once the services are, so a NewServer
+	// failing in register can be retried against the same registry
+	if red != nil {
+		if err := metrics.Register(reg, red); err != nil {
+			return nil, fmt.Errorf("failed to register RED metrics: %w", err)
+		}
 	}
 
 	// Register reflection for debugging
-	reflection.Register(s)
+	reflectionEnabled := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: config.Namespace,
+		Subsystem: "grpc",
+		Name:      "reflection_enabled",
+		Help:      "Whether gRPC server reflection is enabled (1) or disabled (0)",
+	})
+	if err := reg.Register(reflectionEnabled); err != nil {
+		return nil, fmt.Errorf("failed to register reflection metric: %w", err)
+	}
+
+	if !config.DisableReflection {
+		reflection.Register(s)
+		reflectionEnabled.Set(1)
+
+		// Reflection exposes the full service surface, which is rarely wanted outside development
+		if config.Build != "dev" {
+			logger.Warn("startup", "status", "grpc reflection enabled in non-dev build", "build", config.Build)
+		}
+	}
+
+	shutdownTime := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: config.Namespace,
+		Subsystem: "grpc",
+		Name:      "shutdown_duration_seconds",
+		Help:      "How long the last shutdown of the server took",
+	})
+	if err := reg.Register(shutdownTime); err != nil {
+		return nil, fmt.Errorf("failed to register shutdown metric: %w", err)
+	}
 
 	// Register health check service
-	healthServer := health.NewServer()
+	healthServer := newHealthServer()
 	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	// The server isn't serving until it runs, whatever the health server's
+	// default for the overall service
+	healthServer.SetServingStatus(config.HealthServiceName, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
 
 	// Initialize metrics
-	grpcMetrics.InitializeMetrics(s)
+	if grpcMetrics != nil {
+		grpcMetrics.InitializeMetrics(s)
+	}
 
 	// Metrics HTTP server
 	metricsMux := http.NewServeMux()
-	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.Handle("/metrics", metrics.HandlerWithOpts(config.Registry, promhttp.HandlerOpts{
+		Timeout:             config.MetricsScrapeTimeout,
+		MaxRequestsInFlight: config.MetricsMaxRequestsInFlight,
+	}))
 
 	server := &Server{
 		grpcServer:   s,
 		healthServer: healthServer,
+		activeRPCs:   active,
+		drainer:      drain,
+		background:   background.New(ctx, logger),
+		shutdownTime: shutdownTime,
+		red:          red,
+		registerer:   reg,
 		metricsServer: http.Server{
-			Addr:    config.MetricsHost,
-			Handler: metricsMux,
+			Addr:      config.MetricsHost,
+			Handler:   metricsMux,
+			TLSConfig: metricsTLS,
+		},
+		debugServer: http.Server{
+			Addr:    config.DebugHost,
+			Handler: debug.NewMux(),
 		},
 		logger: logger,
 		ctx:    ctx,
@@ -91,25 +377,142 @@ func NewServer(ctx context.Context, config Config, register RegisterFunc, logger
 	return server, nil
 }
 
+// RegisterService registers a service with register and reports it in the
+// health check service under name: NOT_SERVING until the server starts, then
+// SERVING until it shuts down. When register fails, the service isn't
+// reported and the error is returned. It panics if called after Run.
+func (s *Server) RegisterService(name string, register RegisterFunc) error {
+	s.mustNotBeStarted("RegisterService")
+
+	if register != nil {
+		if err := register(s.grpcServer); err != nil {
+			return fmt.Errorf("failed to register %s: %w", name, err)
+		}
+	}
+	s.services = append(s.services, name)
+	s.healthServer.SetServingStatus(name, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	return nil
+}
+
+// SetHealthStatus sets the status the health check service reports for
+// service, e.g. SERVICE_UNKNOWN for a service the app no longer provides or
+// NOT_SERVING while a dependency is down. It is safe to call at any time, but
+// starting and shutting down still set the status of the server's Name and of
+// services registered with RegisterService.
+func (s *Server) SetHealthStatus(service string, status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	s.healthServer.SetServingStatus(service, status)
+}
+
+// Go runs fn in a goroutine tied to the server's lifecycle, for background
+// loops such as cache refreshes or queue consumers. fn starts when the server
+// runs, or right away if it already is, and its context is cancelled once
+// the servers have shut down. Shutdown waits for fn to return, within
+// ShutdownTimeout, and Run returns any error fn returned.
+func (s *Server) Go(fn func(ctx context.Context) error) {
+	s.background.Go(fn)
+}
+
+// Registerer returns the registry the server's metrics are registered with,
+// Config.Registry or the Prometheus default registry, so apps can add their
+// own collectors and have them served on /metrics alongside the server's. It
+// is safe for concurrent use.
+func (s *Server) Registerer() prometheus.Registerer {
+	return s.registerer
+}
+
+// RED returns the server's RED metrics, e.g. to record calls handled outside
+// the interceptors, or nil when DisableMetrics is set. Its vectors are safe
+// for concurrent use.
+func (s *Server) RED() *strategy.RED {
+	return s.red
+}
+
+// GRPCServer returns the underlying gRPC server, e.g. to register more
+// services. It panics if called after Run, since gRPC exits the process when
+// a service is registered once serving has started. Use ServiceInfo to
+// inspect the services of a running server.
+func (s *Server) GRPCServer() *grpc.Server {
+	s.mustNotBeStarted("GRPCServer")
+
+	return s.grpcServer
+}
+
+// ServiceInfo returns the services registered with the gRPC server, as
+// grpc.Server.GetServiceInfo does. Unlike GRPCServer, it can be called at any
+// time.
+func (s *Server) ServiceInfo() map[string]grpc.ServiceInfo {
+	return s.grpcServer.GetServiceInfo()
+}
+
+// mustNotBeStarted panics if Run has been called.
+func (s *Server) mustNotBeStarted(method string) {
+	if s.started.Load() {
+		panic("grpc: " + method + " called after Run")
+	}
+}
+
+// setServingStatus sets the health status of the server, reported under
+// HealthServiceName, and of every service registered with RegisterService.
+func (s *Server) setServingStatus(status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	s.healthServer.SetServingStatus(s.config.HealthServiceName, status)
+	for _, name := range s.services {
+		s.healthServer.SetServingStatus(name, status)
+	}
+}
+
 func (s *Server) Run() error {
+	return s.RunUntil(context.Background(), nil)
+}
+
+// RunUntil runs the server like Run, but also shuts it down gracefully once
+// ctx is done or stop is closed, so embedding applications can trigger
+// shutdown from their own orchestration. A nil stop never fires.
+func (s *Server) RunUntil(ctx context.Context, stop <-chan struct{}) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(shutdown)
 
-	return s.run(shutdown)
+	return s.runUntil(ctx, stop, shutdown)
 }
 
 func (s *Server) run(shutdown <-chan os.Signal) error {
-	serverErrors := make(chan error, 2)
+	return s.runUntil(context.Background(), nil, shutdown)
+}
+
+func (s *Server) runUntil(ctx context.Context, stop <-chan struct{}, shutdown <-chan os.Signal) error {
+	if s.started.Swap(true) {
+		return ErrAlreadyRunning
+	}
+
+	serverErrors := make(chan error, 3)
+
+	s.background.Start()
+
+	// Cancelled once shutdown begins, so binds still being retried don't
+	// outlive it
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+
+	// Start debug server
+	if s.config.EnableDebug {
+		go func() {
+			s.logger.Info("startup", "status", "debug server started", "host", s.config.DebugHost)
+			serverErrors <- s.listenAndServe(listenCtx, &s.debugServer)
+		}()
+	}
 
 	// Start metrics server
-	go func() {
-		s.logger.Info("startup", "status", "metrics server started", "host", s.config.MetricsHost)
-		serverErrors <- s.metricsServer.ListenAndServe()
-	}()
+	if !s.config.DisableMetricsServer {
+		go func() {
+			s.logger.Info("startup", "status", "metrics server started", "host", s.config.MetricsHost)
+			serverErrors <- s.listenAndServe(listenCtx, &s.metricsServer)
+		}()
+	}
 
 	// Start gRPC server
 	go func() {
-		lis, err := net.Listen("tcp", s.config.APIHost)
+		lis, err := listener.Listen(listenCtx, s.config.ListenNetwork, s.config.APIHost, s.listenOptions())
 		if err != nil {
 			serverErrors <- fmt.Errorf("failed to listen on %s: %w", s.config.APIHost, err)
 			return
@@ -117,24 +520,98 @@ func (s *Server) run(shutdown <-chan os.Signal) error {
 		s.logger.Info("startup", "status", "grpc server started", "host", s.config.APIHost)
 
 		// Set serving status to SERVING
-		s.healthServer.SetServingStatus(s.config.Name, grpc_health_v1.HealthCheckResponse_SERVING)
+		s.setServingStatus(grpc_health_v1.HealthCheckResponse_SERVING)
 
 		serverErrors <- s.grpcServer.Serve(lis)
 	}()
 
 	select {
-	case <-s.ctx.Done():
-		// Create a new context for shutdown to allow for graceful stop even if the parent context is cancelled
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
-		defer cancel()
-		return s.shutdownServers(shutdownCtx, nil)
 	case err := <-serverErrors:
-		return fmt.Errorf("server error: %w", err)
+		stopListening()
+		ctx, cancel := s.shutdownContext()
+		defer cancel()
+		return errors.Join(fmt.Errorf("server error: %w", err), s.background.Stop(ctx))
 	case sig := <-shutdown:
-		ctx, cancel := context.WithTimeout(s.ctx, s.config.ShutdownTimeout)
+		stopListening()
+		ctx, cancel := s.shutdownContext()
 		defer cancel()
-		return s.shutdownServers(ctx, sig)
+		return s.shutdownAndSnapshot(ctx, sig)
+	case <-s.ctx.Done():
+	case <-ctx.Done():
+	case <-stop:
 	}
+
+	// Cancelled by the constructor context or stopped by the caller of
+	// RunUntil
+	stopListening()
+	shutdownCtx, cancel := s.shutdownContext()
+	defer cancel()
+
+	return s.shutdownAndSnapshot(shutdownCtx, nil)
+}
+
+// shutdownAndSnapshot shuts the servers down and writes the metrics snapshot.
+func (s *Server) shutdownAndSnapshot(ctx context.Context, signal os.Signal) error {
+	start := time.Now()
+	err := s.shutdownServers(ctx, signal)
+	// Background goroutines stop after the servers, as in-flight RPCs may
+	// still rely on them. ctx is already done when the servers used up the
+	// whole timeout, so they then get a deadline of their own.
+	bctx := ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		bctx, cancel = s.shutdownContext()
+		defer cancel()
+	}
+	if berr := s.background.Stop(bctx); berr != nil {
+		err = errors.Join(err, berr)
+	}
+	s.shutdownTime.Set(time.Since(start).Seconds())
+
+	if s.config.MetricsSnapshotFile != "" {
+		if serr := metrics.WriteSnapshot(s.config.Registry, s.config.MetricsSnapshotFile); serr != nil {
+			err = errors.Join(err, fmt.Errorf("metrics snapshot: %w", serr))
+		}
+	}
+
+	return err
+}
+
+// shutdownContext returns the context bounding a shutdown. It is derived from
+// context.Background rather than s.ctx so a parent context that is already
+// cancelled doesn't cut the graceful stop short.
+func (s *Server) shutdownContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+}
+
+func (s *Server) listenOptions() listener.Options {
+	return listener.Options{
+		ReusePort:    s.config.ReusePort,
+		Retries:      s.config.ListenRetries,
+		RetryBackoff: s.config.ListenRetryBackoff,
+		KeepAlive:    s.config.TCPKeepAlive,
+	}
+}
+
+// listenAndServe is like http.Server.ListenAndServe, but creates the
+// listener with the server's configured listener options, on DefaultNetwork
+// as ListenNetwork only applies to the gRPC listener. Cancelling ctx stops
+// bind retries. Servers with a TLSConfig are served over HTTPS.
+func (s *Server) listenAndServe(ctx context.Context, srv *http.Server) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	ln, err := listener.Listen(ctx, listener.DefaultNetwork, addr, s.listenOptions())
+	if err != nil {
+		return err
+	}
+
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
 }
 
 func (s *Server) shutdownServers(ctx context.Context, signal os.Signal) error {
@@ -145,36 +622,136 @@ func (s *Server) shutdownServers(ctx context.Context, signal os.Signal) error {
 		sig = signal.String()
 	}
 
-	s.logger.Info("shutdown", "server", "health", "status", "shutdown complete", "signal", sig)
+	// Quiet shutdowns log each server's steps at debug level and only the
+	// overall completion at info
+	level := slog.LevelInfo
+	if s.config.QuietShutdown {
+		level = slog.LevelDebug
+	}
+	step := func(server, status string) {
+		s.logger.Log(context.Background(), level, "shutdown", "server", server, "status", status, "signal", sig)
+	}
+
+	step("health", "shutdown complete")
+
+	if s.drainer != nil {
+		s.drainer.start()
+	}
 
 	// Set serving status to NOT_SERVING
-	s.healthServer.SetServingStatus(s.config.Name, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+	s.setServingStatus(grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	// End health Watch streams so they don't hold GracefulStop open
+	s.healthServer.endWatches()
+
+	// GracefulStop refuses every new call, so calls to the methods that
+	// aren't drained are still accepted for the drain window
+	if s.drainer != nil && s.config.DrainWindow > 0 {
+		step("grpc", "drain window started")
+		window := time.NewTimer(s.config.DrainWindow)
+		select {
+		case <-window.C:
+		case <-ctx.Done():
+		}
+		window.Stop()
+	}
+
+	// Shut an HTTP server down, forcing it closed when the deadline passes
+	stopHTTP := func(name string, srv *http.Server) error {
+		step(name, "shutdown started")
+		if err := srv.Shutdown(ctx); err != nil {
+			srv.Close()
+			return fmt.Errorf("%s server could not stop gracefully: %w", name, err)
+		}
+		step(name, "shutdown complete")
+		return nil
+	}
+	stopMetrics := func() error {
+		if s.config.DisableMetricsServer {
+			return nil
+		}
+		return stopHTTP("metrics", &s.metricsServer)
+	}
+
+	// A server that fails to stop gracefully doesn't keep the others running
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	record := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}
+	stopConcurrently := func(stop func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			record(stop())
+		}()
+	}
+
+	if s.config.ShutdownOrder == ShutdownMetricsFirst {
+		record(stopMetrics())
+	}
 
 	// GracefulStop for gRPC doesn't take a context, it waits indefinitely or until connections drain.
 	// To respect the shutdown timeout, we can wrap it in a goroutine/channel.
-	s.logger.Info("shutdown", "server", "grpc", "status", "shutting down started", "signal", sig)
+	step("grpc", "shutting down started")
 	stopped := make(chan struct{})
 	go func() {
 		s.grpcServer.GracefulStop()
 		close(stopped)
 	}()
 
-	// Shutdown metrics server
-	s.logger.Info("shutdown", "server", "metrics", "status", "shutdown started", "signal", sig)
-	if err := s.metricsServer.Shutdown(ctx); err != nil {
-		s.metricsServer.Close()
-		return fmt.Errorf("metrics server could not stop gracefully: %w", err)
+	// The HTTP servers stopping alongside the gRPC drain do so concurrently,
+	// so shutdown takes as long as the slowest of them rather than the sum
+	if s.config.ShutdownOrder == ShutdownConcurrent {
+		stopConcurrently(stopMetrics)
+	}
+	if s.config.EnableDebug {
+		stopConcurrently(func() error {
+			return stopHTTP("debug", &s.debugServer)
+		})
 	}
-	s.logger.Info("shutdown", "server", "metrics", "status", "shutdown complete", "signal", sig)
 
-	select {
-	case <-ctx.Done():
-		// Force stop if timeout exceeded
-		s.grpcServer.Stop()
-		return fmt.Errorf("grpc server shutdown timed out")
-	case <-stopped:
-		s.logger.Info("shutdown", "server", "grpc", "status", "graceful stop complete", "signal", sig)
+	record(s.awaitGracefulStop(ctx, stopped, sig))
+	wg.Wait()
+
+	switch s.config.ShutdownOrder {
+	case ShutdownMetricsFirst, ShutdownConcurrent:
+	default:
+		// Metrics stay up while the gRPC server drains, so the drain is
+		// captured
+		errs = append(errs, stopMetrics())
 	}
 
-	return nil
+	return errors.Join(errs...)
+}
+
+// awaitGracefulStop waits for the gRPC server's graceful stop to finish,
+// reporting progress, and force stops it when ctx is done.
+func (s *Server) awaitGracefulStop(ctx context.Context, stopped <-chan struct{}, sig string) error {
+	// Periodically report how many RPCs are still draining
+	var progress <-chan time.Time
+	if s.config.ShutdownProgressInterval > 0 {
+		ticker := time.NewTicker(s.config.ShutdownProgressInterval)
+		defer ticker.Stop()
+		progress = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Force stop if timeout exceeded
+			s.grpcServer.Stop()
+			return fmt.Errorf("grpc server shutdown timed out")
+		case <-progress:
+			s.logger.Info("shutdown", "server", "grpc", "status", "graceful stop in progress", "active_rpcs", s.activeRPCs.Load(), "signal", sig)
+		case <-stopped:
+			s.logger.Info("shutdown", "server", "grpc", "status", "graceful stop complete", "signal", sig)
+			return nil
+		}
+	}
 }
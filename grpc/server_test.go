@@ -1,20 +1,38 @@
 package grpc
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/rabellamy/server/internal/testcert"
+	"github.com/rabellamy/server/servertest"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/interop/grpc_testing"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestNewServer(t *testing.T) {
@@ -43,6 +61,35 @@ func TestNewServer(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		"native histograms": {
+			config: Config{
+				Namespace:                   "test_server_native_histogram",
+				NativeHistogramBucketFactor: 1.1,
+			},
+		},
+		"invalid native histogram bucket factor": {
+			config: Config{
+				Namespace:                   "test_server_invalid_bucket_factor",
+				NativeHistogramBucketFactor: 0.5,
+			},
+			wantErr: true,
+		},
+		"unsupported listen network": {
+			config: Config{
+				Namespace:     "test_server_listen_network",
+				ListenNetwork: "udp",
+			},
+			wantErr: true,
+		},
+		"api and debug hosts collide": {
+			config: Config{
+				Namespace:   "test_server_host_collision",
+				APIHost:     "0.0.0.0:50051",
+				DebugHost:   "0.0.0.0:50051",
+				EnableDebug: true,
+			},
+			wantErr: true,
+		},
 	}
 
 	for name, tt := range tests {
@@ -170,22 +217,103 @@ func TestRun(t *testing.T) {
 func TestRegister(t *testing.T) {
 	t.Parallel()
 
+	errNoDB := errors.New("no database")
+
+	tests := map[string]struct {
+		register  func(called *[]string) RegisterFunc
+		wantCalls []string
+		wantErr   error
+	}{
+		"registered": {
+			register: func(called *[]string) RegisterFunc {
+				return func(s *grpc.Server) error {
+					*called = append(*called, "greeter")
+					return nil
+				}
+			},
+			wantCalls: []string{"greeter"},
+		},
+		"registration fails": {
+			register: func(called *[]string) RegisterFunc {
+				return func(s *grpc.Server) error {
+					*called = append(*called, "greeter")
+					return errNoDB
+				}
+			},
+			wantCalls: []string{"greeter"},
+			wantErr:   errNoDB,
+		},
+		"registration that can't fail": {
+			register: func(called *[]string) RegisterFunc {
+				return Register(func(s *grpc.Server) {
+					*called = append(*called, "greeter")
+				})
+			},
+			wantCalls: []string{"greeter"},
+		},
+		"combined registration stops at first error": {
+			register: func(called *[]string) RegisterFunc {
+				return RegisterAll(
+					func(s *grpc.Server) error {
+						*called = append(*called, "greeter")
+						return errNoDB
+					},
+					func(s *grpc.Server) error {
+						*called = append(*called, "test")
+						return nil
+					},
+				)
+			},
+			wantCalls: []string{"greeter"},
+			wantErr:   errNoDB,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace: "test_register",
+				APIHost:   "localhost:0",
+				Registry:  prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			var called []string
+			server, err := NewServer(context.Background(), config, tt.register(&called), logger)
+			assert.Equal(t, tt.wantCalls, called)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, server)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRegisterRetry(t *testing.T) {
+	t.Parallel()
+
 	config := Config{
-		Namespace: "test_register",
+		Namespace: "test_register_retry",
 		APIHost:   "localhost:0",
+		Registry:  prometheus.NewRegistry(),
 	}
 	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
 
-	ctx := context.Background()
-
-	called := false
-	register := func(s *grpc.Server) {
-		called = true
+	errNoDB := errors.New("no database")
+	failing := func(s *grpc.Server) error {
+		return errNoDB
 	}
+	_, err := NewServer(context.Background(), config, failing, logger)
+	assert.ErrorIs(t, err, errNoDB)
 
-	_, err := NewServer(ctx, config, register, logger)
+	// A failed registration leaves no metrics behind to collide with
+	server, err := NewServer(context.Background(), config, Register(func(s *grpc.Server) {}), logger)
 	assert.NoError(t, err)
-	assert.True(t, called, "register function should have been called")
+	assert.NotNil(t, server)
 }
 
 func TestHealthCheck(t *testing.T) {
@@ -396,3 +524,1680 @@ func TestShutdownServers(t *testing.T) {
 		})
 	}
 }
+
+func TestReflection(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		build             string
+		disableReflection bool
+		wantWarn          bool
+		wantGauge         string
+	}{
+		"dev build": {
+			build:     "dev",
+			wantWarn:  false,
+			wantGauge: "1",
+		},
+		"prod build": {
+			build:     "prod",
+			wantWarn:  true,
+			wantGauge: "1",
+		},
+		"prod build with reflection disabled": {
+			build:             "prod",
+			disableReflection: true,
+			wantWarn:          false,
+			wantGauge:         "0",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Sanitize namespace for Prometheus
+			ns := "test_reflection_" + strings.ReplaceAll(name, " ", "_")
+			config := Config{
+				Namespace:         ns,
+				Build:             tt.build,
+				DisableReflection: tt.disableReflection,
+			}
+
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			_, err := NewServer(context.Background(), config, nil, logger)
+			assert.NoError(t, err)
+
+			if tt.wantWarn {
+				assert.Contains(t, logs.String(), "level=WARN")
+				assert.Contains(t, logs.String(), "grpc reflection enabled in non-dev build")
+			} else {
+				assert.NotContains(t, logs.String(), "level=WARN")
+			}
+
+			metricName := ns + "_grpc_reflection_enabled"
+			expected := fmt.Sprintf(`
+# HELP %[1]s Whether gRPC server reflection is enabled (1) or disabled (0)
+# TYPE %[1]s gauge
+%[1]s %[2]s
+`, metricName, tt.wantGauge)
+			err = testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected), metricName)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+type greeterServer struct {
+	helloworld.UnimplementedGreeterServer
+}
+
+func (greeterServer) SayHello(ctx context.Context, in *helloworld.HelloRequest) (*helloworld.HelloReply, error) {
+	return &helloworld.HelloReply{Message: "Hello " + in.GetName()}, nil
+}
+
+type testServiceServer struct {
+	grpc_testing.UnimplementedTestServiceServer
+}
+
+func (testServiceServer) EmptyCall(ctx context.Context, in *grpc_testing.Empty) (*grpc_testing.Empty, error) {
+	return &grpc_testing.Empty{}, nil
+}
+
+func TestRegisterAll(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_register_all",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var order []string
+	register := RegisterAll(
+		func(s *grpc.Server) error {
+			order = append(order, "greeter")
+			helloworld.RegisterGreeterServer(s, greeterServer{})
+			return nil
+		},
+		nil,
+		func(s *grpc.Server) error {
+			order = append(order, "test")
+			grpc_testing.RegisterTestServiceServer(s, testServiceServer{})
+			return nil
+		},
+	)
+
+	server, err := NewServer(ctx, config, register, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"greeter", "test"}, order)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Run()
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	reply, err := helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Hello world", reply.GetMessage())
+	}
+
+	_, err = grpc_testing.NewTestServiceClient(conn).EmptyCall(context.Background(), &grpc_testing.Empty{})
+	assert.NoError(t, err)
+
+	cancel()
+	err = <-errChan
+	assert.NoError(t, err)
+}
+
+func TestRegisterService(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Name:            "test",
+		Namespace:       "test_register_service",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	services := []string{"helloworld.Greeter", "grpc.testing.TestService"}
+	assert.NoError(t, server.RegisterService(services[0], func(s *grpc.Server) error {
+		helloworld.RegisterGreeterServer(s, greeterServer{})
+		return nil
+	}))
+	assert.NoError(t, server.RegisterService(services[1], func(s *grpc.Server) error {
+		grpc_testing.RegisterTestServiceServer(s, testServiceServer{})
+		return nil
+	}))
+
+	// A failed registration isn't reported in the health check service
+	assert.Error(t, server.RegisterService("payments.Ledger", func(s *grpc.Server) error {
+		return errors.New("no database")
+	}))
+	_, err = server.healthServer.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "payments.Ledger"})
+	assert.Error(t, err)
+
+	status := func(service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		resp, err := server.healthServer.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		if !assert.NoError(t, err) {
+			return grpc_health_v1.HealthCheckResponse_UNKNOWN
+		}
+		return resp.GetStatus()
+	}
+
+	// Registered services aren't serving until the server starts
+	for _, service := range services {
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, status(service), service)
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	client := grpc_health_v1.NewHealthClient(conn)
+	for _, service := range append([]string{config.HealthServiceName}, services...) {
+		resp, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		if assert.NoError(t, err, service) {
+			assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.GetStatus(), service)
+		}
+	}
+
+	// The registered services are reachable
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	assert.NoError(t, err)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	for _, service := range services {
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, status(service), service)
+	}
+}
+
+func TestGRPCServer(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_grpc_server",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Register an extra service through the accessor before Run
+	helloworld.RegisterGreeterServer(server.GRPCServer(), greeterServer{})
+	assert.Contains(t, server.GRPCServer().GetServiceInfo(), "helloworld.Greeter")
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	reply, err := helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Hello world", reply.GetMessage())
+	}
+
+	// Inspecting still works once serving has started, but registering is
+	// refused before gRPC would exit the process
+	assert.Contains(t, server.ServiceInfo(), "helloworld.Greeter")
+	assert.PanicsWithValue(t, "grpc: GRPCServer called after Run", func() {
+		helloworld.RegisterGreeterServer(server.GRPCServer(), greeterServer{})
+	})
+	assert.PanicsWithValue(t, "grpc: RegisterService called after Run", func() {
+		server.RegisterService("grpc.testing.TestService", nil)
+	})
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestSignalShutdownWithCancelledContext(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_signal_cancelled_ctx",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	var logs syncBuffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	// Slow down every RPC so one is still in flight when shutdown begins
+	slowInterceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		time.Sleep(200 * time.Millisecond)
+		return handler(ctx, req)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger, grpc.UnaryInterceptor(slowInterceptor))
+	assert.NoError(t, err)
+
+	shutdownChan := make(chan os.Signal, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(shutdownChan)
+	}()
+
+	// Give server time to start
+	time.Sleep(100 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	rpcErr := make(chan error, 1)
+	go func() {
+		_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+		rpcErr <- err
+	}()
+
+	// Let the RPC reach the server and signal. The parent context is only
+	// cancelled once the signal has started the shutdown, so run can't pick
+	// the cancellation instead
+	time.Sleep(50 * time.Millisecond)
+	shutdownChan <- os.Interrupt
+	assert.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "signal=interrupt")
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+
+	// The in-flight RPC still drains, as the shutdown timeout isn't derived
+	// from the cancelled parent
+	assert.NoError(t, <-errChan)
+	assert.NoError(t, <-rpcErr)
+	assert.Contains(t, logs.String(), "graceful stop complete")
+	assert.NotContains(t, logs.String(), "signal=context_cancelled")
+}
+
+// syncBuffer is a bytes.Buffer safe to write from the server's goroutines
+// while a test reads it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestMetricsTLS(t *testing.T) {
+	t.Parallel()
+
+	cert := testcert.New(t)
+
+	tests := map[string]struct {
+		namespace    string
+		certFile     string
+		keyFile      string
+		mainCertFile string
+		mainKeyFile  string
+		inheritTLS   bool
+		wantErr      bool
+		scheme       string
+	}{
+		"tls": {
+			namespace: "test_metrics_tls",
+			certFile:  cert.CertFile,
+			keyFile:   cert.KeyFile,
+			scheme:    "https",
+		},
+		"plaintext": {
+			namespace: "test_metrics_plaintext",
+			scheme:    "http",
+		},
+		"missing cert": {
+			namespace: "test_metrics_missing_cert",
+			keyFile:   cert.KeyFile,
+			wantErr:   true,
+		},
+		"inherits main tls": {
+			namespace:    "test_metrics_inherit_tls",
+			mainCertFile: cert.CertFile,
+			mainKeyFile:  cert.KeyFile,
+			inheritTLS:   true,
+			scheme:       "https",
+		},
+		"inherits plaintext main": {
+			namespace:  "test_metrics_inherit_plaintext",
+			inheritTLS: true,
+			scheme:     "http",
+		},
+		"main tls without inheritance": {
+			namespace:    "test_metrics_no_inherit",
+			mainCertFile: cert.CertFile,
+			mainKeyFile:  cert.KeyFile,
+			scheme:       "http",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			metricsAddr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:          tt.namespace,
+				APIHost:            "127.0.0.1:0",
+				MetricsHost:        metricsAddr,
+				ShutdownTimeout:    5 * time.Second,
+				MetricsTLSCertFile: tt.certFile,
+				MetricsTLSKeyFile:  tt.keyFile,
+				TLSCertFile:        tt.mainCertFile,
+				TLSKeyFile:         tt.mainKeyFile,
+				MetricsInheritTLS:  tt.inheritTLS,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			client := &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: cert.Pool},
+				},
+			}
+			resp, err := client.Get(tt.scheme + "://" + metricsAddr + "/metrics")
+			if assert.NoError(t, err) {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Contains(t, string(body), "go_goroutines")
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	t.Parallel()
+
+	serverCert := testcert.New(t)
+	clientCert := testcert.New(t)
+	otherCert := testcert.New(t)
+
+	tests := map[string]struct {
+		caFile     string
+		clientCert *testcert.Cert
+		wantErr    bool
+	}{
+		"tls without client ca": {},
+		"trusted client certificate": {
+			caFile:     clientCert.CertFile,
+			clientCert: &clientCert,
+		},
+		"no client certificate": {
+			caFile:  clientCert.CertFile,
+			wantErr: true,
+		},
+		"untrusted client certificate": {
+			caFile:     clientCert.CertFile,
+			clientCert: &otherCert,
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				APIHost:         addr,
+				ShutdownTimeout: 5 * time.Second,
+				TLSCertFile:     serverCert.CertFile,
+				TLSKeyFile:      serverCert.KeyFile,
+				TLSClientCAFile: tt.caFile,
+				DisableMetrics:  true,
+				Registry:        prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			clientTLS := &tls.Config{RootCAs: serverCert.Pool}
+			if tt.clientCert != nil {
+				pair, err := tls.LoadX509KeyPair(tt.clientCert.CertFile, tt.clientCert.KeyFile)
+				assert.NoError(t, err)
+				clientTLS.Certificates = []tls.Certificate{pair}
+			}
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			callCtx, callCancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer callCancel()
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(callCtx, &grpc_health_v1.HealthCheckRequest{})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+
+	t.Run("client ca without tls", func(t *testing.T) {
+		t.Parallel()
+
+		config := Config{
+			APIHost:         "127.0.0.1:0",
+			TLSClientCAFile: clientCert.CertFile,
+			DisableMetrics:  true,
+			Registry:        prometheus.NewRegistry(),
+		}
+		_, err := NewServer(context.Background(), config, nil, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		assert.Error(t, err)
+	})
+}
+
+func TestConnectionTimeout(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		connectionTimeout time.Duration
+	}{
+		"stalled handshake is closed": {
+			connectionTimeout: 100 * time.Millisecond,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:         "test_connection_timeout",
+				APIHost:           addr,
+				MetricsHost:       "127.0.0.1:0",
+				ShutdownTimeout:   5 * time.Second,
+				ConnectionTimeout: tt.connectionTimeout,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			assert.NoError(t, err)
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			// Open a connection but never send the HTTP/2 preface
+			conn, err := net.Dial("tcp", addr)
+			if assert.NoError(t, err) {
+				defer conn.Close()
+
+				start := time.Now()
+				assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+				_, err = io.ReadAll(conn)
+				assert.NoError(t, err, "server should close the stalled connection")
+				assert.Less(t, time.Since(start), 5*time.Second)
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
+
+func TestKeepaliveOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config Config
+		want   int
+	}{
+		"defaults": {},
+		"server parameters": {
+			config: Config{MaxConnectionAge: time.Minute, KeepaliveTime: 30 * time.Second},
+			want:   1,
+		},
+		"enforcement policy": {
+			config: Config{KeepaliveWithoutStream: true},
+			want:   1,
+		},
+		"both": {
+			config: Config{MaxConnectionIdle: time.Minute, KeepaliveMinTime: 10 * time.Second},
+			want:   2,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Len(t, keepaliveOptions(tt.config), tt.want)
+		})
+	}
+}
+
+func TestMaxConnectionIdle(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		APIHost:           addr,
+		DisableMetrics:    true,
+		ShutdownTimeout:   5 * time.Second,
+		MaxConnectionIdle: 100 * time.Millisecond,
+		Registry:          prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+
+	// The server closes the idle connection, which sends the client back to
+	// idle
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer waitCancel()
+	assert.True(t, conn.WaitForStateChange(waitCtx, connectivity.Ready), "server should close the idle connection")
+	assert.Equal(t, connectivity.Idle, conn.GetState())
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestMaxHeaderListSize(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		metadataSize int
+		wantErr      bool
+	}{
+		"headers within limit": {
+			metadataSize: 100,
+		},
+		"headers over limit": {
+			metadataSize: 4096,
+			wantErr:      true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				APIHost:           addr,
+				DisableMetrics:    true,
+				ShutdownTimeout:   5 * time.Second,
+				MaxHeaderListSize: 1024,
+				Registry:          prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			callCtx := metadata.AppendToOutgoingContext(context.Background(), "x-padding", strings.Repeat("a", tt.metadataSize))
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(callCtx, &grpc_health_v1.HealthCheckRequest{})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
+
+func TestShutdownProgressLogging(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		interval  time.Duration
+		wantLog   bool
+	}{
+		"progress logged": {
+			namespace: "test_shutdown_progress",
+			interval:  50 * time.Millisecond,
+			wantLog:   true,
+		},
+		"progress disabled": {
+			namespace: "test_shutdown_progress_disabled",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:                tt.namespace,
+				APIHost:                  addr,
+				MetricsHost:              "127.0.0.1:0",
+				ShutdownTimeout:          5 * time.Second,
+				ShutdownProgressInterval: tt.interval,
+			}
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+			// Block every RPC so one is still draining during shutdown
+			blocking := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				time.Sleep(300 * time.Millisecond)
+				return handler(ctx, req)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger, grpc.UnaryInterceptor(blocking))
+			assert.NoError(t, err)
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			rpcErr := make(chan error, 1)
+			go func() {
+				_, err := grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+				rpcErr <- err
+			}()
+
+			// Let the RPC reach the server, then shut down
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+
+			assert.NoError(t, <-errChan)
+			assert.NoError(t, <-rpcErr)
+
+			if tt.wantLog {
+				assert.Contains(t, buf.String(), "graceful stop in progress")
+				assert.Contains(t, buf.String(), "active_rpcs=1")
+			} else {
+				assert.NotContains(t, buf.String(), "graceful stop in progress")
+			}
+		})
+	}
+}
+
+func TestRunNoGoroutineLeaks(t *testing.T) {
+	// Not parallel: goroutines from other tests would be reported as leaks
+	servertest.CheckGoroutines(t)
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_run_no_leaks",
+		APIHost:         addr,
+		DebugHost:       "127.0.0.1:0",
+		EnableDebug:     true,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	assert.NoError(t, err)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestListenNetwork(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_listen_network",
+		APIHost:         fmt.Sprintf(":%d", port),
+		ListenNetwork:   "tcp4",
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(fmt.Sprintf("127.0.0.1:%d", port), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	assert.NoError(t, err)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestNewServerHostCollision(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:   "test_host_collision_error",
+		APIHost:     "0.0.0.0:50051",
+		MetricsHost: "0.0.0.0:50051",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewServer(context.Background(), config, nil, logger)
+	assert.EqualError(t, err, `api host "0.0.0.0:50051" and metrics host "0.0.0.0:50051" listen on the same address`)
+}
+
+func TestQuietShutdown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		quiet     bool
+		wantLines int
+	}{
+		"every step at info": {
+			quiet:     false,
+			wantLines: 5, // health, grpc started, grpc complete, metrics started and complete
+		},
+		"quiet": {
+			quiet:     true,
+			wantLines: 1, // graceful stop complete only
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			config := Config{
+				Namespace:       "test_quiet_shutdown_" + strings.ReplaceAll(name, " ", "_"),
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				QuietShutdown:   tt.quiet,
+			}
+
+			server, err := NewServer(context.Background(), config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+			buf.Reset()
+
+			err = server.shutdownServers(context.Background(), nil)
+			assert.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			assert.Len(t, lines, tt.wantLines, buf.String())
+			assert.Contains(t, buf.String(), `level=INFO msg=shutdown server=grpc status="graceful stop complete"`)
+		})
+	}
+}
+
+func TestMetricsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		registry bool
+	}{
+		"custom registry":  {registry: true},
+		"default registry": {registry: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ns := "test_metrics_snapshot_" + strings.ReplaceAll(name, " ", "_")
+			path := filepath.Join(t.TempDir(), "metrics.prom")
+			config := Config{
+				Namespace:           ns,
+				APIHost:             "127.0.0.1:0",
+				MetricsHost:         "127.0.0.1:0",
+				ShutdownTimeout:     5 * time.Second,
+				MetricsSnapshotFile: path,
+			}
+			if tt.registry {
+				config.Registry = prometheus.NewRegistry()
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			ctx, cancel := context.WithCancel(context.Background())
+
+			server, err := NewServer(ctx, config, nil, logger)
+			if !assert.NoError(t, err) {
+				cancel()
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			cancel()
+			assert.NoError(t, <-errChan)
+
+			snapshot, err := os.ReadFile(path)
+			if assert.NoError(t, err) {
+				assert.Contains(t, string(snapshot), ns+"_grpc_shutdown_duration_seconds ")
+			}
+		})
+	}
+}
+
+func TestDisableMetrics(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	reg := prometheus.NewRegistry()
+	config := Config{
+		Namespace:       "test_disable_metrics",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		DisableMetrics:  true,
+		Registry:        reg,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	register := func(s *grpc.Server) error {
+		helloworld.RegisterGreeterServer(s, greeterServer{})
+		return nil
+	}
+	server, err := NewServer(ctx, config, register, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = helloworld.NewGreeterClient(conn).SayHello(context.Background(), &helloworld.HelloRequest{Name: "world"})
+	assert.NoError(t, err)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	for _, name := range []string{
+		"test_disable_metrics_grpc_requests_total",
+		"test_disable_metrics_grpc_errors_total",
+		"grpc_server_handled_total",
+	} {
+		_, err := gatherFamily(reg, name)
+		assert.Error(t, err, "metric %s should not be registered", name)
+	}
+}
+
+func TestDisableMetricsSlowRequestThreshold(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:            "test_disable_metrics_slow",
+		DisableMetrics:       true,
+		SlowRequestThreshold: time.Second,
+		Registry:             prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := NewServer(context.Background(), config, nil, logger)
+	assert.EqualError(t, err, "DisableMetrics can't be combined with SlowRequestThreshold")
+	assert.Nil(t, server)
+}
+
+func TestNewServerZeroShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:   "test_zero_shutdown_timeout",
+		APIHost:     "127.0.0.1:0",
+		MetricsHost: "127.0.0.1:0",
+		Registry:    prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := NewServer(context.Background(), config, nil, logger)
+	if assert.NoError(t, err) {
+		assert.Equal(t, DefaultShutdownTimeout, server.config.ShutdownTimeout)
+	}
+}
+
+func TestLogAttrs(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_log_attrs",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+		LogAttrs:        map[string]string{"service": "orders", "env": "prod"},
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	out := buf.String()
+	assert.Contains(t, out, "msg=startup")
+	assert.Contains(t, out, "msg=shutdown")
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		assert.Contains(t, line, "env=prod service=orders")
+	}
+}
+
+func TestRegisterer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		disableMetrics bool
+	}{
+		"metrics enabled":  {},
+		"metrics disabled": {disableMetrics: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace:      "test_registerer",
+				APIHost:        "127.0.0.1:0",
+				DisableMetrics: tt.disableMetrics,
+				Registry:       prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if tt.disableMetrics {
+				assert.Nil(t, server.RED())
+			} else {
+				assert.NotNil(t, server.RED())
+			}
+
+			orders := prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: config.Namespace,
+				Name:      "orders_total",
+				Help:      "Number of orders placed",
+			})
+			assert.NoError(t, server.Registerer().Register(orders))
+			orders.Add(3)
+
+			scrape := httptest.NewRecorder()
+			server.metricsServer.Handler.ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Contains(t, scrape.Body.String(), "test_registerer_orders_total 3")
+		})
+	}
+}
+
+func TestGo(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fn      func(stopped *atomic.Bool) func(ctx context.Context) error
+		wantErr string
+	}{
+		"cancelled and awaited": {
+			fn: func(stopped *atomic.Bool) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					<-ctx.Done()
+					// Shutdown must wait for cleanup after cancellation
+					time.Sleep(50 * time.Millisecond)
+					stopped.Store(true)
+					return ctx.Err()
+				}
+			},
+		},
+		"error is returned from run": {
+			fn: func(stopped *atomic.Bool) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					stopped.Store(true)
+					return errors.New("consumer failed")
+				}
+			},
+			wantErr: "consumer failed",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       "test_go_" + strings.ReplaceAll(name, " ", "_"),
+				APIHost:         addr,
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				Registry:        prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var stopped atomic.Bool
+			server.Go(tt.fn(&stopped))
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			cancel()
+			err = <-errChan
+			assert.True(t, stopped.Load())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGoAfterShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:       "test_go_after_shutdown_timeout",
+		APIHost:         "127.0.0.1:0",
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := NewServer(context.Background(), config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	var stopped atomic.Bool
+	server.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		stopped.Store(true)
+		return ctx.Err()
+	})
+	server.background.Start()
+
+	// The servers used up the whole shutdown timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = server.shutdownAndSnapshot(ctx, nil)
+	assert.ErrorContains(t, err, "grpc server shutdown timed out")
+	assert.NotContains(t, err.Error(), "background goroutines did not stop")
+	assert.True(t, stopped.Load())
+}
+
+func TestRunUntil(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		stopWithContext bool
+	}{
+		"stop channel closed": {},
+		"context cancelled": {
+			stopWithContext: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       "test_run_until_" + strings.ReplaceAll(name, " ", "_"),
+				APIHost:         addr,
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				Registry:        prometheus.NewRegistry(),
+			}
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			server, err := NewServer(context.Background(), config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stop := make(chan struct{})
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.RunUntil(ctx, stop)
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			// Still running until told to stop
+			select {
+			case err := <-errChan:
+				t.Fatalf("server stopped early: %v", err)
+			default:
+			}
+
+			if tt.stopWithContext {
+				cancel()
+			} else {
+				close(stop)
+			}
+
+			select {
+			case err := <-errChan:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("server did not shut down")
+			}
+			assert.Contains(t, logs.String(), "shutdown complete")
+		})
+	}
+}
+
+func TestRunTwice(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_run_twice",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	assert.ErrorIs(t, server.Run(), ErrAlreadyRunning)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	// A server that has stopped can't be run again either
+	assert.ErrorIs(t, server.run(make(chan os.Signal, 1)), ErrAlreadyRunning)
+}
+
+func TestShutdownServersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	// Each server has a call in flight that only finishes once another
+	// server has started shutting down, so stopping them one after the
+	// other would run out of budget
+	freeAddr := func() string {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		defer lis.Close()
+		return lis.Addr().String()
+	}
+
+	metricsStarted := make(chan struct{})
+	debugStarted := make(chan struct{})
+
+	// The gRPC call waits for the metrics server's shutdown
+	slowRPC := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		<-metricsStarted
+		return handler(ctx, req)
+	}
+
+	budget := 2 * time.Second
+	config := Config{
+		Namespace:       "test_shutdown_servers_concurrently",
+		APIHost:         freeAddr(),
+		MetricsHost:     freeAddr(),
+		DebugHost:       freeAddr(),
+		EnableDebug:     true,
+		ShutdownTimeout: budget,
+		ShutdownOrder:   ShutdownConcurrent,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, nil, logger, grpc.UnaryInterceptor(slowRPC))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// The metrics and debug requests each wait for the other's shutdown
+	server.metricsServer.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-debugStarted
+	})
+	server.debugServer.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-metricsStarted
+	})
+	server.metricsServer.RegisterOnShutdown(func() { close(metricsStarted) })
+	server.debugServer.RegisterOnShutdown(func() { close(debugStarted) })
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := grpc.NewClient(config.APIHost, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	go grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+	go http.Get("http://" + config.MetricsHost + "/")
+	go http.Get("http://" + config.DebugHost + "/")
+
+	// Give the calls time to reach the handlers
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	cancel()
+	assert.NoError(t, <-errChan)
+	assert.Less(t, time.Since(start), budget)
+}
+
+func TestShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		order ShutdownOrder
+		want  []string
+	}{
+		"metrics last": {
+			order: ShutdownMetricsLast,
+			want: []string{
+				"health shutdown complete",
+				"grpc shutting down started", "grpc graceful stop complete",
+				"metrics shutdown started", "metrics shutdown complete",
+			},
+		},
+		"metrics first": {
+			order: ShutdownMetricsFirst,
+			want: []string{
+				"health shutdown complete",
+				"metrics shutdown started", "metrics shutdown complete",
+				"grpc shutting down started", "grpc graceful stop complete",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			config := Config{
+				Namespace:       "test_shutdown_order",
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				ShutdownOrder:   tt.order,
+				Registry:        prometheus.NewRegistry(),
+			}
+
+			server, err := NewServer(context.Background(), config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+			buf.Reset()
+
+			err = server.shutdownServers(context.Background(), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, shutdownSteps(buf.String()))
+		})
+	}
+}
+
+func TestNewServerInvalidShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:     "test_invalid_shutdown_order",
+		MetricsHost:   "127.0.0.1:0",
+		ShutdownOrder: "metrics-sometimes",
+		Registry:      prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewServer(context.Background(), config, nil, logger)
+	assert.EqualError(t, err, `invalid shutdown order "metrics-sometimes"`)
+}
+
+// shutdownSteps returns the server and status of each JSON shutdown log
+// line, e.g. "grpc graceful stop complete".
+func shutdownSteps(logs string) []string {
+	var steps []string
+	for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+		var record struct {
+			Server string `json:"server"`
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record.Server == "" {
+			continue
+		}
+		steps = append(steps, record.Server+" "+record.Status)
+	}
+
+	return steps
+}
@@ -0,0 +1,128 @@
+package grpc
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// WithMessageSampling makes the stream logging interceptor log only the
+// first and last message in each direction of a stream, plus every nth one
+// in between. Values below 2 log every message.
+func WithMessageSampling(every int) InterceptorOption {
+	return func(o *interceptorOptions) {
+		o.sampleEvery = every
+	}
+}
+
+// StreamLoggingInterceptor returns a gRPC stream interceptor that logs the
+// messages sent and received on each stream, and a summary with the message
+// counts when the stream finishes. Bidi streams can carry many messages, so
+// combine it with WithMessageSampling to keep the volume down.
+func StreamLoggingInterceptor(opts ...InterceptorOption) grpc.StreamServerInterceptor {
+	o := newInterceptorOptions(opts)
+
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		start := o.clock.Now()
+
+		service, method, err := extractServiceMethod(info.FullMethod)
+		if err != nil {
+			return invalidMethodError(o.logger, err)
+		}
+
+		log := func(direction string, n int, msg any) {
+			o.logger.Info("stream", "service", service, "method", method, "direction", direction, "message", n, "type", fmt.Sprintf("%T", msg))
+		}
+		ls := &loggingStream{
+			ServerStream: ss,
+			recv:         messageSampler{direction: "received", every: o.sampleEvery, log: log},
+			sent:         messageSampler{direction: "sent", every: o.sampleEvery, log: log},
+		}
+
+		err = handler(srv, ls)
+
+		// The last message in each direction is only known once the stream
+		// is over
+		ls.recv.flush()
+		ls.sent.flush()
+
+		o.logger.Info("stream", "status", "finished", "service", service, "method", method,
+			"received", ls.recv.count, "sent", ls.sent.count,
+			"logged", ls.recv.logged+ls.sent.logged,
+			"code", status.Code(err).String(), "duration", o.clock.Now().Sub(start))
+
+		return err
+	}
+}
+
+// loggingStream wraps grpc.ServerStream to log the messages passing through
+// it. gRPC allows one goroutine to send while another receives, so each
+// direction keeps its own state.
+type loggingStream struct {
+	grpc.ServerStream
+	recv messageSampler
+	sent messageSampler
+}
+
+// RecvMsg receives a message from the client and logs it if sampled.
+func (s *loggingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.recv.observe(m)
+
+	return nil
+}
+
+// SendMsg sends a message to the client and logs it if sampled.
+func (s *loggingStream) SendMsg(m any) error {
+	if err := s.ServerStream.SendMsg(m); err != nil {
+		return err
+	}
+	s.sent.observe(m)
+
+	return nil
+}
+
+// messageSampler decides which messages in one direction of a stream get
+// logged. The latest unlogged message is held back so it can be logged as
+// the last one when the stream finishes.
+type messageSampler struct {
+	direction string
+	every     int
+	log       func(direction string, n int, msg any)
+
+	count   int
+	logged  int
+	pending any
+}
+
+// observe counts msg and logs it if it is sampled.
+func (s *messageSampler) observe(msg any) {
+	s.count++
+	if s.count == 1 || s.every < 2 || s.count%s.every == 0 {
+		s.pending = nil
+		s.emit(s.count, msg)
+		return
+	}
+	s.pending = msg
+}
+
+// flush logs the last message if it wasn't sampled.
+func (s *messageSampler) flush() {
+	if s.pending != nil {
+		s.emit(s.count, s.pending)
+		s.pending = nil
+	}
+}
+
+func (s *messageSampler) emit(n int, msg any) {
+	s.logged++
+	s.log(s.direction, n, msg)
+}
@@ -0,0 +1,112 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// messageStream is a grpc.ServerStream whose client sends recv messages and
+// then closes its side.
+type messageStream struct {
+	mockServerStream
+	recv int
+}
+
+func (s *messageStream) RecvMsg(m any) error {
+	if s.recv == 0 {
+		return io.EOF
+	}
+	s.recv--
+	return nil
+}
+
+func (s *messageStream) SendMsg(m any) error {
+	return nil
+}
+
+func TestStreamLoggingInterceptor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		every      int
+		messages   int
+		wantLogged int
+	}{
+		"no sampling logs every message": {
+			every:      0,
+			messages:   10,
+			wantLogged: 20,
+		},
+		"first, every third and last": {
+			every:      3,
+			messages:   10,
+			wantLogged: 10,
+		},
+		"last already sampled": {
+			every:      5,
+			messages:   10,
+			wantLogged: 6,
+		},
+		"first and last only": {
+			every:      100,
+			messages:   10,
+			wantLogged: 4,
+		},
+		"single message": {
+			every:      3,
+			messages:   1,
+			wantLogged: 2,
+		},
+		"empty stream": {
+			every:      3,
+			messages:   0,
+			wantLogged: 0,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			interceptor := StreamLoggingInterceptor(WithLogger(logger), WithMessageSampling(tt.every))
+
+			stream := &messageStream{mockServerStream: mockServerStream{ctx: context.Background()}, recv: tt.messages}
+			info := &grpc.StreamServerInfo{FullMethod: "/helloworld.Greeter/Chat", IsClientStream: true, IsServerStream: true}
+
+			// Echo every message back to the client
+			err := interceptor(nil, stream, info, func(srv interface{}, ss grpc.ServerStream) error {
+				for {
+					var msg string
+					if err := ss.RecvMsg(&msg); err != nil {
+						if errors.Is(err, io.EOF) {
+							return nil
+						}
+						return err
+					}
+					if err := ss.SendMsg(msg); err != nil {
+						return err
+					}
+				}
+			})
+			assert.NoError(t, err)
+
+			out := buf.String()
+			assert.Equal(t, tt.wantLogged, strings.Count(out, "direction="))
+			assert.Contains(t, out, "status=finished")
+			assert.Contains(t, out, "logged="+strconv.Itoa(tt.wantLogged))
+			assert.Contains(t, out, "received="+strconv.Itoa(tt.messages))
+			assert.Contains(t, out, "sent="+strconv.Itoa(tt.messages))
+		})
+	}
+}
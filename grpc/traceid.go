@@ -0,0 +1,61 @@
+package grpc
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// TraceIDKey is the response trailer key carrying the server's trace ID.
+const TraceIDKey = "trace-id"
+
+// traceIDTrailer returns the trailer carrying the trace ID of the span in
+// ctx, or nil when there is no active span.
+func traceIDTrailer(ctx context.Context) metadata.MD {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return nil
+	}
+
+	return metadata.Pairs(TraceIDKey, sc.TraceID().String())
+}
+
+// UnaryTraceIDInterceptor returns a gRPC unary interceptor that adds the
+// server's trace ID to the response trailer, so clients can log it for
+// correlation. It is a no-op when the call has no active span, e.g. when
+// tracing isn't set up.
+func UnaryTraceIDInterceptor() grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		if md := traceIDTrailer(ctx); md != nil {
+			// SetTrailer only fails outside of a server call
+			_ = grpc.SetTrailer(ctx, md)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// StreamTraceIDInterceptor returns a gRPC stream interceptor that adds the
+// server's trace ID to the response trailer. It is a no-op when the stream
+// has no active span.
+func StreamTraceIDInterceptor() grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		if md := traceIDTrailer(ss.Context()); md != nil {
+			ss.SetTrailer(md)
+		}
+
+		return handler(srv, ss)
+	}
+}
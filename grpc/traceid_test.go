@@ -0,0 +1,104 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestTraceIDMetadata(t *testing.T) {
+	t.Parallel()
+
+	traceID := trace.TraceID{0x4b, 0xf9, 0x2f, 0x35, 0x77, 0xb3, 0x4d, 0xa6, 0xa3, 0xce, 0x92, 0x9d, 0x0e, 0x0e, 0x47, 0x36}
+
+	tests := map[string]struct {
+		namespace       string
+		traceIDMetadata bool
+		withSpan        bool
+		want            []string
+	}{
+		"active span": {
+			namespace:       "test_trace_id_span",
+			traceIDMetadata: true,
+			withSpan:        true,
+			want:            []string{traceID.String()},
+		},
+		"no active span": {
+			namespace:       "test_trace_id_no_span",
+			traceIDMetadata: true,
+		},
+		"disabled": {
+			namespace: "test_trace_id_disabled",
+			withSpan:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       tt.namespace,
+				APIHost:         addr,
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				TraceIDMetadata: tt.traceIDMetadata,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			// Stand in for a tracing stats handler by starting a span context
+			tracing := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if tt.withSpan {
+					ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+						TraceID: traceID,
+						SpanID:  trace.SpanID{0x00, 0xf0, 0x67, 0xaa, 0x0b, 0xa9, 0x02, 0xb7},
+					}))
+				}
+				return handler(ctx, req)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, nil, logger, grpc.UnaryInterceptor(tracing))
+			assert.NoError(t, err)
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+
+			var trailer metadata.MD
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{}, grpc.Trailer(&trailer))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, trailer.Get(TraceIDKey))
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
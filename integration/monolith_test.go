@@ -0,0 +1,187 @@
+// Package integration holds tests that run the rest and grpc servers
+// together.
+package integration
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/server/grpc"
+	"github.com/rabellamy/server/rest"
+	"github.com/stretchr/testify/assert"
+	googlegrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// monolith is a REST and a gRPC server running in one process, sharing a
+// registry exposed on the REST server's metrics endpoint.
+type monolith struct {
+	restAddr    string
+	grpcAddr    string
+	metricsAddr string
+}
+
+// freeAddr returns a local address with a currently unused port.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().String()
+}
+
+// startMonolith starts both servers on free ports and stops them when the
+// test finishes.
+func startMonolith(t *testing.T, namespace string, reg *prometheus.Registry) monolith {
+	t.Helper()
+
+	m := monolith{
+		restAddr:    freeAddr(t),
+		grpcAddr:    freeAddr(t),
+		metricsAddr: freeAddr(t),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	restServer, err := rest.NewServer(ctx, rest.Config{
+		Namespace:       namespace,
+		APIHost:         m.restAddr,
+		MetricsHost:     m.metricsAddr,
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        reg,
+	}, rest.Routes{
+		"/hello": func(w http.ResponseWriter, r *http.Request) {},
+	}, logger)
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to create rest server: %v", err)
+	}
+
+	grpcServer, err := grpc.NewServer(ctx, grpc.Config{
+		Namespace:            namespace,
+		Name:                 "monolith",
+		APIHost:              m.grpcAddr,
+		ShutdownTimeout:      5 * time.Second,
+		DisableMetricsServer: true,
+		Registry:             reg,
+	}, nil, logger)
+	if err != nil {
+		cancel()
+		t.Fatalf("failed to create grpc server: %v", err)
+	}
+
+	errs := make(chan error, 2)
+	go func() { errs <- restServer.Run() }()
+	go func() { errs <- grpcServer.Run() }()
+
+	t.Cleanup(func() {
+		cancel()
+		for range 2 {
+			assert.NoError(t, <-errs)
+		}
+	})
+
+	// Give servers time to start
+	time.Sleep(100 * time.Millisecond)
+
+	return m
+}
+
+func TestMonolithSharedRegistry(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		want      []string
+	}{
+		"rest and grpc metrics on one endpoint": {
+			namespace: "test_monolith",
+			want: []string{
+				"test_monolith_http_requests_total",
+				"test_monolith_grpc_requests_total",
+				"test_monolith_grpc_reflection_enabled",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := prometheus.NewRegistry()
+			m := startMonolith(t, tt.namespace, reg)
+
+			resp, err := http.Get("http://" + m.restAddr + "/hello")
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+			}
+
+			conn, err := googlegrpc.NewClient(m.grpcAddr, googlegrpc.WithTransportCredentials(insecure.NewCredentials()))
+			assert.NoError(t, err)
+			defer conn.Close()
+			_, err = grpc_health_v1.NewHealthClient(conn).Check(context.Background(), &grpc_health_v1.HealthCheckRequest{})
+			assert.NoError(t, err)
+
+			resp, err = http.Get("http://" + m.metricsAddr + "/metrics")
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			assert.NoError(t, err)
+
+			for _, metric := range tt.want {
+				assert.Contains(t, string(body), metric)
+			}
+		})
+	}
+}
+
+func TestSharedNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		reg       *prometheus.Registry
+	}{
+		"default registry": {
+			namespace: "test_shared_namespace_default",
+		},
+		"custom registry": {
+			namespace: "test_shared_namespace_custom",
+			reg:       prometheus.NewRegistry(),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			_, err := rest.NewServer(context.Background(), rest.Config{
+				Namespace: tt.namespace,
+				Registry:  tt.reg,
+			}, rest.Routes{}, logger)
+			assert.NoError(t, err)
+
+			_, err = grpc.NewServer(context.Background(), grpc.Config{
+				Namespace: tt.namespace,
+				Registry:  tt.reg,
+			}, nil, logger)
+			assert.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Package background runs goroutines tied to the rest and grpc servers'
+// lifecycles, so apps' background loops stop with the server instead of
+// outliving it.
+package background
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+)
+
+// Group runs functions from when the server starts until it shuts down.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	pending []func(context.Context) error
+	errs    []error
+	wg      sync.WaitGroup
+}
+
+// New returns a Group whose functions' context is derived from ctx.
+func New(ctx context.Context, logger *slog.Logger) *Group {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &Group{ctx: ctx, cancel: cancel, logger: logger}
+}
+
+// Go runs fn in a goroutine once the group has started, or right away if it
+// already has. fn's context is cancelled when the group stops. Functions
+// passed after the group has stopped are not run.
+func (g *Group) Go(fn func(ctx context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch {
+	case g.stopped:
+	case g.started:
+		g.run(fn)
+	default:
+		g.pending = append(g.pending, fn)
+	}
+}
+
+// Start runs the functions passed to Go so far.
+func (g *Group) Start() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.started = true
+	for _, fn := range g.pending {
+		g.run(fn)
+	}
+	g.pending = nil
+}
+
+// run starts fn. g.mu must be held.
+func (g *Group) run(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		// Returning the context's error once cancelled is a clean stop
+		err := fn(g.ctx)
+		if err == nil || (g.ctx.Err() != nil && errors.Is(err, context.Canceled)) {
+			return
+		}
+
+		g.logger.Error("background", "status", "goroutine failed", "err", err)
+		g.mu.Lock()
+		g.errs = append(g.errs, err)
+		g.mu.Unlock()
+	}()
+}
+
+// Stop cancels the functions' context and waits for them to return, or for
+// ctx to be done. It returns the errors the functions returned.
+func (g *Group) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	g.stopped = true
+	g.pending = nil
+	g.mu.Unlock()
+
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		// Goroutines that finished just as ctx expired still stopped
+		select {
+		case <-done:
+		default:
+			return fmt.Errorf("background goroutines did not stop: %w", ctx.Err())
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return errors.Join(g.errs...)
+}
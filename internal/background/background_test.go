@@ -0,0 +1,122 @@
+package background
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fn      func(ctx context.Context) error
+		stopCtx func() (context.Context, context.CancelFunc)
+		wantErr string
+	}{
+		"cancelled and awaited": {
+			fn: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+		"returns early": {
+			fn: func(ctx context.Context) error {
+				return nil
+			},
+		},
+		"fails": {
+			fn: func(ctx context.Context) error {
+				return errors.New("queue closed")
+			},
+			wantErr: "queue closed",
+		},
+		"ignores cancellation": {
+			fn: func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			},
+			stopCtx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 10*time.Millisecond)
+			},
+			wantErr: "background goroutines did not stop: context deadline exceeded",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			g := New(context.Background(), slog.New(slog.DiscardHandler))
+
+			var ran atomic.Bool
+			g.Go(func(ctx context.Context) error {
+				ran.Store(true)
+				return tt.fn(ctx)
+			})
+			assert.False(t, ran.Load(), "ran before Start")
+
+			g.Start()
+
+			// Let the function run before stopping
+			time.Sleep(10 * time.Millisecond)
+
+			stopCtx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.stopCtx != nil {
+				stopCtx, cancel = tt.stopCtx()
+			}
+			defer cancel()
+
+			err := g.Stop(stopCtx)
+			assert.True(t, ran.Load())
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGroupGoAfterStart(t *testing.T) {
+	t.Parallel()
+
+	g := New(context.Background(), slog.New(slog.DiscardHandler))
+	g.Start()
+
+	stopped := make(chan struct{})
+	g.Go(func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	})
+
+	assert.NoError(t, g.Stop(context.Background()))
+	select {
+	case <-stopped:
+	default:
+		t.Fatal("Stop returned before the goroutine did")
+	}
+}
+
+func TestGroupGoAfterStop(t *testing.T) {
+	t.Parallel()
+
+	g := New(context.Background(), slog.New(slog.DiscardHandler))
+	g.Start()
+	assert.NoError(t, g.Stop(context.Background()))
+
+	var ran atomic.Bool
+	g.Go(func(ctx context.Context) error {
+		ran.Store(true)
+		return nil
+	})
+
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, ran.Load())
+}
@@ -0,0 +1,34 @@
+// Package ctxkey provides typed context keys shared by the rest and grpc
+// packages, so the values they store in a context can't collide with each
+// other or with keys from user code.
+package ctxkey
+
+import "context"
+
+// Key identifies a value of type T stored in a context. Keys are compared by
+// identity: two Keys never collide, even with the same name, and neither
+// does a Key with a string or any other key type a caller might use.
+type Key[T any] struct {
+	name string
+}
+
+// New returns a new Key. name is only used to describe the key.
+func New[T any](name string) *Key[T] {
+	return &Key[T]{name: name}
+}
+
+// WithValue returns a copy of ctx carrying value under k.
+func (k *Key[T]) WithValue(ctx context.Context, value T) context.Context {
+	return context.WithValue(ctx, k, value)
+}
+
+// Value returns the value stored under k in ctx, and whether there was one.
+func (k *Key[T]) Value(ctx context.Context) (T, bool) {
+	value, ok := ctx.Value(k).(T)
+	return value, ok
+}
+
+// String describes the key, e.g. when a context is printed.
+func (k *Key[T]) String() string {
+	return "ctxkey." + k.name
+}
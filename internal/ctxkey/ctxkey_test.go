@@ -0,0 +1,72 @@
+package ctxkey
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type stringKey string
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	key := New[string]("request id")
+
+	tests := map[string]struct {
+		ctx       context.Context
+		want      string
+		wantFound bool
+	}{
+		"round trip": {
+			ctx:       key.WithValue(context.Background(), "abc"),
+			want:      "abc",
+			wantFound: true,
+		},
+		"missing": {
+			ctx: context.Background(),
+		},
+		"another key with the same name": {
+			ctx: New[string]("request id").WithValue(context.Background(), "abc"),
+		},
+		"plain string key with the same name": {
+			ctx: context.WithValue(context.Background(), "request id", "abc"),
+		},
+		"typed string key with the same name": {
+			ctx: context.WithValue(context.Background(), stringKey("request id"), "abc"),
+		},
+		"string key doesn't shadow the key": {
+			ctx:       context.WithValue(key.WithValue(context.Background(), "abc"), stringKey("request id"), "xyz"),
+			want:      "abc",
+			wantFound: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := key.Value(tt.ctx)
+			assert.Equal(t, tt.wantFound, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestKeyPointerValue(t *testing.T) {
+	t.Parallel()
+
+	type counter struct{ n int }
+	key := New[*counter]("counter")
+
+	c := &counter{}
+	ctx := key.WithValue(context.Background(), c)
+
+	got, ok := key.Value(ctx)
+	if assert.True(t, ok) {
+		got.n++
+		assert.Equal(t, 1, c.n, "value should be shared, not copied")
+	}
+	assert.Equal(t, "ctxkey.counter", key.String())
+}
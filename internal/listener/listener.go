@@ -0,0 +1,141 @@
+// Package listener creates the network listeners shared by the rest and grpc
+// servers.
+package listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultNetwork is the network used when none is configured. It listens on
+// both IPv4 and IPv6 where available.
+const DefaultNetwork = "tcp"
+
+// Network validates a configured listen network, returning DefaultNetwork
+// when it is empty. Only tcp, tcp4, tcp6 and unix are supported.
+func Network(network string) (string, error) {
+	switch network {
+	case "":
+		return DefaultNetwork, nil
+	case "tcp", "tcp4", "tcp6", "unix":
+		return network, nil
+	default:
+		return "", fmt.Errorf("unsupported listen network %q: must be tcp, tcp4, tcp6 or unix", network)
+	}
+}
+
+// Options configures how listeners are created.
+type Options struct {
+	// ReusePort sets SO_REUSEPORT on the socket so another process can bind
+	// the same address, which allows a new process to start accepting
+	// connections while the old one drains.
+	ReusePort bool
+
+	// Retries is how many more times binding an address that is still in
+	// use is attempted before giving up, e.g. while a previous process's
+	// socket lingers after a fast restart. Zero fails on the first error.
+	Retries int
+
+	// RetryBackoff is the wait before the first retry. It doubles after
+	// every further attempt.
+	RetryBackoff time.Duration
+
+	// KeepAlive is the interval between TCP keep-alive probes on accepted
+	// connections, which detect peers that went away without closing the
+	// connection, e.g. behind a stateful firewall. Zero uses Go's default
+	// and a negative value disables keep-alives.
+	KeepAlive time.Duration
+}
+
+// listenConfig returns the net.ListenConfig implementing opts.
+func listenConfig(opts Options) net.ListenConfig {
+	lc := net.ListenConfig{
+		KeepAlive: opts.KeepAlive,
+	}
+	if opts.ReusePort {
+		lc.Control = reusePortControl
+	}
+
+	return lc
+}
+
+// Listen announces on the local network address using the given options.
+// Cancelling ctx stops retries still waiting to bind, returning ctx's error.
+func Listen(ctx context.Context, network, address string, opts Options) (net.Listener, error) {
+	lc := listenConfig(opts)
+
+	backoff := opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		ln, err := lc.Listen(ctx, network, address)
+		if err == nil || attempt >= opts.Retries || !errors.Is(err, syscall.EADDRINUSE) {
+			return ln, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// Addr is an address one of a process's servers listens on.
+type Addr struct {
+	// Name describes the server, e.g. "metrics".
+	Name    string
+	Address string
+	// Network is the network Address is on. Empty means DefaultNetwork.
+	Network string
+}
+
+// CheckConflicts returns an error describing the first two addrs that would
+// bind the same socket, so servers sharing an address fail with a clear
+// error rather than whichever one loses the race to bind it. Empty addresses
+// are ignored.
+func CheckConflicts(addrs ...Addr) error {
+	for i, a := range addrs {
+		for _, b := range addrs[i+1:] {
+			if overlap(a, b) {
+				return fmt.Errorf("%s host %q and %s host %q listen on the same address", a.Name, a.Address, b.Name, b.Address)
+			}
+		}
+	}
+
+	return nil
+}
+
+// overlap reports whether binding a and b would conflict. Unix sockets only
+// conflict with the same path, and the tcp networks with each other. Port 0
+// picks a free port, so it never conflicts, and a wildcard host conflicts
+// with every host on the same port.
+func overlap(a, b Addr) bool {
+	if a.Address == "" || b.Address == "" {
+		return false
+	}
+	if a.Network == "unix" || b.Network == "unix" {
+		return a.Network == b.Network && a.Address == b.Address
+	}
+
+	hostA, portA, errA := net.SplitHostPort(a.Address)
+	hostB, portB, errB := net.SplitHostPort(b.Address)
+	if errA != nil || errB != nil {
+		return a.Address == b.Address
+	}
+	if portA != portB || portA == "0" {
+		return false
+	}
+
+	return wildcard(hostA) || wildcard(hostB) || strings.EqualFold(hostA, hostB)
+}
+
+func wildcard(host string) bool {
+	return host == "" || host == "0.0.0.0" || host == "::"
+}
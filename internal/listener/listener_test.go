@@ -0,0 +1,272 @@
+package listener
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListen(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts          Options
+		wantSecondErr bool
+	}{
+		"reuse port allows a second bind": {
+			opts:          Options{ReusePort: true},
+			wantSecondErr: false,
+		},
+		"without reuse port the second bind fails": {
+			opts:          Options{},
+			wantSecondErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			first, err := Listen(context.Background(), "tcp", "127.0.0.1:0", tt.opts)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer first.Close()
+
+			second, err := Listen(context.Background(), "tcp", first.Addr().String(), tt.opts)
+			if tt.wantSecondErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				defer second.Close()
+				assert.Equal(t, first.Addr().String(), second.Addr().String())
+			}
+		})
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		network string
+		want    string
+		wantErr bool
+	}{
+		"empty defaults to tcp": {network: "", want: "tcp"},
+		"tcp":                   {network: "tcp", want: "tcp"},
+		"tcp4":                  {network: "tcp4", want: "tcp4"},
+		"tcp6":                  {network: "tcp6", want: "tcp6"},
+		"unix":                  {network: "unix", want: "unix"},
+		"unsupported":           {network: "udp", wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Network(tt.network)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestListenNetwork(t *testing.T) {
+	t.Parallel()
+
+	t.Run("tcp4 binds an IPv4 address", func(t *testing.T) {
+		t.Parallel()
+
+		ln, err := Listen(context.Background(), "tcp4", ":0", Options{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ln.Close()
+
+		addr, ok := ln.Addr().(*net.TCPAddr)
+		if assert.True(t, ok) {
+			assert.NotNil(t, addr.IP.To4(), "expected an IPv4 address, got %s", addr.IP)
+		}
+	})
+
+	t.Run("unix binds a socket", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "server.sock")
+		ln, err := Listen(context.Background(), "unix", path, Options{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer ln.Close()
+
+		assert.Equal(t, "unix", ln.Addr().Network())
+		assert.Equal(t, path, ln.Addr().String())
+	})
+}
+
+func TestListenRetries(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts    Options
+		wantErr bool
+	}{
+		"bind succeeds once the port is freed": {
+			opts: Options{Retries: 5, RetryBackoff: 50 * time.Millisecond},
+		},
+		"without retries the bind fails": {
+			opts:    Options{},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			held, err := Listen(context.Background(), "tcp", "127.0.0.1:0", Options{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			addr := held.Addr().String()
+
+			// Free the port after the first attempt has failed
+			go func() {
+				time.Sleep(20 * time.Millisecond)
+				held.Close()
+			}()
+
+			ln, err := Listen(context.Background(), "tcp", addr, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				defer ln.Close()
+				assert.Equal(t, addr, ln.Addr().String())
+			}
+		})
+	}
+}
+
+func TestListenRetriesCancelled(t *testing.T) {
+	t.Parallel()
+
+	held, err := Listen(context.Background(), "tcp", "127.0.0.1:0", Options{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer held.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+
+	// The port stays taken, so only cancelling ends the retries early
+	start := time.Now()
+	_, err = Listen(ctx, "tcp", held.Addr().String(), Options{Retries: 10, RetryBackoff: time.Minute})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCheckConflicts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		addrs   []Addr
+		wantErr string
+	}{
+		"distinct ports": {
+			addrs: []Addr{{"api", "0.0.0.0:3000", "tcp"}, {"metrics", "0.0.0.0:2112", "tcp"}},
+		},
+		"identical hosts": {
+			addrs:   []Addr{{"api", "0.0.0.0:3000", "tcp"}, {"metrics", "0.0.0.0:3000", "tcp"}},
+			wantErr: `api host "0.0.0.0:3000" and metrics host "0.0.0.0:3000" listen on the same address`,
+		},
+		"wildcard and specific host on the same port": {
+			addrs:   []Addr{{"api", ":3000", "tcp"}, {"debug", "127.0.0.1:3000", "tcp"}},
+			wantErr: `api host ":3000" and debug host "127.0.0.1:3000" listen on the same address`,
+		},
+		"different specific hosts on the same port": {
+			addrs: []Addr{{"api", "127.0.0.1:3000", "tcp"}, {"metrics", "127.0.0.2:3000", "tcp"}},
+		},
+		"random ports": {
+			addrs: []Addr{{"api", "127.0.0.1:0", "tcp"}, {"metrics", "127.0.0.1:0", "tcp"}},
+		},
+		"empty addresses": {
+			addrs: []Addr{{"api", "", "tcp"}, {"metrics", "", "tcp"}},
+		},
+		"identical unix sockets": {
+			addrs:   []Addr{{"api", "/tmp/app.sock", "unix"}, {"metrics", "/tmp/app.sock", "unix"}},
+			wantErr: `api host "/tmp/app.sock" and metrics host "/tmp/app.sock" listen on the same address`,
+		},
+		"unix socket and tcp address": {
+			addrs: []Addr{{"api", "/tmp/app.sock", "unix"}, {"metrics", "0.0.0.0:2112", "tcp"}},
+		},
+		"tcp4 and tcp on the same port": {
+			addrs:   []Addr{{"api", ":3000", "tcp4"}, {"metrics", "0.0.0.0:3000", "tcp"}},
+			wantErr: `api host ":3000" and metrics host "0.0.0.0:3000" listen on the same address`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckConflicts(tt.addrs...)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestListenConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts          Options
+		wantKeepAlive time.Duration
+		wantControl   bool
+	}{
+		"defaults": {
+			opts: Options{},
+		},
+		"keep-alive": {
+			opts:          Options{KeepAlive: 3 * time.Minute},
+			wantKeepAlive: 3 * time.Minute,
+		},
+		"keep-alive disabled": {
+			opts:          Options{KeepAlive: -1},
+			wantKeepAlive: -1,
+		},
+		"reuse port": {
+			opts:        Options{ReusePort: true},
+			wantControl: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lc := listenConfig(tt.opts)
+			assert.Equal(t, tt.wantKeepAlive, lc.KeepAlive)
+			assert.Equal(t, tt.wantControl, lc.Control != nil)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+// Package logattrs attaches the configured service-identifying attributes to
+// the rest and grpc server loggers.
+package logattrs
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// With returns logger with attrs added to every record, in key order so log
+// lines are stable. It returns logger itself when attrs is empty.
+func With(logger *slog.Logger, attrs map[string]string) *slog.Logger {
+	if len(attrs) == 0 {
+		return logger
+	}
+
+	args := make([]any, 0, len(attrs))
+	for _, key := range slices.Sorted(maps.Keys(attrs)) {
+		args = append(args, slog.String(key, attrs[key]))
+	}
+
+	return logger.With(args...)
+}
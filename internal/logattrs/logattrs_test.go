@@ -0,0 +1,46 @@
+package logattrs
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		attrs map[string]string
+		want  string
+	}{
+		"no attrs": {
+			attrs: nil,
+			want:  "level=INFO msg=hello\n",
+		},
+		"sorted attrs": {
+			attrs: map[string]string{"version": "1.2.3", "env": "prod", "service": "api"},
+			want:  "level=INFO msg=hello env=prod service=api version=1.2.3\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+				ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					if a.Key == slog.TimeKey {
+						return slog.Attr{}
+					}
+					return a
+				},
+			}))
+
+			With(logger, tt.attrs).Info("hello")
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
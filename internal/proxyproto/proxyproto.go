@@ -0,0 +1,306 @@
+// Package proxyproto wraps listeners to read the PROXY protocol header a
+// layer 4 load balancer prepends to each connection, so servers see the real
+// client address instead of the load balancer's.
+//
+// Both version 1 (text) and version 2 (binary) headers are supported.
+// Connections without a valid header are closed, since the header is only
+// trustworthy when every connection comes through the load balancer.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderTimeout bounds how long reading a connection's header may take.
+var HeaderTimeout = 10 * time.Second
+
+// maxV1Length is the longest a version 1 header can be, including CRLF.
+const maxV1Length = 107
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+// NewListener wraps ln so every accepted connection has had its PROXY header
+// read before Accept returns it. Headers are read in a goroutine per
+// connection, so a slow or silent client doesn't hold up accepting others,
+// and RemoteAddr never blocks. A connection whose header is invalid is still
+// returned, and its Read returns the error.
+func NewListener(ln net.Listener) net.Listener {
+	l := &listener{
+		Listener: ln,
+		accepted: make(chan accepted),
+		done:     make(chan struct{}),
+		pending:  map[net.Conn]struct{}{},
+	}
+	go l.acceptLoop()
+
+	return l
+}
+
+type listener struct {
+	net.Listener
+	accepted chan accepted
+	done     chan struct{}
+	stop     sync.Once
+
+	mu      sync.Mutex
+	pending map[net.Conn]struct{}
+}
+
+// accepted is a connection, or an error from the wrapped listener, waiting
+// to be returned by Accept.
+type accepted struct {
+	conn net.Conn
+	err  error
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case a := <-l.accepted:
+		return a.conn, a.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the wrapped listener and the connections whose header is
+// still being read.
+func (l *listener) Close() error {
+	l.close()
+
+	l.mu.Lock()
+	for c := range l.pending {
+		c.Close()
+	}
+	l.mu.Unlock()
+
+	return l.Listener.Close()
+}
+
+func (l *listener) close() {
+	l.stop.Do(func() { close(l.done) })
+}
+
+// acceptLoop accepts connections from the wrapped listener until it is
+// closed, reading each one's header in its own goroutine.
+func (l *listener) acceptLoop() {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			if !l.send(accepted{err: err}) || errors.Is(err, net.ErrClosed) {
+				l.close()
+				return
+			}
+			continue
+		}
+
+		if !l.track(c) {
+			c.Close()
+			return
+		}
+		go l.handshake(c)
+	}
+}
+
+// handshake reads c's header and queues it for Accept.
+func (l *listener) handshake(c net.Conn) {
+	pc := &conn{Conn: c, reader: bufio.NewReaderSize(c, 256)}
+	pc.readHeader()
+
+	l.mu.Lock()
+	delete(l.pending, c)
+	l.mu.Unlock()
+
+	if !l.send(accepted{conn: pc}) {
+		c.Close()
+	}
+}
+
+// track records c as pending, unless the listener has been closed.
+func (l *listener) track(c net.Conn) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	select {
+	case <-l.done:
+		return false
+	default:
+	}
+	l.pending[c] = struct{}{}
+
+	return true
+}
+
+// send hands a to Accept, reporting false if the listener was closed first.
+func (l *listener) send(a accepted) bool {
+	select {
+	case l.accepted <- a:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	remoteAddr net.Addr
+	err        error
+}
+
+// readHeader reads the PROXY header. On failure the connection is closed and
+// every later Read returns the error.
+func (c *conn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(HeaderTimeout))
+	c.remoteAddr, c.err = readHeader(c.reader)
+	c.Conn.SetReadDeadline(time.Time{})
+
+	if c.err != nil {
+		c.err = fmt.Errorf("proxy protocol: %w", c.err)
+		c.Conn.Close()
+	}
+}
+
+func (c *conn) Read(b []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the client address from the PROXY header, or the
+// connection's own address when the header carries none, e.g. for health
+// checks made by the load balancer itself.
+func (c *conn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+
+	return c.Conn.RemoteAddr()
+}
+
+// readHeader reads a version 1 or 2 header from r and returns the source
+// address it carries, or nil when it carries none.
+func readHeader(r *bufio.Reader) (net.Addr, error) {
+	peek, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if bytes.Equal(peek, v1Prefix) {
+		return readV1(r)
+	}
+
+	peek, err = r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if bytes.Equal(peek, v2Signature) {
+		return readV2(r)
+	}
+
+	return nil, errors.New("missing header")
+}
+
+// readV1 reads a header of the form
+// "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n".
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= maxV1Length {
+			return nil, errors.New("v1 header too long")
+		}
+	}
+
+	text, ok := strings.CutSuffix(string(line), "\r\n")
+	if !ok {
+		return nil, errors.New("v1 header not terminated by CRLF")
+	}
+
+	fields := strings.Split(text, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid v1 header %q", text)
+	}
+
+	ip := net.ParseIP(fields[2])
+	if ip == nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid v1 source address %q", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid v1 source port %q", fields[4])
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readV2 reads a binary header: the signature, a version and command byte, a
+// family and transport byte, the length of the rest and the addresses,
+// followed by optional TLVs that are skipped.
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(v2Signature)+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("reading v2 header: %w", err)
+	}
+
+	verCmd := header[12]
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	if verCmd>>4 != 2 {
+		return nil, fmt.Errorf("unsupported v2 version %d", verCmd>>4)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("reading v2 addresses: %w", err)
+	}
+
+	switch verCmd & 0x0f {
+	case 0x0: // LOCAL: a connection from the load balancer itself
+		return nil, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported v2 command %d", verCmd&0x0f)
+	}
+
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(body) < 12 {
+			return nil, errors.New("v2 IPv4 addresses too short")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(body) < 36 {
+			return nil, errors.New("v2 IPv6 addresses too short")
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	default:
+		// Other families, e.g. UNIX sockets, carry no usable client address
+		return nil, nil
+	}
+}
@@ -0,0 +1,184 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// v2Header builds a version 2 header with command cmd, family fam and the
+// given address block.
+func v2Header(cmd, fam byte, addrs []byte) []byte {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|cmd, fam)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addrs)))
+	return append(header, addrs...)
+}
+
+func TestListener(t *testing.T) {
+	t.Parallel()
+
+	ipv4Addrs := []byte{203, 0, 113, 7, 10, 0, 0, 1}
+	ipv4Addrs = binary.BigEndian.AppendUint16(ipv4Addrs, 56324)
+	ipv4Addrs = binary.BigEndian.AppendUint16(ipv4Addrs, 443)
+
+	tests := map[string]struct {
+		header         []byte
+		wantRemoteAddr string
+		wantProxied    bool
+		wantErr        bool
+	}{
+		"v1 tcp4": {
+			header:         []byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"),
+			wantRemoteAddr: "203.0.113.7:56324",
+			wantProxied:    true,
+		},
+		"v1 tcp6": {
+			header:         []byte("PROXY TCP6 2001:db8::7 2001:db8::1 56324 443\r\n"),
+			wantRemoteAddr: "[2001:db8::7]:56324",
+			wantProxied:    true,
+		},
+		"v1 unknown keeps the connection address": {
+			header: []byte("PROXY UNKNOWN\r\n"),
+		},
+		"v2 tcp4": {
+			header:         v2Header(0x1, 0x11, ipv4Addrs),
+			wantRemoteAddr: "203.0.113.7:56324",
+			wantProxied:    true,
+		},
+		"v2 tcp4 with tlvs": {
+			header:         v2Header(0x1, 0x11, append(append([]byte{}, ipv4Addrs...), 0x04, 0x00, 0x01, 0xff)),
+			wantRemoteAddr: "203.0.113.7:56324",
+			wantProxied:    true,
+		},
+		"v2 local keeps the connection address": {
+			header: v2Header(0x0, 0x00, nil),
+		},
+		"missing header": {
+			header:  []byte("GET / HTTP/1.1\r\n"),
+			wantErr: true,
+		},
+		"v1 family mismatch": {
+			header:  []byte("PROXY TCP4 2001:db8::7 10.0.0.1 56324 443\r\n"),
+			wantErr: true,
+		},
+		"v1 without crlf": {
+			header:  []byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\n"),
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if !assert.NoError(t, err) {
+				return
+			}
+			ln := NewListener(inner)
+			defer ln.Close()
+
+			client, err := net.Dial("tcp", ln.Addr().String())
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer client.Close()
+
+			go client.Write(append(append([]byte{}, tt.header...), "hello"...))
+
+			conn, err := ln.Accept()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			payload := make([]byte, 5)
+			_, err = io.ReadFull(conn, payload)
+			if tt.wantErr {
+				assert.ErrorContains(t, err, "proxy protocol")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "hello", string(payload))
+
+			if tt.wantProxied {
+				assert.Equal(t, tt.wantRemoteAddr, conn.RemoteAddr().String())
+			} else {
+				assert.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+			}
+		})
+	}
+}
+
+func TestListenerSilentConnection(t *testing.T) {
+	t.Parallel()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ln := NewListener(inner)
+	defer ln.Close()
+
+	// Never sends a header
+	silent, err := net.Dial("tcp", ln.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer silent.Close()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer client.Close()
+	go client.Write([]byte("PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"))
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+
+	select {
+	case conn := <-accepted:
+		defer conn.Close()
+		assert.Equal(t, "203.0.113.7:56324", conn.RemoteAddr().String())
+	case <-time.After(time.Second):
+		t.Fatal("silent connection held up accepting another")
+	}
+}
+
+func TestListenerCloseWhileReadingHeader(t *testing.T) {
+	t.Parallel()
+
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	ln := NewListener(inner)
+
+	silent, err := net.Dial("tcp", ln.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer silent.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	assert.NoError(t, ln.Close())
+
+	// Closing the listener closes connections still waiting for a header
+	silent.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = silent.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = ln.Accept()
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
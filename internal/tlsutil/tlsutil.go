@@ -0,0 +1,60 @@
+// Package tlsutil builds the TLS configurations used by the rest and grpc
+// servers.
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Load returns a server TLS configuration using the given certificate and key
+// files. It returns a nil configuration when neither file is set, meaning the
+// server should serve plaintext.
+func Load(certFile, keyFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("both a TLS certificate and key file must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// RequireClientCerts returns a copy of config that requires clients to present
+// a certificate signed by a CA in caFile, for mutual TLS. config is returned
+// unchanged when caFile isn't set.
+func RequireClientCerts(config *tls.Config, caFile string) (*tls.Config, error) {
+	if caFile == "" {
+		return config, nil
+	}
+	if config == nil {
+		return nil, errors.New("a client CA file requires a TLS certificate and key file")
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("client CA file contains no PEM certificates")
+	}
+
+	config = config.Clone()
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
@@ -0,0 +1,129 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rabellamy/server/internal/testcert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	cert := testcert.New(t)
+
+	tests := map[string]struct {
+		certFile string
+		keyFile  string
+		wantNil  bool
+		wantErr  bool
+	}{
+		"plaintext": {
+			wantNil: true,
+		},
+		"valid key pair": {
+			certFile: cert.CertFile,
+			keyFile:  cert.KeyFile,
+		},
+		"missing key": {
+			certFile: cert.CertFile,
+			wantErr:  true,
+		},
+		"missing cert": {
+			keyFile: cert.KeyFile,
+			wantErr: true,
+		},
+		"unreadable files": {
+			certFile: "does-not-exist.pem",
+			keyFile:  "does-not-exist.pem",
+			wantErr:  true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := Load(tt.certFile, tt.keyFile)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantNil {
+				assert.Nil(t, got)
+			} else if assert.NotNil(t, got) {
+				assert.Len(t, got.Certificates, 1)
+			}
+		})
+	}
+}
+
+func TestRequireClientCerts(t *testing.T) {
+	t.Parallel()
+
+	cert := testcert.New(t)
+	base, err := Load(cert.CertFile, cert.KeyFile)
+	assert.NoError(t, err)
+
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	assert.NoError(t, os.WriteFile(notPEM, []byte("not a certificate"), 0o600))
+
+	tests := map[string]struct {
+		config     *tls.Config
+		caFile     string
+		wantErr    bool
+		wantMutual bool
+	}{
+		"no client ca": {
+			config: base,
+		},
+		"plaintext without client ca": {},
+		"client ca": {
+			config:     base,
+			caFile:     cert.CertFile,
+			wantMutual: true,
+		},
+		"client ca without tls": {
+			caFile:  cert.CertFile,
+			wantErr: true,
+		},
+		"unreadable client ca": {
+			config:  base,
+			caFile:  "does-not-exist.pem",
+			wantErr: true,
+		},
+		"client ca without certificates": {
+			config:  base,
+			caFile:  notPEM,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := RequireClientCerts(tt.config, tt.caFile)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if !tt.wantMutual {
+				assert.Same(t, tt.config, got)
+				return
+			}
+			if assert.NotNil(t, got) {
+				assert.Equal(t, tls.RequireAndVerifyClientCert, got.ClientAuth)
+				assert.NotNil(t, got.ClientCAs)
+				assert.Len(t, got.Certificates, 1)
+			}
+			// The original configuration is left untouched
+			assert.Equal(t, tls.NoClientCert, tt.config.ClientAuth)
+		})
+	}
+}
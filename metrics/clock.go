@@ -0,0 +1,17 @@
+package metrics
+
+import "time"
+
+// Clock tells the time used when measuring durations. It lets tests replace
+// the wall clock so recorded durations are deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is a Clock backed by time.Now.
+type RealClock struct{}
+
+// Now returns the current local time.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
@@ -3,18 +3,90 @@ package metrics
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rabellamy/promstrap/strategy"
 )
 
+// REDOption configures the metrics created by NewRED.
+type REDOption func(*redOptions)
+
+type redOptions struct {
+	nativeHistogramBucketFactor float64
+	constLabels                 prometheus.Labels
+	help                        REDHelp
+}
+
+// REDHelp is the help text of the RED metrics. Empty fields keep the default
+// help text.
+type REDHelp struct {
+	Requests string
+	Errors   string
+	Duration string
+}
+
+// WithNativeHistogram creates the duration histogram as a Prometheus native
+// histogram with the given bucket factor (e.g. 1.1), in addition to its
+// classic buckets. A factor of 0 keeps classic buckets only.
+func WithNativeHistogram(bucketFactor float64) REDOption {
+	return func(o *redOptions) {
+		o.nativeHistogramBucketFactor = bucketFactor
+	}
+}
+
+// WithConstLabels adds labels with fixed values, such as env="prod" or
+// region="us-east", to every RED metric.
+func WithConstLabels(labels prometheus.Labels) REDOption {
+	return func(o *redOptions) {
+		o.constLabels = labels
+	}
+}
+
+// WithHelp replaces the help text of the RED metrics.
+func WithHelp(help REDHelp) REDOption {
+	return func(o *redOptions) {
+		o.help = help
+	}
+}
+
+// reservedPrefixes are metric name prefixes used by the collectors and
+// handlers on the Prometheus default registry, or reserved by Prometheus
+// itself.
+var reservedPrefixes = []string{"go_", "process_", "promhttp_", "scrape_", "__"}
+
+// checkReserved returns an error when metrics in namespace would use a
+// reserved prefix.
+func checkReserved(namespace string) error {
+	name := namespace + "_"
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return fmt.Errorf("namespace %q uses the reserved metric name prefix %q", namespace, prefix)
+		}
+	}
+
+	return nil
+}
+
 // NewRED creates a new RED metrics instance.
-func NewRED(namespace, requestType string, requestLabels, durationLabels []string) (*strategy.RED, error) {
+func NewRED(namespace, requestType string, requestLabels, durationLabels []string, opts ...REDOption) (*strategy.RED, error) {
 	// regex matches Prometheus metric name limits
 	// see: https://prometheus.io/docs/concepts/data_model/#metric-names-and-labels
 	metricNameRegex := regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
 	if !metricNameRegex.MatchString(namespace) {
 		return nil, fmt.Errorf("namespace must match %s", metricNameRegex.String())
 	}
+	if err := checkReserved(namespace); err != nil {
+		return nil, err
+	}
+
+	var o redOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.nativeHistogramBucketFactor != 0 && o.nativeHistogramBucketFactor <= 1 {
+		return nil, fmt.Errorf("native histogram bucket factor must be greater than 1, got %v", o.nativeHistogramBucketFactor)
+	}
 
 	red, err := strategy.NewRED(strategy.REDOpts{
 		Namespace: namespace,
@@ -22,7 +94,10 @@ func NewRED(namespace, requestType string, requestLabels, durationLabels []strin
 			RequestType:   requestType,
 			RequestLabels: requestLabels,
 		},
+		// Prefix the errors metric with the request type like the other RED
+		// metrics, so HTTP and gRPC servers can share a namespace.
 		ErrorsOpt: strategy.REDErrorsOpt{
+			ErrorName:   requestType + "_errors_total",
 			ErrorLabels: []string{"error"},
 		},
 		DurationOpt: strategy.REDDurationOpt{
@@ -34,5 +109,54 @@ func NewRED(namespace, requestType string, requestLabels, durationLabels []strin
 		return nil, err
 	}
 
+	// promstrap has no native histogram, const label or help options, so swap
+	// in equivalent metrics with the same names and labels.
+	custom := len(o.constLabels) > 0 || o.help != (REDHelp{})
+	help := o.help.withDefaults()
+	if custom {
+		red.Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        red.RequestMetricName(),
+			Help:        help.Requests,
+			ConstLabels: o.constLabels,
+		}, requestLabels)
+		red.Errors = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Name:        red.ErrorMetricName(),
+			Help:        help.Errors,
+			ConstLabels: o.constLabels,
+		}, []string{"error"})
+		red.Duration.Summary = prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace:   namespace,
+			Name:        red.DurationMetricName() + "_sum",
+			Help:        help.Duration,
+			ConstLabels: o.constLabels,
+		}, durationLabels)
+	}
+	if custom || o.nativeHistogramBucketFactor != 0 {
+		red.Duration.Histogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:                   namespace,
+			Name:                        red.DurationMetricName() + "_hist",
+			Help:                        help.Duration,
+			ConstLabels:                 o.constLabels,
+			NativeHistogramBucketFactor: o.nativeHistogramBucketFactor,
+		}, durationLabels)
+	}
+
 	return red, nil
 }
+
+// withDefaults returns h with promstrap's help text in its empty fields.
+func (h REDHelp) withDefaults() REDHelp {
+	if h.Requests == "" {
+		h.Requests = "Number of requests"
+	}
+	if h.Errors == "" {
+		h.Errors = "Number of errors, RED"
+	}
+	if h.Duration == "" {
+		h.Duration = "Duration of request in seconds"
+	}
+
+	return h
+}
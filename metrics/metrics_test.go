@@ -3,6 +3,7 @@ package metrics
 import (
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rabellamy/promstrap/strategy"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,6 +31,30 @@ func TestNewRED(t *testing.T) {
 			want:      &strategy.RED{},
 			wantErr:   true,
 		},
+		"reserved go namespace": {
+			namespace: "go",
+			wantErr:   true,
+		},
+		"reserved process prefix": {
+			namespace: "process_api",
+			wantErr:   true,
+		},
+		"reserved scrape prefix": {
+			namespace: "scrape",
+			wantErr:   true,
+		},
+		"reserved promhttp prefix": {
+			namespace: "promhttp",
+			wantErr:   true,
+		},
+		"reserved double underscore": {
+			namespace: "__internal",
+			wantErr:   true,
+		},
+		"reserved word inside namespace": {
+			namespace: "golang_api",
+			want:      &strategy.RED{},
+		},
 	}
 
 	for name, tt := range tests {
@@ -48,3 +73,188 @@ func TestNewRED(t *testing.T) {
 		})
 	}
 }
+
+func TestNewREDNativeHistogram(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		bucketFactor float64
+		wantNative   bool
+		wantErr      bool
+	}{
+		"native histogram": {
+			bucketFactor: 1.1,
+			wantNative:   true,
+		},
+		"classic buckets": {
+			bucketFactor: 0,
+		},
+		"invalid bucket factor": {
+			bucketFactor: 0.5,
+			wantErr:      true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			red, err := NewRED("test_native_histogram", "http", []string{"path", "verb"}, []string{"path"}, WithNativeHistogram(tt.bucketFactor))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			red.Duration.Histogram.WithLabelValues("/").Observe(0.25)
+
+			reg := prometheus.NewRegistry()
+			assert.NoError(t, reg.Register(red.Duration.Histogram))
+			families, err := reg.Gather()
+			assert.NoError(t, err)
+			if !assert.Len(t, families, 1) {
+				return
+			}
+			assert.Equal(t, "test_native_histogram_http_request_duration_seconds_hist", families[0].GetName())
+
+			histogram := families[0].GetMetric()[0].GetHistogram()
+			assert.Equal(t, uint64(1), histogram.GetSampleCount())
+			assert.Equal(t, tt.wantNative, histogram.Schema != nil)
+		})
+	}
+}
+
+func TestNewREDConstLabels(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts     []REDOption
+		wantHelp string
+	}{
+		"const labels": {
+			opts:     []REDOption{WithConstLabels(prometheus.Labels{"env": "prod", "region": "us-east"})},
+			wantHelp: "Duration of request in seconds",
+		},
+		"const labels with native histogram": {
+			opts: []REDOption{
+				WithNativeHistogram(1.1),
+				WithConstLabels(prometheus.Labels{"env": "prod", "region": "us-east"}),
+			},
+			wantHelp: "Duration of request in seconds",
+		},
+		"const labels with custom help": {
+			opts: []REDOption{
+				WithConstLabels(prometheus.Labels{"env": "prod", "region": "us-east"}),
+				WithHelp(REDHelp{Duration: "Time to serve a request"}),
+			},
+			wantHelp: "Time to serve a request",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			red, err := NewRED("test_const_labels", "http", []string{"path", "verb"}, []string{"path"}, tt.opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			red.Requests.WithLabelValues("/", "GET").Inc()
+			red.Errors.WithLabelValues("500").Inc()
+			red.Duration.Histogram.WithLabelValues("/").Observe(0.25)
+			red.Duration.Summary.WithLabelValues("/").Observe(0.25)
+
+			reg := prometheus.NewRegistry()
+			assert.NoError(t, Register(reg, red))
+			families, err := reg.Gather()
+			assert.NoError(t, err)
+			assert.Len(t, families, 4)
+
+			for _, family := range families {
+				labels := map[string]string{}
+				for _, label := range family.GetMetric()[0].GetLabel() {
+					labels[label.GetName()] = label.GetValue()
+				}
+				assert.Equal(t, "prod", labels["env"], family.GetName())
+				assert.Equal(t, "us-east", labels["region"], family.GetName())
+
+				if family.GetName() == "test_const_labels_http_request_duration_seconds_hist" {
+					assert.Equal(t, tt.wantHelp, family.GetHelp())
+				}
+			}
+		})
+	}
+}
+
+func TestNewREDHelp(t *testing.T) {
+	t.Parallel()
+
+	red, err := NewRED("test_red_help", "http", []string{"path", "verb"}, []string{"path"}, WithHelp(REDHelp{
+		Requests: "HTTP requests served",
+		Errors:   "HTTP requests that failed",
+	}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	red.Requests.WithLabelValues("/", "GET").Inc()
+	red.Errors.WithLabelValues("500").Inc()
+	red.Duration.Histogram.WithLabelValues("/").Observe(0.25)
+	red.Duration.Summary.WithLabelValues("/").Observe(0.25)
+
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, Register(reg, red))
+	families, err := reg.Gather()
+	assert.NoError(t, err)
+
+	help := map[string]string{}
+	for _, family := range families {
+		help[family.GetName()] = family.GetHelp()
+	}
+	assert.Equal(t, map[string]string{
+		"test_red_help_http_requests_total":                "HTTP requests served",
+		"test_red_help_http_errors_total":                  "HTTP requests that failed",
+		"test_red_help_http_request_duration_seconds_hist": "Duration of request in seconds",
+		"test_red_help_http_request_duration_seconds_sum":  "Duration of request in seconds",
+	}, help)
+}
+
+func TestNewREDSharedNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		requestTypes []string
+		wantErrors   []string
+	}{
+		"http and grpc": {
+			requestTypes: []string{"http", "grpc"},
+			wantErrors:   []string{"test_shared_namespace_http_errors_total", "test_shared_namespace_grpc_errors_total"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := prometheus.NewRegistry()
+			for _, requestType := range tt.requestTypes {
+				red, err := NewRED("test_shared_namespace", requestType, []string{"path", "verb"}, []string{"path"})
+				assert.NoError(t, err)
+				assert.NoError(t, Register(reg, red))
+				red.Errors.WithLabelValues("500").Inc()
+			}
+
+			families, err := reg.Gather()
+			assert.NoError(t, err)
+
+			var names []string
+			for _, family := range families {
+				names = append(names, family.GetName())
+			}
+			for _, want := range tt.wantErrors {
+				assert.Contains(t, names, want)
+			}
+		})
+	}
+}
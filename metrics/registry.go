@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+	"github.com/rabellamy/promstrap/strategy"
+)
+
+// Registerer returns reg, or the Prometheus default registerer when reg is
+// nil.
+func Registerer(reg *prometheus.Registry) prometheus.Registerer {
+	if reg == nil {
+		return prometheus.DefaultRegisterer
+	}
+
+	return reg
+}
+
+// Handler returns the /metrics handler exposing reg, or the Prometheus
+// default registry when reg is nil.
+func Handler(reg *prometheus.Registry) http.Handler {
+	return HandlerWithOpts(reg, promhttp.HandlerOpts{})
+}
+
+// HandlerWithOpts is like Handler, but serves metrics with opts, e.g. to
+// bound how long a scrape may take and how many may run at once.
+func HandlerWithOpts(reg *prometheus.Registry, opts promhttp.HandlerOpts) http.Handler {
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if reg != nil {
+		gatherer = reg
+	}
+
+	return promhttp.InstrumentMetricHandler(Registerer(reg), promhttp.HandlerFor(gatherer, opts))
+}
+
+// WriteSnapshot writes the current metrics of reg, or of the Prometheus
+// default registry when reg is nil, to path in the text exposition format.
+// The file is replaced atomically, so a reader never sees a partial snapshot.
+func WriteSnapshot(reg *prometheus.Registry, path string) error {
+	var gatherer prometheus.Gatherer = prometheus.DefaultGatherer
+	if reg != nil {
+		gatherer = reg
+	}
+
+	families, err := gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp makes the file private; snapshots are as readable as /metrics
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	enc := expfmt.NewEncoder(tmp, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := enc.Encode(family); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to encode metrics: %w", err)
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Register registers the RED metrics with reg. It is like red.Register but
+// isn't tied to the Prometheus default registerer.
+func Register(reg prometheus.Registerer, red *strategy.RED) error {
+	collectors := []prometheus.Collector{red.Requests, red.Errors}
+	if red.Duration != nil {
+		if red.Duration.Histogram != nil {
+			collectors = append(collectors, red.Duration.Histogram)
+		}
+		if red.Duration.Summary != nil {
+			collectors = append(collectors, red.Duration.Summary)
+		}
+	}
+
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
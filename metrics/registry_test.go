@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		twice   bool
+		wantErr bool
+	}{
+		"fresh registry": {},
+		"already registered": {
+			twice:   true,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := prometheus.NewRegistry()
+			red, err := NewRED("test_register", "http", []string{"path", "verb"}, []string{"path"})
+			assert.NoError(t, err)
+
+			err = Register(reg, red)
+			if tt.twice {
+				assert.NoError(t, err)
+				err = Register(reg, red)
+			}
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			red.Requests.WithLabelValues("/", "GET").Inc()
+			families, err := reg.Gather()
+			assert.NoError(t, err)
+			assert.Len(t, families, 1)
+			assert.Equal(t, "test_register_http_requests_total", families[0].GetName())
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		reg  *prometheus.Registry
+		want string
+	}{
+		"default registry": {
+			want: "go_goroutines",
+		},
+		"custom registry": {
+			reg:  prometheus.NewRegistry(),
+			want: "promhttp_metric_handler_requests_total",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			Handler(tt.reg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+			body, err := io.ReadAll(rec.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Contains(t, string(body), tt.want)
+		})
+	}
+}
+
+// blockingCollector blocks every collection until release is closed.
+type blockingCollector struct {
+	collecting chan struct{}
+	release    chan struct{}
+}
+
+func (c *blockingCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+func (c *blockingCollector) Collect(ch chan<- prometheus.Metric) {
+	c.collecting <- struct{}{}
+	<-c.release
+}
+
+func TestHandlerWithOptsMaxRequestsInFlight(t *testing.T) {
+	t.Parallel()
+
+	collector := &blockingCollector{
+		collecting: make(chan struct{}, 1),
+		release:    make(chan struct{}),
+	}
+	reg := prometheus.NewRegistry()
+	assert.NoError(t, reg.Register(collector))
+
+	handler := HandlerWithOpts(reg, promhttp.HandlerOpts{MaxRequestsInFlight: 1})
+
+	// Hold the only slot with a scrape that blocks in the collector
+	first := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		first <- rec.Code
+	}()
+	<-collector.collecting
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	close(collector.release)
+	assert.Equal(t, http.StatusOK, <-first)
+}
+
+func TestWriteSnapshot(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		path    func(dir string) string
+		wantErr bool
+	}{
+		"writes snapshot": {
+			path: func(dir string) string { return filepath.Join(dir, "metrics.prom") },
+		},
+		"replaces existing snapshot": {
+			path: func(dir string) string {
+				path := filepath.Join(dir, "metrics.prom")
+				os.WriteFile(path, []byte("stale"), 0o644)
+				return path
+			},
+		},
+		"missing directory": {
+			path:    func(dir string) string { return filepath.Join(dir, "missing", "metrics.prom") },
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := prometheus.NewRegistry()
+			counter := prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "test_snapshot_total",
+				Help: "Test counter",
+			})
+			reg.MustRegister(counter)
+			counter.Add(3)
+
+			path := tt.path(t.TempDir())
+			err := WriteSnapshot(reg, path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			snapshot, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Contains(t, string(snapshot), "test_snapshot_total 3")
+			assert.NotContains(t, string(snapshot), "stale")
+
+			// No temporary files are left behind
+			entries, err := os.ReadDir(filepath.Dir(path))
+			assert.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
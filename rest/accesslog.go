@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// AccessLogMiddleware logs one line per request with its method, path,
+// status, duration, response body size and remote address.
+type AccessLogMiddleware struct {
+	logger *slog.Logger
+	next   http.Handler
+	skip   map[string]struct{}
+}
+
+// NewAccessLogMiddleware creates a new access logging middleware reporting to
+// logger at info level.
+func NewAccessLogMiddleware(logger *slog.Logger, next http.Handler) *AccessLogMiddleware {
+	return &AccessLogMiddleware{
+		logger: logger,
+		next:   next,
+		skip:   map[string]struct{}{},
+	}
+}
+
+// Skip excludes the given paths, such as health checks polled every few
+// seconds, from the access log.
+func (m *AccessLogMiddleware) Skip(paths ...string) {
+	for _, path := range paths {
+		m.skip[path] = struct{}{}
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *AccessLogMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.skip[r.URL.Path]; ok {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	start := time.Now()
+
+	rw := newResponseWriter(w)
+	defer rw.release()
+
+	m.next.ServeHTTP(rw, r)
+
+	m.logger.Info("request",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", rw.statusCode,
+		"duration", time.Since(start),
+		"bytes", rw.written,
+		"remote_addr", r.RemoteAddr,
+	)
+}
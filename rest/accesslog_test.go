@@ -0,0 +1,148 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method     string
+		path       string
+		skip       []string
+		wantLogged bool
+		wantStatus float64
+		wantBytes  float64
+	}{
+		"logged": {
+			method:     http.MethodGet,
+			path:       "/hello",
+			wantLogged: true,
+			wantStatus: http.StatusOK,
+			wantBytes:  float64(len("hello world")),
+		},
+		"error status": {
+			method:     http.MethodPost,
+			path:       "/missing",
+			wantLogged: true,
+			wantStatus: http.StatusNotFound,
+			wantBytes:  float64(len("404 page not found\n")),
+		},
+		"skipped path": {
+			method: http.MethodGet,
+			path:   "/health",
+			skip:   []string{"/health", "/metrics"},
+		},
+		"other paths logged when skipping": {
+			method:     http.MethodGet,
+			path:       "/hello",
+			skip:       []string{"/health", "/metrics"},
+			wantLogged: true,
+			wantStatus: http.StatusOK,
+			wantBytes:  float64(len("hello world")),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/hello" && r.URL.Path != "/health" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte("hello world"))
+			})
+
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+			accessLog := NewAccessLogMiddleware(logger, handler)
+			accessLog.Skip(tt.skip...)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			accessLog.ServeHTTP(httptest.NewRecorder(), req)
+
+			if !tt.wantLogged {
+				assert.Empty(t, logs.String())
+				return
+			}
+
+			var entry map[string]any
+			assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+			assert.Equal(t, "INFO", entry["level"])
+			assert.Equal(t, "request", entry["msg"])
+			assert.Equal(t, tt.method, entry["method"])
+			assert.Equal(t, tt.path, entry["path"])
+			assert.Equal(t, tt.wantStatus, entry["status"])
+			assert.Equal(t, tt.wantBytes, entry["bytes"])
+			assert.Equal(t, "192.0.2.1:1234", entry["remote_addr"])
+			assert.Contains(t, entry, "duration")
+		})
+	}
+}
+
+func TestNewServerAccessLog(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		accessLog  bool
+		path       string
+		wantLogged bool
+	}{
+		"disabled": {
+			path: "/hello",
+		},
+		"enabled": {
+			accessLog:  true,
+			path:       "/hello",
+			wantLogged: true,
+		},
+		"health skipped by default": {
+			accessLog: true,
+			path:      "/health",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace:          "test_access_log",
+				AccessLog:          tt.accessLog,
+				AccessLogSkipPaths: []string{"/health", "/livez", "/readyz", "/metrics"},
+				Registry:           prometheus.NewRegistry(),
+			}
+			routes := Routes{
+				"GET /hello": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("hello world"))
+				},
+			}
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			server, err := NewServer(context.Background(), config, routes, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+			logs.Reset()
+
+			server.mainServer.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantLogged, strings.Contains(logs.String(), `"path":"`+tt.path+`"`), logs.String())
+		})
+	}
+}
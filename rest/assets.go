@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+// DefaultRobotsTxt is served at /robots.txt when ServeRobotsTxt is set
+// without RobotsTxt. It asks crawlers to stay away from the whole API.
+const DefaultRobotsTxt = "User-agent: *\nDisallow: /\n"
+
+const (
+	robotsPath  = "/robots.txt"
+	faviconPath = "/favicon.ico"
+)
+
+// robotsHandler returns a handler serving content as /robots.txt, or
+// DefaultRobotsTxt when content is empty.
+func robotsHandler(content string) http.HandlerFunc {
+	if content == "" {
+		content = DefaultRobotsTxt
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(content))
+	}
+}
+
+// faviconHandler returns a handler serving icon, read from the file called
+// name, as /favicon.ico. Without an icon it answers 204 No Content, which
+// stops browsers asking without a 404.
+func faviconHandler(name string, icon []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if len(icon) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		// ServeContent sets the Content-Type from the file extension
+		http.ServeContent(w, r, filepath.Base(name), time.Time{}, bytes.NewReader(icon))
+	}
+}
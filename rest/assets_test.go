@@ -0,0 +1,136 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeAssets(t *testing.T) {
+	t.Parallel()
+
+	icon := []byte("\x00\x00\x01\x00fake icon")
+	iconFile := filepath.Join(t.TempDir(), "favicon.ico")
+	assert.NoError(t, os.WriteFile(iconFile, icon, 0o644))
+
+	tests := map[string]struct {
+		config     Config
+		routes     Routes
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		"default robots.txt disallows all": {
+			config:     Config{ServeRobotsTxt: true},
+			path:       "/robots.txt",
+			wantStatus: http.StatusOK,
+			wantBody:   DefaultRobotsTxt,
+		},
+		"custom robots.txt": {
+			config:     Config{ServeRobotsTxt: true, RobotsTxt: "User-agent: *\nAllow: /docs\n"},
+			path:       "/robots.txt",
+			wantStatus: http.StatusOK,
+			wantBody:   "User-agent: *\nAllow: /docs\n",
+		},
+		"robots.txt disabled": {
+			path:       "/robots.txt",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+		"favicon from file": {
+			config:     Config{ServeFavicon: true, FaviconFile: iconFile},
+			path:       "/favicon.ico",
+			wantStatus: http.StatusOK,
+			wantBody:   string(icon),
+		},
+		"favicon without file": {
+			config:     Config{ServeFavicon: true},
+			path:       "/favicon.ico",
+			wantStatus: http.StatusNoContent,
+		},
+		"favicon disabled": {
+			path:       "/favicon.ico",
+			wantStatus: http.StatusNotFound,
+			wantBody:   "404 page not found\n",
+		},
+		"user route takes precedence": {
+			config: Config{ServeRobotsTxt: true},
+			routes: Routes{
+				"/robots.txt": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("custom"))
+				},
+			},
+			path:       "/robots.txt",
+			wantStatus: http.StatusOK,
+			wantBody:   "custom",
+		},
+		"user method route takes precedence": {
+			config: Config{ServeFavicon: true},
+			routes: Routes{
+				"GET /favicon.ico": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("custom"))
+				},
+			},
+			path:       "/favicon.ico",
+			wantStatus: http.StatusOK,
+			wantBody:   "custom",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := tt.config
+			config.Namespace = "test_serve_assets"
+			config.MetricsHost = "127.0.0.1:2112"
+			config.Registry = prometheus.NewRegistry()
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, tt.routes, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+
+			// Built-in assets are left out of the RED metrics
+			builtIn := tt.routes == nil && (config.ServeRobotsTxt || config.ServeFavicon)
+			label := tt.path
+			if tt.wantStatus == http.StatusNotFound {
+				label = unmatchedRoute
+			}
+			recorded := testutil.ToFloat64(server.red.red.Requests.WithLabelValues(label, http.MethodGet))
+			assert.Equal(t, !builtIn, recorded > 0)
+		})
+	}
+}
+
+func TestServeFaviconMissingFile(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:    "test_serve_favicon_missing",
+		MetricsHost:  "127.0.0.1:2112",
+		ServeFavicon: true,
+		FaviconFile:  filepath.Join(t.TempDir(), "missing.ico"),
+		Registry:     prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewServer(context.Background(), config, Routes{}, logger)
+	assert.ErrorContains(t, err, "failed to read favicon")
+}
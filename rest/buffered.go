@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// bufferedResponse is an http.ResponseWriter that holds the whole response in
+// memory so it can be replayed to one or more clients later.
+type bufferedResponse struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+
+	// route is the pattern the ServeMux matched for the buffered request, so
+	// replays are labelled like the original in RED metrics
+	route routePattern
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{
+		header:     http.Header{},
+		statusCode: http.StatusOK,
+	}
+}
+
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *bufferedResponse) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func (r *bufferedResponse) WriteHeader(code int) {
+	r.statusCode = code
+}
+
+// recordRoute keeps the route pattern recorded for req, if any.
+func (r *bufferedResponse) recordRoute(req *http.Request) {
+	if route, ok := routePatternKey.Value(req.Context()); ok {
+		r.route = *route
+	}
+}
+
+// writeTo replays the buffered response onto w, and its route pattern onto
+// req's, as the requests a replay answers never reach the ServeMux.
+func (r *bufferedResponse) writeTo(w http.ResponseWriter, req *http.Request) {
+	if route, ok := routePatternKey.Value(req.Context()); ok && r.route.recorded {
+		*route = r.route
+	}
+	for k, values := range r.header {
+		w.Header()[k] = append([]string(nil), values...)
+	}
+	w.WriteHeader(r.statusCode)
+	w.Write(r.body.Bytes())
+}
@@ -0,0 +1,197 @@
+package rest
+
+import (
+	"container/list"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheMaxEntries is the number of responses a CacheMiddleware keeps
+// before evicting the least recently used one.
+const DefaultCacheMaxEntries = 1024
+
+// CacheMiddleware caches successful GET responses in memory for a fixed TTL,
+// keyed by the request's host, full URL and the request headers named in the
+// response's Vary header. Requests sending "Cache-Control: no-cache" bypass
+// the cache and refresh the stored entry. Requests carrying credentials
+// bypass it entirely, and responses marked private or no-store, setting
+// cookies or sending "Vary: *" aren't stored.
+type CacheMiddleware struct {
+	// MaxEntries is the maximum number of cached responses. Once reached, the
+	// least recently used entry is evicted.
+	MaxEntries int
+
+	ttl  time.Duration
+	next http.Handler
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// vary holds the request headers the last stored response for a host and
+	// URL varied on, which the keys of its entries include
+	vary map[string][]string
+}
+
+type cacheEntry struct {
+	key      string
+	base     string
+	response *bufferedResponse
+	storedAt time.Time
+}
+
+// NewCacheMiddleware creates a new response caching middleware.
+func NewCacheMiddleware(ttl time.Duration, next http.Handler) *CacheMiddleware {
+	return &CacheMiddleware{
+		MaxEntries: DefaultCacheMaxEntries,
+		ttl:        ttl,
+		next:       next,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+		vary:       map[string][]string{},
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *CacheMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Responses to credentialed requests may be personal, so they're never
+	// shared with other callers
+	if r.Header.Get("Authorization") != "" || r.Header.Get("Cookie") != "" {
+		w.Header().Set("X-Cache", "BYPASS")
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	base := r.Host + r.URL.String()
+	noCache := strings.Contains(r.Header.Get("Cache-Control"), "no-cache")
+
+	if !noCache {
+		if entry, ok := m.get(base, r); ok {
+			age := int(time.Since(entry.storedAt).Seconds())
+			w.Header().Set("Age", strconv.Itoa(age))
+			w.Header().Set("X-Cache", "HIT")
+			entry.response.writeTo(w, r)
+			return
+		}
+	}
+
+	resp := newBufferedResponse()
+	m.next.ServeHTTP(resp, r)
+	resp.recordRoute(r)
+
+	if resp.statusCode == http.StatusOK && storable(resp.header) {
+		m.set(base, r, resp)
+	}
+
+	if noCache {
+		w.Header().Set("X-Cache", "BYPASS")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	resp.writeTo(w, r)
+}
+
+func (m *CacheMiddleware) get(base string, r *http.Request) (*cacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := variantKey(base, m.vary[base], r)
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if time.Since(entry.storedAt) >= m.ttl {
+		m.lru.Remove(elem)
+		delete(m.entries, key)
+		return nil, false
+	}
+
+	m.lru.MoveToFront(elem)
+	return entry, true
+}
+
+func (m *CacheMiddleware) set(base string, r *http.Request, resp *bufferedResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := varyNames(resp.header)
+	if len(names) > 0 {
+		m.vary[base] = names
+	} else {
+		delete(m.vary, base)
+	}
+
+	key := variantKey(base, names, r)
+	entry := &cacheEntry{key: key, base: base, response: resp, storedAt: time.Now()}
+
+	if elem, ok := m.entries[key]; ok {
+		elem.Value = entry
+		m.lru.MoveToFront(elem)
+		return
+	}
+
+	m.entries[key] = m.lru.PushFront(entry)
+
+	for m.MaxEntries > 0 && m.lru.Len() > m.MaxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		evicted := oldest.Value.(*cacheEntry)
+		delete(m.entries, evicted.key)
+		// Other variants of the URL then miss and are stored again, which
+		// keeps vary from outgrowing the cache
+		delete(m.vary, evicted.base)
+	}
+}
+
+// storable reports whether a response with header may be shared with other
+// callers.
+func storable(header http.Header) bool {
+	cacheControl := strings.Join(header.Values("Cache-Control"), ",")
+	if strings.Contains(cacheControl, "private") || strings.Contains(cacheControl, "no-store") {
+		return false
+	}
+	if header.Get("Set-Cookie") != "" {
+		return false
+	}
+
+	return !slices.Contains(varyNames(header), "*")
+}
+
+// varyNames returns the canonical request header names listed in header's
+// Vary values.
+func varyNames(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+
+	return names
+}
+
+// variantKey extends base with r's values of the headers in names.
+func variantKey(base string, names []string, r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range names {
+		b.WriteString("\x00")
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+
+	return b.String()
+}
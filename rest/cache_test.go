@@ -0,0 +1,206 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheMiddleware(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		method       string
+		path         string
+		cacheControl string
+		host         string
+		header       map[string]string
+		wait         time.Duration
+		wantXCache   string
+		wantBody     string
+	}
+
+	tests := map[string]struct {
+		ttl        time.Duration
+		maxEntries int
+		status     int
+		respHeader map[string]string
+		requests   []request
+	}{
+		"cache hit": {
+			ttl:    time.Minute,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/foo?a=1", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo?a=1", wantXCache: "HIT", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo?a=2", wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"miss after ttl": {
+			ttl:    50 * time.Millisecond,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/foo", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", wait: 100 * time.Millisecond, wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"no-cache bypass": {
+			ttl:    time.Minute,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/foo", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", cacheControl: "no-cache", wantXCache: "BYPASS", wantBody: "2"},
+				{method: http.MethodGet, path: "/foo", wantXCache: "HIT", wantBody: "2"},
+			},
+		},
+		"non-200 not cached": {
+			ttl:    time.Minute,
+			status: http.StatusNotFound,
+			requests: []request{
+				{method: http.MethodGet, path: "/foo", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"POST not cached": {
+			ttl:    time.Minute,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodPost, path: "/foo", wantXCache: "", wantBody: "1"},
+				{method: http.MethodPost, path: "/foo", wantXCache: "", wantBody: "2"},
+			},
+		},
+		"private response not stored": {
+			ttl:        time.Minute,
+			status:     http.StatusOK,
+			respHeader: map[string]string{"Cache-Control": "private, max-age=60"},
+			requests: []request{
+				{method: http.MethodGet, path: "/me", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/me", wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"no-store response not stored": {
+			ttl:        time.Minute,
+			status:     http.StatusOK,
+			respHeader: map[string]string{"Cache-Control": "no-store"},
+			requests: []request{
+				{method: http.MethodGet, path: "/me", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/me", wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"response setting cookie not stored": {
+			ttl:        time.Minute,
+			status:     http.StatusOK,
+			respHeader: map[string]string{"Set-Cookie": "session=abc"},
+			requests: []request{
+				{method: http.MethodGet, path: "/login", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/login", wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"vary star not stored": {
+			ttl:        time.Minute,
+			status:     http.StatusOK,
+			respHeader: map[string]string{"Vary": "*"},
+			requests: []request{
+				{method: http.MethodGet, path: "/foo", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", wantXCache: "MISS", wantBody: "2"},
+			},
+		},
+		"authorization bypasses cache": {
+			ttl:    time.Minute,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/me", header: map[string]string{"Authorization": "Bearer alice"}, wantXCache: "BYPASS", wantBody: "1"},
+				{method: http.MethodGet, path: "/me", wantXCache: "MISS", wantBody: "2"},
+				{method: http.MethodGet, path: "/me", header: map[string]string{"Authorization": "Bearer bob"}, wantXCache: "BYPASS", wantBody: "3"},
+			},
+		},
+		"cookie bypasses cache": {
+			ttl:    time.Minute,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/me", header: map[string]string{"Cookie": "session=alice"}, wantXCache: "BYPASS", wantBody: "1"},
+				{method: http.MethodGet, path: "/me", header: map[string]string{"Cookie": "session=alice"}, wantXCache: "BYPASS", wantBody: "2"},
+			},
+		},
+		"host in key": {
+			ttl:    time.Minute,
+			status: http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/foo", host: "a.example.com", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", host: "b.example.com", wantXCache: "MISS", wantBody: "2"},
+				{method: http.MethodGet, path: "/foo", host: "a.example.com", wantXCache: "HIT", wantBody: "1"},
+			},
+		},
+		"vary headers in key": {
+			ttl:        time.Minute,
+			status:     http.StatusOK,
+			respHeader: map[string]string{"Vary": "Accept"},
+			requests: []request{
+				{method: http.MethodGet, path: "/foo", header: map[string]string{"Accept": "application/json"}, wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", header: map[string]string{"Accept": "text/plain"}, wantXCache: "MISS", wantBody: "2"},
+				{method: http.MethodGet, path: "/foo", header: map[string]string{"Accept": "application/json"}, wantXCache: "HIT", wantBody: "1"},
+				{method: http.MethodGet, path: "/foo", header: map[string]string{"Accept": "text/plain"}, wantXCache: "HIT", wantBody: "2"},
+			},
+		},
+		"max size eviction": {
+			ttl:        time.Minute,
+			maxEntries: 1,
+			status:     http.StatusOK,
+			requests: []request{
+				{method: http.MethodGet, path: "/a", wantXCache: "MISS", wantBody: "1"},
+				{method: http.MethodGet, path: "/b", wantXCache: "MISS", wantBody: "2"},
+				{method: http.MethodGet, path: "/a", wantXCache: "MISS", wantBody: "3"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			calls := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				for k, v := range tt.respHeader {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(strconv.Itoa(calls)))
+			})
+
+			middleware := NewCacheMiddleware(tt.ttl, handler)
+			if tt.maxEntries > 0 {
+				middleware.MaxEntries = tt.maxEntries
+			}
+
+			for _, r := range tt.requests {
+				time.Sleep(r.wait)
+
+				req := httptest.NewRequest(r.method, r.path, nil)
+				if r.cacheControl != "" {
+					req.Header.Set("Cache-Control", r.cacheControl)
+				}
+				if r.host != "" {
+					req.Host = r.host
+				}
+				for k, v := range r.header {
+					req.Header.Set(k, v)
+				}
+				rec := httptest.NewRecorder()
+
+				middleware.ServeHTTP(rec, req)
+
+				assert.Equal(t, tt.status, rec.Code)
+				assert.Equal(t, r.wantXCache, rec.Header().Get("X-Cache"))
+				assert.Equal(t, r.wantBody, rec.Body.String())
+				if r.wantXCache == "HIT" {
+					assert.Equal(t, "0", rec.Header().Get("Age"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ClientGone reports whether the client of r has disconnected. Long-running
+// handlers can check it, or select on r.Context().Done(), to stop work nobody
+// will receive. A request that hit a server-side deadline isn't reported as
+// gone.
+func ClientGone(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
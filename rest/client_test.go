@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientGone(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ctx  func() context.Context
+		want bool
+	}{
+		"connected": {
+			ctx:  context.Background,
+			want: false,
+		},
+		"cancelled": {
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			want: true,
+		},
+		"deadline exceeded": {
+			ctx: func() context.Context {
+				ctx, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0))
+				defer cancel()
+				return ctx
+			},
+			want: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(tt.ctx())
+			assert.Equal(t, tt.want, ClientGone(r))
+		})
+	}
+}
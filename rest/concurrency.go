@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConcurrencyLimitMiddleware caps the number of requests handled at once.
+// Requests beyond the limit are rejected immediately with a 503 and a
+// Retry-After header rather than queued. The health, liveness and readiness
+// endpoints are never limited.
+type ConcurrencyLimitMiddleware struct {
+	sem      chan struct{}
+	inFlight prometheus.Gauge
+	next     http.Handler
+}
+
+// NewConcurrencyLimitMiddleware creates a new concurrency limiting middleware
+// allowing at most limit in-flight requests.
+func NewConcurrencyLimitMiddleware(namespace string, limit int, next http.Handler) (*ConcurrencyLimitMiddleware, error) {
+	return newConcurrencyLimitMiddleware(prometheus.DefaultRegisterer, namespace, limit, next)
+}
+
+// newConcurrencyLimitMiddleware is like NewConcurrencyLimitMiddleware, but
+// registers its metrics with reg.
+func newConcurrencyLimitMiddleware(reg prometheus.Registerer, namespace string, limit int, next http.Handler) (*ConcurrencyLimitMiddleware, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("concurrency limit must be positive, got %d", limit)
+	}
+
+	inFlight := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "Number of requests currently being handled",
+	})
+	if err := reg.Register(inFlight); err != nil {
+		return nil, fmt.Errorf("failed to register in-flight metric: %w", err)
+	}
+
+	return &ConcurrencyLimitMiddleware{
+		sem:      make(chan struct{}, limit),
+		inFlight: inFlight,
+		next:     next,
+	}, nil
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *ConcurrencyLimitMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Failing probes under load would get a healthy instance restarted,
+	// making the overload worse
+	if probePath(r.URL.Path) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		w.Header().Set("Retry-After", "1")
+		WriteError(w, r, http.StatusServiceUnavailable, "too many concurrent requests")
+		return
+	}
+
+	m.inFlight.Inc()
+	defer func() {
+		m.inFlight.Dec()
+		<-m.sem
+	}()
+
+	m.next.ServeHTTP(w, r)
+}
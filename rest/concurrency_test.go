@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		limit     int
+		wantErr   bool
+	}{
+		"limit of one": {
+			namespace: "test_concurrency_limit_one",
+			limit:     1,
+		},
+		"limit of three": {
+			namespace: "test_concurrency_limit_three",
+			limit:     3,
+		},
+		"invalid limit": {
+			namespace: "test_concurrency_limit_invalid",
+			limit:     0,
+			wantErr:   true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					<-release
+				}
+			})
+
+			middleware, err := NewConcurrencyLimitMiddleware(tt.namespace, tt.limit, handler)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			// Fill every slot with a blocked request
+			var wg sync.WaitGroup
+			recs := make([]*httptest.ResponseRecorder, tt.limit)
+			for i := range recs {
+				recs[i] = httptest.NewRecorder()
+				wg.Add(1)
+				go func(rec *httptest.ResponseRecorder) {
+					defer wg.Done()
+					middleware.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+				}(recs[i])
+			}
+
+			assert.Eventually(t, func() bool {
+				return testutil.ToFloat64(middleware.inFlight) == float64(tt.limit)
+			}, time.Second, 10*time.Millisecond)
+
+			// The next request is over the limit
+			rejected := httptest.NewRecorder()
+			middleware.ServeHTTP(rejected, httptest.NewRequest(http.MethodGet, "/", nil))
+			assert.Equal(t, http.StatusServiceUnavailable, rejected.Code)
+			assert.Equal(t, "1", rejected.Header().Get("Retry-After"))
+
+			// Probes are still answered, so the instance isn't restarted
+			for _, path := range []string{healthPath, livePath, readyPath} {
+				probe := httptest.NewRecorder()
+				middleware.ServeHTTP(probe, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, http.StatusOK, probe.Code, path)
+			}
+
+			close(release)
+			wg.Wait()
+
+			for _, rec := range recs {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			}
+			assert.Equal(t, 0.0, testutil.ToFloat64(middleware.inFlight))
+		})
+	}
+}
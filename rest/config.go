@@ -1,36 +1,151 @@
 package rest
 
 import (
+	"fmt"
 	"time"
 
-	"github.com/kelseyhightower/envconfig"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/server/config"
 )
 
+// DefaultShutdownTimeout is used when Config.ShutdownTimeout is zero, which
+// would otherwise leave no time at all to shut down gracefully.
+const DefaultShutdownTimeout = 20 * time.Second
+
+// ShutdownOrder controls when the metrics server shuts down relative to the
+// main server.
+type ShutdownOrder string
+
+const (
+	// ShutdownMetricsLast shuts the metrics server down once the main
+	// server has drained, so metrics recorded during the drain can still be
+	// scraped.
+	ShutdownMetricsLast ShutdownOrder = "metrics-last"
+
+	// ShutdownMetricsFirst shuts the metrics server down before the main
+	// server, to stop scrapes of an instance that is going away.
+	ShutdownMetricsFirst ShutdownOrder = "metrics-first"
+
+	// ShutdownConcurrent shuts all servers down at once.
+	ShutdownConcurrent ShutdownOrder = "concurrent"
+)
+
+// parseShutdownOrder validates order, defaulting an empty one to
+// ShutdownMetricsLast.
+func parseShutdownOrder(order ShutdownOrder) (ShutdownOrder, error) {
+	switch order {
+	case "":
+		return ShutdownMetricsLast, nil
+	case ShutdownMetricsLast, ShutdownMetricsFirst, ShutdownConcurrent:
+		return order, nil
+	default:
+		return "", fmt.Errorf("invalid shutdown order %q", order)
+	}
+}
+
 type Config struct {
-	ReadTimeout        time.Duration `default:"5s"`
-	WriteTimeout       time.Duration `default:"10s"`
-	IdleTimeout        time.Duration `default:"120s"`
-	ShutdownTimeout    time.Duration `default:"20s"`
-	APIHost            string        `default:"0.0.0.0:3000"`
-	DebugHost          string        `default:"0.0.0.0:3010"`
-	MetricsHost        string        `default:"0.0.0.0:2112"`
-	CorsAllowedOrigins []string      `default:"*"`
-	MaxHeaderBytes     int           `default:"0"`
-	Build              string        `default:"dev"`
-	Desc               string        `default:"example server"`
-	Namespace          string
+	ReadTimeout                 time.Duration `default:"5s"`
+	WriteTimeout                time.Duration `default:"10s"`
+	IdleTimeout                 time.Duration `default:"120s"`
+	ShutdownTimeout             time.Duration `default:"20s"`
+	QuietShutdown               bool          `default:"false"`
+	ShutdownOrder               ShutdownOrder `default:"metrics-last"`
+	APIHost                     string        `default:"0.0.0.0:3000"`
+	DebugHost                   string        `default:"0.0.0.0:3010"`
+	EnableDebug                 bool          `default:"false"`
+	MetricsHost                 string        `default:"0.0.0.0:2112"`
+	ReusePort                   bool          `default:"false"`
+	EnableProxyProtocol         bool          `default:"false"`
+	ListenNetwork               string        `default:"tcp"`
+	ListenRetries               int           `default:"0"`
+	ListenRetryBackoff          time.Duration `default:"100ms"`
+	TCPKeepAlive                time.Duration `default:"3m"`
+	CorsAllowedOrigins          []string      `default:"*"`
+	AllowedHosts                []string
+	MaxHeaderBytes              int           `default:"0"`
+	MaxConcurrentRequests       int           `default:"0"`
+	MaxConnections              int           `default:"0"`
+	HandlerTimeout              time.Duration `default:"0s"`
+	Build                       string        `default:"dev"`
+	Desc                        string        `default:"example server"`
+	Namespace                   string
+	SingleFlightPaths           []string
+	OverheadMetrics             bool          `default:"false"`
+	TrackConnections            bool          `default:"false"`
+	FlushStreams                bool          `default:"false"`
+	FoldHeadMetrics             bool          `default:"false"`
+	RequireRoutes               bool          `default:"false"`
+	ServeRootInfo               bool          `default:"false"`
+	ReadinessDetail             bool          `default:"false"`
+	ReadinessTimeout            time.Duration `default:"5s"`
+	DisableHealthRoute          bool          `default:"false"`
+	DisableLivenessRoute        bool          `default:"false"`
+	DisableReadinessRoute       bool          `default:"false"`
+	ServeRobotsTxt              bool          `default:"false"`
+	ServeFavicon                bool          `default:"false"`
+	CacheTTL                    time.Duration `default:"0s"`
+	CacheMaxEntries             int           `default:"1024"`
+	SlowRequestThreshold        time.Duration `default:"0s"`
+	AccessLog                   bool          `default:"false"`
+	AccessLogSkipPaths          []string      `default:"/health,/livez,/readyz,/metrics"`
+	MaintenanceMode             bool          `default:"false"`
+	MaintenanceRetryAfter       time.Duration `default:"30s"`
+	MaintenanceFailHealth       bool          `default:"false"`
+	DisableMetricsServer        bool          `default:"false"`
+	DisableMetrics              bool          `default:"false"`
+	NativeHistogramBucketFactor float64       `default:"0"`
+	MetricsInheritTLS           bool          `default:"false"`
+	MetricsScrapeTimeout        time.Duration `default:"0s"`
+	MetricsMaxRequestsInFlight  int           `default:"0"`
+	HSTSMaxAge                  time.Duration `default:"0s"`
+	HSTSIncludeSubDomains       bool          `default:"false"`
+	HSTSPreload                 bool          `default:"false"`
+	HSTSTrustForwardedProto     bool          `default:"false"`
+	CleanPaths                  bool          `default:"false"`
+	RejectInvalidPaths          bool          `default:"false"`
+	LowercasePaths              bool          `default:"false"`
+	MethodTimeouts              map[string]time.Duration
+	MetricLabels                []string
+	MetricConstLabels           map[string]string
+	LogAttrs                    map[string]string
+	Subsystem                   string
+	TLSCertFile                 string
+	TLSKeyFile                  string
+	MetricsTLSCertFile          string
+	MetricsTLSKeyFile           string
+	MetricsSnapshotFile         string
+	MaintenanceBody             string
+	RobotsTxt                   string
+	FaviconFile                 string
+
+	// Registry, when set, is used instead of the Prometheus default registry
+	// for the server's metrics and its /metrics endpoint.
+	Registry *prometheus.Registry `ignored:"true"`
+
+	// OnPanic, when set, is called with every panic recovered from a handler,
+	// before the 500 response is written.
+	OnPanic PanicHandler `ignored:"true"`
 }
 
 func LoadConfig(prefix string) (Config, error) {
-	var c Config
-	err := envconfig.Process(prefix, &c)
-	if err != nil {
-		return c, err
-	}
+	return config.LoadConfig[Config](prefix)
+}
 
+// SetPrefixDefaults implements config.PrefixDefaulter: Namespace defaults to
+// the prefix the config was loaded with.
+func (c *Config) SetPrefixDefaults(prefix string) {
 	if c.Namespace == "" {
 		c.Namespace = prefix
 	}
+}
+
+// MetricsNamespace returns the namespace used for the server's metrics. When a
+// Subsystem is set it is appended to the Namespace, so several servers in one
+// process can share a Namespace while producing distinct metric names.
+func (c Config) MetricsNamespace() string {
+	if c.Subsystem == "" {
+		return c.Namespace
+	}
 
-	return c, nil
+	return c.Namespace + "_" + c.Subsystem
 }
@@ -22,18 +22,26 @@ func TestLoadConfig(t *testing.T) {
 			prefix: "test_defaults",
 			env:    map[string]string{},
 			want: Config{
-				ReadTimeout:        5 * time.Second,
-				WriteTimeout:       10 * time.Second,
-				IdleTimeout:        120 * time.Second,
-				ShutdownTimeout:    20 * time.Second,
-				APIHost:            "0.0.0.0:3000",
-				DebugHost:          "0.0.0.0:3010",
-				MetricsHost:        "0.0.0.0:2112",
-				CorsAllowedOrigins: []string{"*"},
-				MaxHeaderBytes:     0,
-				Build:              "dev",
-				Desc:               "example server",
-				Namespace:          "test_defaults",
+				ReadTimeout:           5 * time.Second,
+				WriteTimeout:          10 * time.Second,
+				IdleTimeout:           120 * time.Second,
+				ShutdownTimeout:       20 * time.Second,
+				ShutdownOrder:         ShutdownMetricsLast,
+				APIHost:               "0.0.0.0:3000",
+				ListenNetwork:         "tcp",
+				ListenRetryBackoff:    100 * time.Millisecond,
+				TCPKeepAlive:          3 * time.Minute,
+				DebugHost:             "0.0.0.0:3010",
+				MetricsHost:           "0.0.0.0:2112",
+				CorsAllowedOrigins:    []string{"*"},
+				MaxHeaderBytes:        0,
+				Build:                 "dev",
+				Desc:                  "example server",
+				Namespace:             "test_defaults",
+				CacheMaxEntries:       1024,
+				MaintenanceRetryAfter: 30 * time.Second,
+				ReadinessTimeout:      5 * time.Second,
+				AccessLogSkipPaths:    []string{"/health", "/livez", "/readyz", "/metrics"},
 			},
 			err: nil,
 		},
@@ -46,18 +54,57 @@ func TestLoadConfig(t *testing.T) {
 				"TEST_ENV_DEBUGHOST": "127.0.0.1:9091",
 			},
 			want: Config{
-				ReadTimeout:        5 * time.Second,
-				WriteTimeout:       10 * time.Second,
-				IdleTimeout:        120 * time.Second,
-				ShutdownTimeout:    20 * time.Second,
-				APIHost:            "127.0.0.1:9090",
-				DebugHost:          "127.0.0.1:9091",
-				MetricsHost:        "0.0.0.0:2112",
-				CorsAllowedOrigins: []string{"*"},
-				MaxHeaderBytes:     0,
-				Build:              "prod",
-				Desc:               "example server",
-				Namespace:          "custom_namespace",
+				ReadTimeout:           5 * time.Second,
+				WriteTimeout:          10 * time.Second,
+				IdleTimeout:           120 * time.Second,
+				ShutdownTimeout:       20 * time.Second,
+				ShutdownOrder:         ShutdownMetricsLast,
+				APIHost:               "127.0.0.1:9090",
+				ListenNetwork:         "tcp",
+				ListenRetryBackoff:    100 * time.Millisecond,
+				TCPKeepAlive:          3 * time.Minute,
+				DebugHost:             "127.0.0.1:9091",
+				MetricsHost:           "0.0.0.0:2112",
+				CorsAllowedOrigins:    []string{"*"},
+				MaxHeaderBytes:        0,
+				Build:                 "prod",
+				Desc:                  "example server",
+				Namespace:             "custom_namespace",
+				CacheMaxEntries:       1024,
+				MaintenanceRetryAfter: 30 * time.Second,
+				ReadinessTimeout:      5 * time.Second,
+				AccessLogSkipPaths:    []string{"/health", "/livez", "/readyz", "/metrics"},
+			},
+			err: nil,
+		},
+		"method timeouts": {
+			prefix: "test_method_timeouts",
+			env: map[string]string{
+				"TEST_METHOD_TIMEOUTS_HANDLERTIMEOUT": "10s",
+				"TEST_METHOD_TIMEOUTS_METHODTIMEOUTS": "GET:5s,POST:30s",
+			},
+			want: Config{
+				ReadTimeout:           5 * time.Second,
+				WriteTimeout:          10 * time.Second,
+				IdleTimeout:           120 * time.Second,
+				ShutdownTimeout:       20 * time.Second,
+				ShutdownOrder:         ShutdownMetricsLast,
+				APIHost:               "0.0.0.0:3000",
+				ListenNetwork:         "tcp",
+				ListenRetryBackoff:    100 * time.Millisecond,
+				TCPKeepAlive:          3 * time.Minute,
+				DebugHost:             "0.0.0.0:3010",
+				MetricsHost:           "0.0.0.0:2112",
+				CorsAllowedOrigins:    []string{"*"},
+				HandlerTimeout:        10 * time.Second,
+				MethodTimeouts:        map[string]time.Duration{"GET": 5 * time.Second, "POST": 30 * time.Second},
+				Build:                 "dev",
+				Desc:                  "example server",
+				Namespace:             "test_method_timeouts",
+				CacheMaxEntries:       1024,
+				MaintenanceRetryAfter: 30 * time.Second,
+				ReadinessTimeout:      5 * time.Second,
+				AccessLogSkipPaths:    []string{"/health", "/livez", "/readyz", "/metrics"},
 			},
 			err: nil,
 		},
@@ -96,3 +143,29 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config Config
+		want   string
+	}{
+		"namespace only": {
+			config: Config{Namespace: "app"},
+			want:   "app",
+		},
+		"namespace and subsystem": {
+			config: Config{Namespace: "app", Subsystem: "admin"},
+			want:   "app_admin",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, tt.config.MetricsNamespace())
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package rest
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// connTracker records http.Server connection state transitions as an
+// active-connections gauge and debug logs.
+type connTracker struct {
+	active prometheus.Gauge
+	logger *slog.Logger
+}
+
+func newConnTracker(reg prometheus.Registerer, namespace string, logger *slog.Logger) (*connTracker, error) {
+	active := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "active_connections",
+		Help:      "Number of open connections to the main server",
+	})
+	if err := reg.Register(active); err != nil {
+		return nil, fmt.Errorf("failed to register connection metrics: %w", err)
+	}
+
+	return &connTracker{
+		active: active,
+		logger: logger,
+	}, nil
+}
+
+// connState is an http.Server ConnState hook.
+func (t *connTracker) connState(conn net.Conn, state http.ConnState) {
+	t.logger.Debug("connection", "remote", conn.RemoteAddr().String(), "state", state.String())
+
+	switch state {
+	case http.StateNew:
+		t.active.Inc()
+	case http.StateClosed, http.StateHijacked:
+		t.active.Dec()
+	}
+}
@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackConnections(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		conns     int
+	}{
+		"single connection": {
+			namespace: "test_conn_state_single",
+			conns:     1,
+		},
+		"multiple connections": {
+			namespace: "test_conn_state_multiple",
+			conns:     3,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			config := Config{
+				Namespace:        tt.namespace,
+				TrackConnections: true,
+			}
+			server, err := NewServer(context.Background(), config, Routes{}, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			go server.mainServer.Serve(ln)
+			defer server.mainServer.Close()
+
+			conns := make([]net.Conn, tt.conns)
+			for i := range conns {
+				conns[i], err = net.Dial("tcp", ln.Addr().String())
+				assert.NoError(t, err)
+			}
+
+			assert.Eventually(t, func() bool {
+				return testutil.ToFloat64(server.connTracker.active) == float64(tt.conns)
+			}, time.Second, 10*time.Millisecond)
+
+			for _, conn := range conns {
+				conn.Close()
+			}
+
+			assert.Eventually(t, func() bool {
+				return testutil.ToFloat64(server.connTracker.active) == 0
+			}, time.Second, 10*time.Millisecond)
+
+			assert.Contains(t, logs.String(), "state=new")
+			assert.Contains(t, logs.String(), "state=closed")
+		})
+	}
+}
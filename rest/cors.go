@@ -0,0 +1,162 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultCORSMethods are the methods allowed in preflight responses when
+// CORSMiddleware.AllowMethods is empty.
+var DefaultCORSMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// CORSMiddleware sets Access-Control-Allow-Origin for origins in its allow
+// list and answers preflight requests itself, so they never reach the
+// routes. An allow list of exactly "*" sends a wildcard; any other list
+// echoes the request's Origin only when it matches.
+type CORSMiddleware struct {
+	// AllowMethods is sent as Access-Control-Allow-Methods in preflight
+	// responses. Defaults to DefaultCORSMethods.
+	AllowMethods []string
+	// AllowHeaders is sent as Access-Control-Allow-Headers in preflight
+	// responses. Empty echoes the headers the preflight asked for.
+	AllowHeaders []string
+
+	wildcard bool
+	origins  *originMatcher
+	next     http.Handler
+}
+
+// NewCORSMiddleware creates a new CORS middleware allowing origins, in the
+// format of CorsAllowedOrigins.
+func NewCORSMiddleware(origins []string, next http.Handler) *CORSMiddleware {
+	return &CORSMiddleware{
+		AllowMethods: DefaultCORSMethods,
+		wildcard:     len(origins) == 1 && origins[0] == "*",
+		origins:      newOriginMatcher(origins),
+		next:         next,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *CORSMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	header := w.Header()
+
+	if m.wildcard {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		// The response depends on the Origin, so caches mustn't share it
+		// across origins
+		header.Add("Vary", "Origin")
+		if m.origins.allowed(origin) {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+	}
+
+	if !isPreflight(r) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if header.Get("Access-Control-Allow-Origin") != "" {
+		methods := m.AllowMethods
+		if len(methods) == 0 {
+			methods = DefaultCORSMethods
+		}
+		header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+		if len(m.AllowHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(m.AllowHeaders, ", "))
+		} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isPreflight reports whether r is a CORS preflight request rather than a
+// plain OPTIONS request.
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// originMatcher decides whether a request's Origin is in CorsAllowedOrigins.
+// Services can allow many origins, so exact entries are kept in a set rather
+// than scanned per request. Entries of the form "https://*.example.com" match
+// any subdomain of example.com over that scheme, but not example.com itself,
+// and "*" allows every origin.
+type originMatcher struct {
+	any       bool
+	exact     map[string]struct{}
+	wildcards []originWildcard
+}
+
+// originWildcard is a "scheme://*.domain" entry split around its "*".
+type originWildcard struct {
+	prefix string
+	suffix string
+}
+
+// newOriginMatcher builds an originMatcher for the allowed origins.
+func newOriginMatcher(origins []string) *originMatcher {
+	m := &originMatcher{exact: make(map[string]struct{}, len(origins))}
+
+	for _, origin := range origins {
+		origin = strings.ToLower(strings.TrimSuffix(origin, "/"))
+		if origin == "*" {
+			m.any = true
+			continue
+		}
+		if prefix, suffix, ok := strings.Cut(origin, "*"); ok {
+			m.wildcards = append(m.wildcards, originWildcard{prefix: prefix, suffix: suffix})
+			continue
+		}
+		m.exact[origin] = struct{}{}
+	}
+
+	return m
+}
+
+// allowed reports whether origin may make cross-origin requests.
+func (m *originMatcher) allowed(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	if m.any {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if _, ok := m.exact[origin]; ok {
+		return true
+	}
+
+	for _, w := range m.wildcards {
+		// The suffix keeps its leading dot, so "*.example.com" never matches
+		// "example.com"
+		if len(origin) <= len(w.prefix)+len(w.suffix) ||
+			!strings.HasPrefix(origin, w.prefix) || !strings.HasSuffix(origin, w.suffix) {
+			continue
+		}
+
+		// The subdomain can't smuggle in a path or userinfo
+		sub := origin[len(w.prefix) : len(origin)-len(w.suffix)]
+		if !strings.ContainsAny(sub, "/@") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,296 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// manyOrigins returns n distinct exact origins.
+func manyOrigins(n int) []string {
+	origins := make([]string, 0, n)
+	for i := range n {
+		origins = append(origins, fmt.Sprintf("https://tenant%d.example.org", i))
+	}
+
+	return origins
+}
+
+func TestOriginMatcher(t *testing.T) {
+	t.Parallel()
+
+	large := append(manyOrigins(10000), "https://*.example.com", "http://localhost:3000")
+
+	tests := map[string]struct {
+		origins []string
+		origin  string
+		want    bool
+	}{
+		"first of a large list": {
+			origins: large,
+			origin:  "https://tenant0.example.org",
+			want:    true,
+		},
+		"last of a large list": {
+			origins: large,
+			origin:  "https://tenant9999.example.org",
+			want:    true,
+		},
+		"exact with port": {
+			origins: large,
+			origin:  "http://localhost:3000",
+			want:    true,
+		},
+		"case insensitive": {
+			origins: large,
+			origin:  "HTTPS://Tenant42.Example.org",
+			want:    true,
+		},
+		"not in a large list": {
+			origins: large,
+			origin:  "https://tenant10000.example.org",
+			want:    false,
+		},
+		"scheme must match": {
+			origins: large,
+			origin:  "http://tenant1.example.org",
+			want:    false,
+		},
+		"wildcard subdomain": {
+			origins: large,
+			origin:  "https://app.example.com",
+			want:    true,
+		},
+		"wildcard nested subdomain": {
+			origins: large,
+			origin:  "https://a.b.example.com",
+			want:    true,
+		},
+		"wildcard does not match apex": {
+			origins: large,
+			origin:  "https://example.com",
+			want:    false,
+		},
+		"wildcard scheme must match": {
+			origins: large,
+			origin:  "http://app.example.com",
+			want:    false,
+		},
+		"wildcard rejects suffix lookalike": {
+			origins: large,
+			origin:  "https://evilexample.com",
+			want:    false,
+		},
+		"wildcard rejects userinfo": {
+			origins: large,
+			origin:  "https://evil.com@x.example.com",
+			want:    false,
+		},
+		"star allows any": {
+			origins: []string{"*"},
+			origin:  "https://anything.test",
+			want:    true,
+		},
+		"missing origin": {
+			origins: []string{"*"},
+			origin:  "",
+			want:    false,
+		},
+		"trailing slash in config": {
+			origins: []string{"https://example.net/"},
+			origin:  "https://example.net",
+			want:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, newOriginMatcher(tt.origins).allowed(tt.origin))
+		})
+	}
+}
+
+func BenchmarkOriginMatcher(b *testing.B) {
+	for _, n := range []int{10, 1000, 100000} {
+		b.Run(fmt.Sprintf("origins=%d", n), func(b *testing.B) {
+			m := newOriginMatcher(append(manyOrigins(n), "https://*.example.com"))
+			origin := fmt.Sprintf("https://tenant%d.example.org", n-1)
+
+			b.ReportAllocs()
+			for b.Loop() {
+				m.allowed(origin)
+			}
+		})
+	}
+}
+
+func TestCORSMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		origins      []string
+		allowHeaders []string
+		method       string
+		header       map[string]string
+		wantStatus   int
+		wantHeader   map[string]string
+		wantVary     []string
+	}{
+		"wildcard": {
+			origins:    []string{"*"},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusTeapot,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "*"},
+		},
+		"allowed origin is echoed": {
+			origins:    []string{"https://app.example.com"},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusTeapot,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://app.example.com"},
+			wantVary:   []string{"Origin"},
+		},
+		"disallowed origin is omitted": {
+			origins:    []string{"https://app.example.com"},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://evil.example.net"},
+			wantStatus: http.StatusTeapot,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": ""},
+			wantVary:   []string{"Origin"},
+		},
+		"wildcard in a list echoes the origin": {
+			origins:    []string{"*", "https://app.example.com"},
+			method:     http.MethodGet,
+			header:     map[string]string{"Origin": "https://other.example.net"},
+			wantStatus: http.StatusTeapot,
+			wantHeader: map[string]string{"Access-Control-Allow-Origin": "https://other.example.net"},
+			wantVary:   []string{"Origin"},
+		},
+		"preflight": {
+			origins: []string{"https://app.example.com"},
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  http.MethodPut,
+				"Access-Control-Request-Headers": "Content-Type, X-Request-Id",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers": "Content-Type, X-Request-Id",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		"preflight with configured headers": {
+			origins:      []string{"*"},
+			allowHeaders: []string{"Content-Type"},
+			method:       http.MethodOptions,
+			header: map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  http.MethodPost,
+				"Access-Control-Request-Headers": "X-Anything",
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Headers": "Content-Type",
+			},
+			wantVary: []string{"Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		"preflight from disallowed origin": {
+			origins: []string{"https://app.example.com"},
+			method:  http.MethodOptions,
+			header: map[string]string{
+				"Origin":                        "https://evil.example.net",
+				"Access-Control-Request-Method": http.MethodDelete,
+			},
+			wantStatus: http.StatusNoContent,
+			wantHeader: map[string]string{
+				"Access-Control-Allow-Origin":  "",
+				"Access-Control-Allow-Methods": "",
+			},
+			wantVary: []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"},
+		},
+		"plain options reaches the handler": {
+			origins:    []string{"*"},
+			method:     http.MethodOptions,
+			header:     map[string]string{"Origin": "https://app.example.com"},
+			wantStatus: http.StatusTeapot,
+			wantHeader: map[string]string{"Access-Control-Allow-Methods": ""},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+			cors := NewCORSMiddleware(tt.origins, next)
+			cors.AllowHeaders = tt.allowHeaders
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			cors.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			for k, v := range tt.wantHeader {
+				assert.Equal(t, v, rec.Header().Get(k), k)
+			}
+			assert.Equal(t, tt.wantVary, rec.Header().Values("Vary"))
+		})
+	}
+}
+
+func TestNewServerCORS(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:          "test_new_server_cors",
+		MetricsHost:        "127.0.0.1:2112",
+		CorsAllowedOrigins: []string{"https://app.example.com"},
+		Registry:           prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	called := false
+	routes := Routes{
+		"/widgets": func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		},
+	}
+
+	server, err := NewServer(context.Background(), config, routes, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest(http.MethodOptions, "/widgets", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	rec := httptest.NewRecorder()
+	server.mainServer.Handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// The preflight never reaches the mux, so it is recorded as unmatched
+	assert.False(t, called)
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.red.red.Requests.WithLabelValues(unmatchedRoute, http.MethodOptions)))
+}
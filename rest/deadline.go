@@ -0,0 +1,21 @@
+package rest
+
+import (
+	"net/http"
+	"time"
+)
+
+// ExtendWriteDeadline gives the rest of the response to w d more time to be
+// written, counted from now, replacing the deadline set by WriteTimeout.
+// Streaming handlers, such as server-sent events, call it before each chunk,
+// so a stream can outlive WriteTimeout while a client that stops reading is
+// still cut off. Zero removes the deadline. It returns an error wrapping
+// http.ErrNotSupported when w can't set deadlines.
+func ExtendWriteDeadline(w http.ResponseWriter, d time.Duration) error {
+	var deadline time.Time
+	if d > 0 {
+		deadline = time.Now().Add(d)
+	}
+
+	return http.NewResponseController(w).SetWriteDeadline(deadline)
+}
@@ -0,0 +1,115 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendWriteDeadline(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		extend  bool
+		wantErr bool
+	}{
+		"stream outlives write timeout": {
+			extend: true,
+		},
+		"stream cut off by write timeout": {
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       "test_extend_write_deadline",
+				APIHost:         addr,
+				MetricsHost:     "127.0.0.1:0",
+				WriteTimeout:    200 * time.Millisecond,
+				ShutdownTimeout: 5 * time.Second,
+				Registry:        prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			// The stream runs for well over WriteTimeout, with each chunk
+			// well within it
+			done := make(chan struct{})
+			routes := Routes{
+				"/events": func(w http.ResponseWriter, r *http.Request) {
+					defer close(done)
+					w.Header().Set("Content-Type", "text/event-stream")
+					for i := range 8 {
+						if tt.extend {
+							assert.NoError(t, ExtendWriteDeadline(w, 200*time.Millisecond))
+						}
+						if _, err := fmt.Fprintf(w, "data: %d\n\n", i); err != nil {
+							return
+						}
+						if err := http.NewResponseController(w).Flush(); err != nil {
+							return
+						}
+						time.Sleep(50 * time.Millisecond)
+					}
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, routes, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			resp, err := http.Get("http://" + addr + "/events")
+			if assert.NoError(t, err) {
+				body, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if tt.wantErr {
+					assert.Error(t, err)
+				} else {
+					assert.NoError(t, err)
+					assert.Contains(t, string(body), "data: 7\n\n")
+				}
+			}
+
+			<-done
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+
+	t.Run("unsupported writer", func(t *testing.T) {
+		t.Parallel()
+
+		assert.ErrorIs(t, ExtendWriteDeadline(httptest.NewRecorder(), time.Second), http.ErrNotSupported)
+	})
+}
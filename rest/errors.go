@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/munnerz/goautoneg"
+)
+
+// errorResponse is the JSON body WriteError sends.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// WriteError responds with status and message, as JSON of the form
+// {"error": "message"} when the request's Accept header prefers
+// application/json and as plain text otherwise, so browsers and API clients
+// can share an endpoint. The server's own error responses use it.
+func WriteError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	w.Header().Add("Vary", "Accept")
+	if !acceptsJSON(r) {
+		http.Error(w, message, status)
+		return
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
+
+// acceptsJSON reports whether r's Accept header prefers JSON to plain text.
+// Plain text wins ties, so requests without an Accept header or accepting
+// anything get it.
+func acceptsJSON(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return false
+	}
+
+	return goautoneg.Negotiate(accept, []string{"text/plain", "application/json"}) == "application/json"
+}
+
+// negotiateMuxErrors wraps mux so the 404 and 405 responses it writes for
+// requests no route matched go through WriteError. Matched requests are
+// served as is.
+func negotiateMuxErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		mux.ServeHTTP(&muxErrorWriter{ResponseWriter: w, r: r}, r)
+	})
+}
+
+// muxErrorWriter replaces the plain text body of a ServeMux's 404 or 405
+// response with WriteError's. Other responses, such as the redirects the
+// mux sends to clean paths, pass through.
+type muxErrorWriter struct {
+	http.ResponseWriter
+	r        *http.Request
+	replaced bool
+}
+
+// WriteHeader implements the http.ResponseWriter interface.
+func (w *muxErrorWriter) WriteHeader(code int) {
+	if code != http.StatusNotFound && code != http.StatusMethodNotAllowed {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.replaced = true
+	WriteError(w.ResponseWriter, w.r, code, strings.ToLower(http.StatusText(code)))
+}
+
+// Write implements the http.ResponseWriter interface, discarding the mux's
+// body once it has been replaced.
+func (w *muxErrorWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+
+	return w.ResponseWriter.Write(b)
+}
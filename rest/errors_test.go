@@ -0,0 +1,153 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		"json": {
+			accept:          "application/json",
+			wantContentType: "application/json",
+			wantBody:        `{"error":"invalid host"}` + "\n",
+		},
+		"json preferred by quality": {
+			accept:          "text/plain;q=0.5, application/json",
+			wantContentType: "application/json",
+			wantBody:        `{"error":"invalid host"}` + "\n",
+		},
+		"plain text": {
+			accept:          "text/plain",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "invalid host\n",
+		},
+		"browser gets plain text": {
+			accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "invalid host\n",
+		},
+		"wildcard gets plain text": {
+			accept:          "*/*",
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "invalid host\n",
+		},
+		"no accept header gets plain text": {
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "invalid host\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			WriteError(rec, req, http.StatusBadRequest, "invalid host")
+
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestNegotiateMuxErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method          string
+		path            string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+		wantAllow       string
+		wantLocation    string
+	}{
+		"not found as json": {
+			method:          http.MethodGet,
+			path:            "/missing",
+			accept:          "application/json",
+			wantStatus:      http.StatusNotFound,
+			wantContentType: "application/json",
+			wantBody:        `{"error":"not found"}` + "\n",
+		},
+		"not found as plain text": {
+			method:          http.MethodGet,
+			path:            "/missing",
+			wantStatus:      http.StatusNotFound,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "404 page not found\n",
+		},
+		"method not allowed as json": {
+			method:          http.MethodPost,
+			path:            "/hello",
+			accept:          "application/json",
+			wantStatus:      http.StatusMethodNotAllowed,
+			wantContentType: "application/json",
+			wantBody:        `{"error":"method not allowed"}` + "\n",
+			wantAllow:       "GET, HEAD",
+		},
+		"matched route untouched": {
+			method:          http.MethodGet,
+			path:            "/hello",
+			accept:          "application/json",
+			wantStatus:      http.StatusNotFound,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "no greeting\n",
+		},
+		"redirect passes through": {
+			method:       http.MethodGet,
+			path:         "/hello/../hello",
+			accept:       "application/json",
+			wantLocation: "/hello",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			// A route's own 404 isn't the mux's to replace
+			mux.HandleFunc("GET /hello", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "no greeting", http.StatusNotFound)
+			})
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+
+			negotiateMuxErrors(mux).ServeHTTP(rec, req)
+
+			if tt.wantLocation != "" {
+				// The mux's redirect status varies across Go versions
+				assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+				return
+			}
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, tt.wantAllow, rec.Header().Get("Allow"))
+		})
+	}
+}
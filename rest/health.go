@@ -0,0 +1,134 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// healthPath is where the health endpoint is served.
+	healthPath = "/health"
+	// livePath is where the liveness endpoint is served.
+	livePath = "/livez"
+	// readyPath is where the readiness endpoint is served.
+	readyPath = "/readyz"
+)
+
+// probePath reports whether path is one of the endpoints orchestrators probe.
+func probePath(path string) bool {
+	return path == healthPath || path == livePath || path == readyPath
+}
+
+// healthStatus is the JSON body served at the health and readiness
+// endpoints.
+type healthStatus struct {
+	Status string `json:"status"`
+	// Failed lists the readiness checks that failed, when detail is enabled.
+	Failed []failedCheck `json:"failed,omitempty"`
+}
+
+// failedCheck describes a readiness check that failed. Error is only set
+// when detail is enabled.
+type failedCheck struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// ReadinessCheck reports whether a dependency the server needs is available,
+// returning an error describing why when it isn't.
+type ReadinessCheck func(ctx context.Context) error
+
+// readinessCheck is a ReadinessCheck and the name it reports under.
+type readinessCheck struct {
+	name  string
+	check ReadinessCheck
+}
+
+// healthHandler returns a handler answering 200 with status "ok" while ready
+// reports true, and 503 with status "unavailable" otherwise. A nil ready is
+// always ready.
+func healthHandler(ready func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code, status := http.StatusOK, "ok"
+		if ready != nil && !ready() {
+			code, status = http.StatusServiceUnavailable, "unavailable"
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(healthStatus{Status: status})
+	}
+}
+
+// readinessHandler serves the readiness endpoint: 200 while ready reports
+// true and every check passes, 503 otherwise. A nil ready is always ready.
+// Checks share a deadline of timeout, when set, and the remaining checks are
+// skipped once the request's context is done. With detail set, a 503 lists
+// the checks that failed and their errors. Errors can reveal internals, so
+// detail is off by default.
+type readinessHandler struct {
+	ready   func() bool
+	detail  bool
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []readinessCheck
+}
+
+// add adds a check. It is safe to call while requests are being served.
+func (h *readinessHandler) add(c readinessCheck) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks = append(h.checks, c)
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *readinessHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	code, body := http.StatusOK, healthStatus{Status: "ok"}
+	if h.ready != nil && !h.ready() {
+		code, body.Status = http.StatusServiceUnavailable, "unavailable"
+	}
+
+	ctx := r.Context()
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+
+	h.mu.RLock()
+	checks := slices.Clone(h.checks)
+	h.mu.RUnlock()
+
+	for _, c := range checks {
+		err := c.check(ctx)
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			continue
+		}
+
+		code, body.Status = http.StatusServiceUnavailable, "unavailable"
+		if h.detail {
+			body.Failed = append(body.Failed, failedCheck{Name: c.name, Error: err.Error()})
+		}
+
+		// Once the deadline has passed or the client is gone, the
+		// remaining checks can't succeed either
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
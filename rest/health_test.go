@@ -0,0 +1,307 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		ready      func() bool
+		wantStatus int
+		wantBody   string
+	}{
+		"no readiness check": {
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ok"}` + "\n",
+		},
+		"ready": {
+			ready:      func() bool { return true },
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ok"}` + "\n",
+		},
+		"not ready": {
+			ready:      func() bool { return false },
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"unavailable"}` + "\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			healthHandler(tt.ready).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, healthPath, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestHealthDuringShutdown(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:       "test_health_during_shutdown",
+		MetricsHost:     "127.0.0.1:2112",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := NewServer(context.Background(), config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	status := func(path string) int {
+		rec := httptest.NewRecorder()
+		server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status(healthPath))
+	assert.Equal(t, http.StatusOK, status(readyPath))
+	assert.Equal(t, http.StatusOK, status(livePath))
+	assert.NoError(t, server.shutdownAndSnapshot(context.Background(), nil))
+	assert.Equal(t, http.StatusServiceUnavailable, status(healthPath))
+	assert.Equal(t, http.StatusServiceUnavailable, status(readyPath))
+	// The process is still alive while it drains
+	assert.Equal(t, http.StatusOK, status(livePath))
+}
+
+func TestSetReady(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:       "test_set_ready",
+		MetricsHost:     "127.0.0.1:2112",
+		MaintenanceMode: true,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := NewServer(context.Background(), config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// Maintenance fails readiness, but never liveness
+	server.SetMaintenance(false)
+
+	status := func(path string) int {
+		rec := httptest.NewRecorder()
+		server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status(readyPath))
+
+	server.SetReady(false)
+	assert.Equal(t, http.StatusServiceUnavailable, status(readyPath))
+	assert.Equal(t, http.StatusOK, status(livePath))
+	// /health only reports shutdown
+	assert.Equal(t, http.StatusOK, status(healthPath))
+
+	server.SetReady(true)
+	assert.Equal(t, http.StatusOK, status(readyPath))
+
+	server.SetMaintenance(true)
+	assert.Equal(t, http.StatusServiceUnavailable, status(readyPath))
+	assert.Equal(t, http.StatusOK, status(livePath))
+}
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+
+	dbDown := WithReadinessCheck("db", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	cacheUp := WithReadinessCheck("cache", func(ctx context.Context) error {
+		return nil
+	})
+
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	tests := map[string]struct {
+		opts       []Option
+		added      []readinessCheck
+		detail     bool
+		wantStatus int
+		wantBody   string
+	}{
+		"added check": {
+			opts:       []Option{cacheUp},
+			added:      []readinessCheck{{name: "queue", check: func(ctx context.Context) error { return errors.New("no broker") }}},
+			detail:     true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"unavailable","failed":[{"name":"queue","error":"no broker"}]}` + "\n",
+		},
+		"timed out check": {
+			added:      []readinessCheck{{name: "slow", check: slow}},
+			detail:     true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"unavailable","failed":[{"name":"slow","error":"context deadline exceeded"}]}` + "\n",
+		},
+		"no checks": {
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ok"}` + "\n",
+		},
+		"passing checks": {
+			opts:       []Option{cacheUp},
+			detail:     true,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"status":"ok"}` + "\n",
+		},
+		"failing check without detail": {
+			opts:       []Option{cacheUp, dbDown},
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"unavailable"}` + "\n",
+		},
+		"failing check with detail": {
+			opts:       []Option{cacheUp, dbDown},
+			detail:     true,
+			wantStatus: http.StatusServiceUnavailable,
+			wantBody:   `{"status":"unavailable","failed":[{"name":"db","error":"connection refused"}]}` + "\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace:        "test_readiness",
+				MetricsHost:      "127.0.0.1:2112",
+				ReadinessDetail:  tt.detail,
+				ReadinessTimeout: 50 * time.Millisecond,
+				Registry:         prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, Routes{}, logger, tt.opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+			for _, c := range tt.added {
+				server.AddReadinessCheck(c.name, c.check)
+			}
+
+			rec := httptest.NewRecorder()
+			server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, readyPath, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+		})
+	}
+
+	t.Run("checks after a timeout are skipped", func(t *testing.T) {
+		t.Parallel()
+
+		h := &readinessHandler{timeout: 50 * time.Millisecond}
+		h.add(readinessCheck{name: "slow", check: slow})
+		h.add(readinessCheck{name: "after", check: func(ctx context.Context) error {
+			t.Error("check ran after the deadline")
+			return nil
+		}})
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, readyPath, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
+
+func TestDisableHealthRoutes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config   Config
+		disabled string
+	}{
+		"none disabled": {},
+		"health disabled": {
+			config:   Config{DisableHealthRoute: true},
+			disabled: healthPath,
+		},
+		"liveness disabled": {
+			config:   Config{DisableLivenessRoute: true},
+			disabled: livePath,
+		},
+		"readiness disabled": {
+			config:   Config{DisableReadinessRoute: true},
+			disabled: readyPath,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := tt.config
+			config.Namespace = "test_disable_health_routes"
+			config.MetricsHost = "127.0.0.1:2112"
+			config.Registry = prometheus.NewRegistry()
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, Routes{}, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			for _, path := range []string{healthPath, livePath, readyPath} {
+				rec := httptest.NewRecorder()
+				server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+				want := http.StatusOK
+				if path == tt.disabled {
+					want = http.StatusNotFound
+				}
+				assert.Equal(t, want, rec.Code, path)
+			}
+		})
+	}
+
+	t.Run("own health route", func(t *testing.T) {
+		t.Parallel()
+
+		config := Config{
+			Namespace:          "test_own_health_route",
+			MetricsHost:        "127.0.0.1:2112",
+			DisableHealthRoute: true,
+			Registry:           prometheus.NewRegistry(),
+		}
+		routes := Routes{
+			healthPath: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+		}
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+		server, err := NewServer(context.Background(), config, routes, logger)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, healthPath, nil))
+		assert.Equal(t, http.StatusTeapot, rec.Code)
+	})
+}
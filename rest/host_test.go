@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		allowedHosts []string
+		host         string
+		want         int
+	}{
+		"allowed exact": {
+			allowedHosts: []string{"api.example.com"},
+			host:         "api.example.com",
+			want:         http.StatusOK,
+		},
+		"allowed exact with port": {
+			allowedHosts: []string{"api.example.com"},
+			host:         "api.example.com:8080",
+			want:         http.StatusOK,
+		},
+		"allowed entry with port": {
+			allowedHosts: []string{"10.0.0.5:8080"},
+			host:         "10.0.0.5:8080",
+			want:         http.StatusOK,
+		},
+		"allowed entry with port matches without port": {
+			allowedHosts: []string{"10.0.0.5:8080"},
+			host:         "10.0.0.5",
+			want:         http.StatusOK,
+		},
+		"allowed IPv6 entry": {
+			allowedHosts: []string{"[::1]"},
+			host:         "[::1]:3000",
+			want:         http.StatusOK,
+		},
+		"allowed exact case insensitive": {
+			allowedHosts: []string{"API.example.com"},
+			host:         "api.EXAMPLE.com",
+			want:         http.StatusOK,
+		},
+		"allowed wildcard": {
+			allowedHosts: []string{"*.example.com"},
+			host:         "tenant.example.com",
+			want:         http.StatusOK,
+		},
+		"allowed nested wildcard": {
+			allowedHosts: []string{"*.example.com"},
+			host:         "a.b.example.com",
+			want:         http.StatusOK,
+		},
+		"wildcard does not match apex": {
+			allowedHosts: []string{"*.example.com"},
+			host:         "example.com",
+			want:         http.StatusBadRequest,
+		},
+		"rejected host": {
+			allowedHosts: []string{"api.example.com", "*.example.com"},
+			host:         "evil.com",
+			want:         http.StatusBadRequest,
+		},
+		"rejected suffix lookalike": {
+			allowedHosts: []string{"*.example.com"},
+			host:         "evilexample.com",
+			want:         http.StatusBadRequest,
+		},
+		"empty allowlist skips validation": {
+			allowedHosts: nil,
+			host:         "anything.com",
+			want:         http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			middleware := NewHostMiddleware(tt.allowedHosts, handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HSTSPreloadMinMaxAge is the shortest max-age the HSTS preload list
+// accepts.
+const HSTSPreloadMinMaxAge = 365 * 24 * time.Hour
+
+// HSTSMiddleware sets the Strict-Transport-Security header on responses to
+// HTTPS requests, telling browsers to only reach the host over HTTPS.
+// Browsers ignore the header over plaintext HTTP, so it isn't sent there.
+type HSTSMiddleware struct {
+	header              string
+	trustForwardedProto bool
+	next                http.Handler
+}
+
+// NewHSTSMiddleware creates a new HSTS middleware. Preload requires
+// includeSubDomains and a maxAge of at least HSTSPreloadMinMaxAge, as the
+// preload list does.
+func NewHSTSMiddleware(maxAge time.Duration, includeSubDomains, preload bool, next http.Handler) (*HSTSMiddleware, error) {
+	if maxAge <= 0 {
+		return nil, errors.New("hsts max-age must be positive")
+	}
+	if preload {
+		if maxAge < HSTSPreloadMinMaxAge {
+			return nil, errors.New("hsts preload requires a max-age of at least one year")
+		}
+		if !includeSubDomains {
+			return nil, errors.New("hsts preload requires includeSubDomains")
+		}
+	}
+
+	header := "max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+	if includeSubDomains {
+		header += "; includeSubDomains"
+	}
+	if preload {
+		header += "; preload"
+	}
+
+	return &HSTSMiddleware{header: header, next: next}, nil
+}
+
+// TrustForwardedProto treats requests with an X-Forwarded-Proto: https
+// header as HTTPS, for servers behind a proxy that terminates TLS. Only use
+// it when that proxy sets or strips the header, as clients can forge it.
+func (m *HSTSMiddleware) TrustForwardedProto() {
+	m.trustForwardedProto = true
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *HSTSMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.secure(r) {
+		w.Header().Set("Strict-Transport-Security", m.header)
+	}
+	m.next.ServeHTTP(w, r)
+}
+
+// secure reports whether r reached the server over HTTPS.
+func (m *HSTSMiddleware) secure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	return m.trustForwardedProto && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHSTSMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		maxAge            time.Duration
+		includeSubDomains bool
+		preload           bool
+		trustForwarded    bool
+		plaintext         bool
+		forwardedProto    string
+		want              string
+		wantErr           string
+	}{
+		"max-age only": {
+			maxAge: 24 * time.Hour,
+			want:   "max-age=86400",
+		},
+		"include subdomains": {
+			maxAge:            24 * time.Hour,
+			includeSubDomains: true,
+			want:              "max-age=86400; includeSubDomains",
+		},
+		"preload": {
+			maxAge:            2 * 365 * 24 * time.Hour,
+			includeSubDomains: true,
+			preload:           true,
+			want:              "max-age=63072000; includeSubDomains; preload",
+		},
+		"preload with short max-age": {
+			maxAge:            30 * 24 * time.Hour,
+			includeSubDomains: true,
+			preload:           true,
+			wantErr:           "hsts preload requires a max-age of at least one year",
+		},
+		"preload without subdomains": {
+			maxAge:  HSTSPreloadMinMaxAge,
+			preload: true,
+			wantErr: "hsts preload requires includeSubDomains",
+		},
+		"plaintext": {
+			maxAge:    24 * time.Hour,
+			plaintext: true,
+		},
+		"forwarded https not trusted": {
+			maxAge:         24 * time.Hour,
+			plaintext:      true,
+			forwardedProto: "https",
+		},
+		"forwarded https trusted": {
+			maxAge:         24 * time.Hour,
+			trustForwarded: true,
+			plaintext:      true,
+			forwardedProto: "https",
+			want:           "max-age=86400",
+		},
+		"forwarded http trusted": {
+			maxAge:         24 * time.Hour,
+			trustForwarded: true,
+			plaintext:      true,
+			forwardedProto: "http",
+		},
+		"zero max-age": {
+			wantErr: "hsts max-age must be positive",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			})
+
+			m, err := NewHSTSMiddleware(tt.maxAge, tt.includeSubDomains, tt.preload, next)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.trustForwarded {
+				m.TrustForwardedProto()
+			}
+
+			url := "https://example.com/"
+			if tt.plaintext {
+				url = "http://example.com/"
+			}
+			req := httptest.NewRequest(http.MethodGet, url, nil)
+			if tt.forwardedProto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.forwardedProto)
+			}
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusTeapot, rec.Code)
+			assert.Equal(t, tt.want, rec.Header().Get("Strict-Transport-Security"))
+		})
+	}
+}
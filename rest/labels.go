@@ -0,0 +1,66 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/rabellamy/server/internal/ctxkey"
+)
+
+// metricLabelsKey is the context key under which the REDMiddleware stores the
+// extra metric labels handlers may set for a request.
+var metricLabelsKey = ctxkey.New[*metricLabels]("metric labels")
+
+// metricLabels holds the values of a request's extra metric labels, keyed by
+// the allowlisted label names declared on the REDMiddleware.
+type metricLabels struct {
+	names  []string
+	values map[string]string
+}
+
+func newMetricLabels(names []string) *metricLabels {
+	l := &metricLabels{
+		names:  names,
+		values: make(map[string]string, len(names)),
+	}
+	for _, name := range names {
+		l.values[name] = ""
+	}
+
+	return l
+}
+
+// list returns the label values in the order the names were declared.
+func (l *metricLabels) list() []string {
+	values := make([]string, len(l.names))
+	for i, name := range l.names {
+		values[i] = l.values[name]
+	}
+
+	return values
+}
+
+// SetMetricLabel sets an extra label recorded on the request's RED metrics,
+// e.g. SetMetricLabel(r, "tenant", tenantID). Only label names passed to
+// NewREDMiddleware are recorded; any other name is ignored so cardinality
+// stays bounded. Labels a handler doesn't set are recorded as empty.
+func SetMetricLabel(r *http.Request, name, value string) {
+	labels, ok := metricLabelsKey.Value(r.Context())
+	if !ok {
+		return
+	}
+
+	if _, allowed := labels.values[name]; allowed {
+		labels.values[name] = value
+	}
+}
+
+// withMetricLabels attaches the extra metric labels to the request when any
+// were declared.
+func (m *REDMiddleware) withMetricLabels(r *http.Request) (*http.Request, *metricLabels) {
+	if len(m.extraLabels) == 0 {
+		return r, nil
+	}
+
+	labels := newMetricLabels(m.extraLabels)
+	return r.WithContext(metricLabelsKey.WithValue(r.Context(), labels)), labels
+}
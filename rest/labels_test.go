@@ -0,0 +1,74 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMetricLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace   string
+		extraLabels []string
+		set         map[string]string
+		wantValues  []string
+	}{
+		"allowlisted label is recorded": {
+			namespace:   "test_metric_label_allowed",
+			extraLabels: []string{"tenant"},
+			set:         map[string]string{"tenant": "acme"},
+			wantValues:  []string{"acme"},
+		},
+		"unknown label is ignored": {
+			namespace:   "test_metric_label_unknown",
+			extraLabels: []string{"tenant"},
+			set:         map[string]string{"tenant": "acme", "user": "bob"},
+			wantValues:  []string{"acme"},
+		},
+		"unset label is empty": {
+			namespace:   "test_metric_label_unset",
+			extraLabels: []string{"tenant", "plan"},
+			set:         map[string]string{"plan": "pro"},
+			wantValues:  []string{"", "pro"},
+		},
+		"no extra labels": {
+			namespace:  "test_metric_label_none",
+			set:        map[string]string{"tenant": "acme"},
+			wantValues: nil,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.set {
+					SetMetricLabel(r, k, v)
+				}
+			})
+
+			middleware, err := NewREDMiddleware(tt.namespace, handler, tt.extraLabels...)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/labels", nil)
+			rec := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rec, req)
+
+			requestValues := append([]string{unmatchedRoute, http.MethodGet}, tt.wantValues...)
+			assert.Equal(t, 1.0, testutil.ToFloat64(middleware.red.Requests.WithLabelValues(requestValues...)))
+			assert.Equal(t, 1, testutil.CollectAndCount(middleware.red.Requests))
+
+			durationValues := append([]string{unmatchedRoute}, tt.wantValues...)
+			histogramSum(t, middleware.red.Duration.Histogram.WithLabelValues(durationValues...))
+		})
+	}
+}
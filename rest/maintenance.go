@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaintenanceBody is the response body sent while in maintenance mode
+// when none is configured.
+const DefaultMaintenanceBody = "service under maintenance"
+
+// MaintenanceMiddleware rejects requests with a 503 while maintenance mode is
+// enabled, without shutting the server down. The health endpoint keeps
+// answering unless FailHealth is set, and the liveness endpoint always does.
+type MaintenanceMiddleware struct {
+	// RetryAfter is sent as the Retry-After header. Zero omits the header.
+	RetryAfter time.Duration
+	// Body is the response body. Defaults to DefaultMaintenanceBody.
+	Body string
+	// FailHealth makes the health endpoint return 503 during maintenance too,
+	// so load balancers drain the instance.
+	FailHealth bool
+
+	enabled atomic.Bool
+	next    http.Handler
+}
+
+// NewMaintenanceMiddleware creates a new maintenance mode middleware, starting
+// in maintenance mode when enabled is true.
+func NewMaintenanceMiddleware(enabled bool, next http.Handler) *MaintenanceMiddleware {
+	m := &MaintenanceMiddleware{
+		Body: DefaultMaintenanceBody,
+		next: next,
+	}
+	m.enabled.Store(enabled)
+
+	return m
+}
+
+// SetEnabled turns maintenance mode on or off. It is safe to call while
+// requests are being served.
+func (m *MaintenanceMiddleware) SetEnabled(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// Enabled reports whether maintenance mode is on.
+func (m *MaintenanceMiddleware) Enabled() bool {
+	return m.enabled.Load()
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *MaintenanceMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Failing liveness would get the instance restarted rather than drained
+	if !m.enabled.Load() || r.URL.Path == livePath || (r.URL.Path == healthPath && !m.FailHealth) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	if m.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(m.RetryAfter.Seconds())))
+	}
+	WriteError(w, r, http.StatusServiceUnavailable, m.Body)
+}
+
+// maintenanceState is the JSON body of the maintenance admin endpoint.
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
+
+// AdminHandler returns a handler reporting maintenance mode on GET and
+// setting it on PUT, both as JSON of the form {"enabled": true}.
+func (m *MaintenanceMiddleware) AdminHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var state maintenanceState
+			if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+				http.Error(w, "invalid maintenance state", http.StatusBadRequest)
+				return
+			}
+			m.SetEnabled(state.Enabled)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(maintenanceState{Enabled: m.Enabled()})
+	})
+}
@@ -0,0 +1,192 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenanceMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		enabled        bool
+		failHealth     bool
+		retryAfter     time.Duration
+		body           string
+		path           string
+		want           int
+		wantRetryAfter string
+		wantBody       string
+	}{
+		"disabled": {
+			path: "/users",
+			want: http.StatusOK,
+		},
+		"enabled rejects routes": {
+			enabled:        true,
+			retryAfter:     30 * time.Second,
+			path:           "/users",
+			want:           http.StatusServiceUnavailable,
+			wantRetryAfter: "30",
+			wantBody:       DefaultMaintenanceBody,
+		},
+		"custom body": {
+			enabled:  true,
+			body:     "back at 10:00 UTC",
+			path:     "/users",
+			want:     http.StatusServiceUnavailable,
+			wantBody: "back at 10:00 UTC",
+		},
+		"health stays up": {
+			enabled: true,
+			path:    "/health",
+			want:    http.StatusOK,
+		},
+		"health fails when configured": {
+			enabled:    true,
+			failHealth: true,
+			path:       "/health",
+			want:       http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			m := NewMaintenanceMiddleware(tt.enabled, next)
+			m.RetryAfter = tt.retryAfter
+			m.FailHealth = tt.failHealth
+			if tt.body != "" {
+				m.Body = tt.body
+			}
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.want, rec.Code)
+			assert.Equal(t, tt.wantRetryAfter, rec.Header().Get("Retry-After"))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, strings.TrimSpace(rec.Body.String()))
+			}
+		})
+	}
+}
+
+func TestMaintenanceAdminHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		method      string
+		body        string
+		want        int
+		wantEnabled bool
+	}{
+		"get": {
+			method: http.MethodGet,
+			want:   http.StatusOK,
+		},
+		"enable": {
+			method:      http.MethodPut,
+			body:        `{"enabled": true}`,
+			want:        http.StatusOK,
+			wantEnabled: true,
+		},
+		"invalid body": {
+			method: http.MethodPut,
+			body:   "yes please",
+			want:   http.StatusBadRequest,
+		},
+		"method not allowed": {
+			method: http.MethodDelete,
+			want:   http.StatusMethodNotAllowed,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			m := NewMaintenanceMiddleware(false, next)
+
+			rec := httptest.NewRecorder()
+			m.AdminHandler().ServeHTTP(rec, httptest.NewRequest(tt.method, "/admin/maintenance", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.want, rec.Code)
+			assert.Equal(t, tt.wantEnabled, m.Enabled())
+			if tt.want == http.StatusOK {
+				assert.JSONEq(t, `{"enabled": `+strconv.FormatBool(tt.wantEnabled)+`}`, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerMaintenanceToggle(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		toggle    func(s *httpServer)
+		path      string
+		want      int
+	}{
+		"toggled on": {
+			namespace: "test_maintenance_on",
+			toggle:    func(s *httpServer) { s.SetMaintenance(true) },
+			path:      "/users",
+			want:      http.StatusServiceUnavailable,
+		},
+		"toggled on then off": {
+			namespace: "test_maintenance_off",
+			toggle: func(s *httpServer) {
+				s.SetMaintenance(true)
+				s.SetMaintenance(false)
+			},
+			path: "/users",
+			want: http.StatusOK,
+		},
+		"toggled via admin endpoint": {
+			namespace: "test_maintenance_admin",
+			toggle: func(s *httpServer) {
+				req := httptest.NewRequest(http.MethodPut, "/admin/maintenance", strings.NewReader(`{"enabled": true}`))
+				s.debugServer.Handler.ServeHTTP(httptest.NewRecorder(), req)
+			},
+			path: "/users",
+			want: http.StatusServiceUnavailable,
+		},
+		"health during maintenance": {
+			namespace: "test_maintenance_health",
+			toggle:    func(s *httpServer) { s.SetMaintenance(true) },
+			path:      "/health",
+			want:      http.StatusOK,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			routes := Routes{"/users": func(w http.ResponseWriter, r *http.Request) {}}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			s, err := NewServer(context.Background(), Config{Namespace: tt.namespace}, routes, logger)
+			assert.NoError(t, err)
+
+			tt.toggle(s)
+
+			rec := httptest.NewRecorder()
+			s.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -1,72 +1,251 @@
 package rest
 
 import (
+	"bufio"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rabellamy/promstrap/strategy"
 	"github.com/rabellamy/server/metrics"
 )
 
 // REDMiddleware wraps an HTTP handler to collect RED metrics.
 type REDMiddleware struct {
-	red  *strategy.RED
-	next http.Handler
+	red         *strategy.RED
+	cancelled   *prometheus.CounterVec
+	next        http.Handler
+	skip        map[string]struct{}
+	overhead    *prometheus.HistogramVec
+	flushStream bool
+	foldHead    bool
+	clock       metrics.Clock
+	extraLabels []string
+	registerer  prometheus.Registerer
+	pathLabel   func(*http.Request) string
+
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+// NewREDMiddleware creates a new RED metrics middleware. extraLabels declares
+// additional labels handlers may set per request with SetMetricLabel; they
+// are added to the requests and duration metrics.
+func NewREDMiddleware(namespace string, next http.Handler, extraLabels ...string) (*REDMiddleware, error) {
+	return newREDMiddleware(prometheus.DefaultRegisterer, namespace, next, nil, extraLabels...)
 }
 
-// NewREDMiddleware creates a new RED metrics middleware.
-func NewREDMiddleware(namespace string, next http.Handler) (*REDMiddleware, error) {
-	red, err := metrics.NewRED(namespace, "http", []string{"path", "verb"}, []string{"path"})
+// newREDMiddleware is like NewREDMiddleware, but registers its metrics with
+// reg and creates them with redOpts.
+func newREDMiddleware(reg prometheus.Registerer, namespace string, next http.Handler, redOpts []metrics.REDOption, extraLabels ...string) (*REDMiddleware, error) {
+	requestLabels := append([]string{"path", "verb"}, extraLabels...)
+	durationLabels := append([]string{"path"}, extraLabels...)
+
+	red, err := metrics.NewRED(namespace, "http", requestLabels, durationLabels, redOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create RED metrics: %w", err)
 	}
 
-	if err := red.Register(); err != nil {
+	if err := metrics.Register(reg, red); err != nil {
 		return nil, fmt.Errorf("failed to register RED metrics: %w", err)
 	}
 
+	cancelled := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_client_cancelled_total",
+		Help:      "Number of requests whose client went away before the handler returned",
+	}, []string{"path"})
+	if err := reg.Register(cancelled); err != nil {
+		return nil, fmt.Errorf("failed to register cancellation metric: %w", err)
+	}
+
 	return &REDMiddleware{
-		red:  red,
-		next: next,
+		red:         red,
+		cancelled:   cancelled,
+		next:        next,
+		skip:        map[string]struct{}{},
+		clock:       metrics.RealClock{},
+		extraLabels: extraLabels,
+		registerer:  reg,
+		logger:      slog.Default(),
 	}, nil
 }
 
-// responseWriter wraps http.ResponseWriter to capture the status code.
+// SetClock replaces the clock used to measure request durations.
+func (m *REDMiddleware) SetClock(clock metrics.Clock) {
+	m.clock = clock
+}
+
+// SetPathLabel replaces how the path label of a request is derived, e.g. to
+// normalize paths served without a ServeMux. fn is called once the handler
+// has returned, with Request.Pattern set if RecordRoutePattern saw the
+// request. By default the label is the matched route pattern, and
+// "unmatched" when no route matched or no ServeMux pattern was recorded, so
+// raw request paths only become labels when fn returns them.
+func (m *REDMiddleware) SetPathLabel(fn func(*http.Request) string) {
+	m.pathLabel = fn
+}
+
+// Skip excludes the given paths from RED metrics. Requests to them are
+// passed straight through to the wrapped handler.
+func (m *REDMiddleware) Skip(paths ...string) {
+	for _, path := range paths {
+		m.skip[path] = struct{}{}
+	}
+}
+
+// FlushStreams makes the middleware flush streaming responses before
+// recording their duration, so the duration covers delivering the final
+// chunk to the client rather than just the handler returning. A response is
+// treated as streaming when the handler flushed it or its Content-Type is
+// text/event-stream.
+func (m *REDMiddleware) FlushStreams() {
+	m.flushStream = true
+}
+
+// FoldHEAD makes the middleware record HEAD requests under the GET verb
+// label. http.ServeMux serves HEAD with GET handlers, and HEAD is usually a
+// probe's variant of GET, so folding keeps a route's metrics in one series.
+func (m *REDMiddleware) FoldHEAD() {
+	m.foldHead = true
+}
+
+// SetLogger sets the logger the middleware reports to. Defaults to
+// slog.Default().
+func (m *REDMiddleware) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// LogSlowRequests makes the middleware log a warning, tagged slow=true, for
+// requests that take longer than threshold. Zero disables slow request
+// logging.
+func (m *REDMiddleware) LogSlowRequests(logger *slog.Logger, threshold time.Duration) {
+	m.logger = logger
+	m.slowThreshold = threshold
+}
+
+// responseWriter wraps http.ResponseWriter to capture the status code and
+// count the body bytes written.
 type responseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	written    int64
+	flushed    bool
+}
+
+// responseWriterPool reuses responseWriters across requests to cut
+// per-request allocations.
+var responseWriterPool = sync.Pool{
+	New: func() any { return new(responseWriter) },
+}
+
+// newResponseWriter returns a pooled responseWriter wrapping w. Call release
+// once the request is done with it.
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	rw := responseWriterPool.Get().(*responseWriter)
+	rw.ResponseWriter = w
+	rw.statusCode = http.StatusOK
+
+	return rw
+}
+
+// release resets rw and returns it to the pool. Dropping the wrapped writer
+// keeps the pool from holding on to finished connections.
+func (rw *responseWriter) release() {
+	*rw = responseWriter{}
+	responseWriterPool.Put(rw)
+}
+
+// streaming reports whether the response looks like a stream.
+func (rw *responseWriter) streaming() bool {
+	return rw.flushed || strings.HasPrefix(rw.Header().Get("Content-Type"), "text/event-stream")
 }
 
 // ServeHTTP implements the http.Handler interface.
 func (m *REDMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
+	if _, ok := m.skip[r.URL.Path]; ok {
+		m.next.ServeHTTP(w, r)
+		return
+	}
 
-	// Wrap response writer to capture status code
-	rw := &responseWriter{
-		ResponseWriter: w,
-		statusCode:     http.StatusOK,
+	start := m.clock.Now()
+
+	r, timing := m.withHandlerTiming(r)
+	r, labels := m.withMetricLabels(r)
+	route := &routePattern{}
+	r = r.WithContext(routePatternKey.WithValue(r.Context(), route))
+
+	verb := r.Method
+	if m.foldHead && verb == http.MethodHead {
+		verb = http.MethodGet
 	}
 
-	// Record the request (Rate)
-	m.red.Requests.WithLabelValues(r.URL.Path, r.Method).Inc()
+	// Wrap response writer to capture status code
+	rw := newResponseWriter(w)
+	defer rw.release()
 
 	m.next.ServeHTTP(rw, r)
 
+	if m.flushStream && rw.streaming() {
+		rw.Flush()
+	}
+
+	// The route and extra labels are only known once the handler has run,
+	// so the request (Rate) is recorded afterwards
+	if route.recorded {
+		r.Pattern = route.pattern
+	}
+	path := m.requestPathLabel(r)
+	requestValues := []string{path, verb}
+	durationValues := []string{path}
+	if labels != nil {
+		extra := labels.list()
+		requestValues = append(requestValues, extra...)
+		durationValues = append(durationValues, extra...)
+	}
+	m.red.Requests.WithLabelValues(requestValues...).Inc()
+
 	// Record duration
-	duration := time.Since(start).Seconds()
+	elapsed := m.clock.Now().Sub(start)
+	duration := elapsed.Seconds()
+	m.observeOverhead(timing, elapsed)
 	if m.red.Duration.Histogram != nil {
-		m.red.Duration.Histogram.WithLabelValues(r.URL.Path).Observe(duration)
+		m.red.Duration.Histogram.WithLabelValues(durationValues...).Observe(duration)
 	}
 	if m.red.Duration.Summary != nil {
-		m.red.Duration.Summary.WithLabelValues(r.URL.Path).Observe(duration)
+		m.red.Duration.Summary.WithLabelValues(durationValues...).Observe(duration)
 	}
 
-	// Record errors (status code >= 400)
-	if rw.statusCode >= 400 {
+	// A client that went away isn't a server error, whatever status the
+	// handler wrote for it
+	gone := ClientGone(r)
+	if gone {
+		m.cancelled.WithLabelValues(path).Inc()
+		m.logger.Debug("request", "status", "client gone", "path", r.URL.Path, "method", r.Method, "duration", elapsed)
+	} else if rw.statusCode >= 400 {
+		// Record errors (status code >= 400)
 		m.red.Errors.WithLabelValues(strconv.Itoa(rw.statusCode)).Inc()
 	}
+
+	if m.slowThreshold > 0 && elapsed > m.slowThreshold {
+		m.logger.Warn("request", "slow", true, "path", r.URL.Path, "method", r.Method, "status", rw.statusCode, "client_gone", gone, "duration", elapsed)
+	}
+}
+
+// requestPathLabel returns the path label recorded for r.
+func (m *REDMiddleware) requestPathLabel(r *http.Request) string {
+	if m.pathLabel != nil {
+		return m.pathLabel(r)
+	}
+
+	return routeLabel(r.Pattern)
 }
 
 // WriteHeader captures the status code and calls the underlying WriteHeader.
@@ -74,3 +253,52 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Write counts the bytes written and calls the underlying Write.
+func (rw *responseWriter) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.written += int64(n)
+
+	return n, err
+}
+
+// Flush flushes the underlying ResponseWriter if it supports it.
+func (rw *responseWriter) Flush() {
+	rw.flushed = true
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the underlying ResponseWriter's connection, e.g. for a
+// WebSocket upgrade, if it supports it. The request is then recorded with
+// status 101 Switching Protocols.
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := rw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+
+	conn, buf, err := h.Hijack()
+	if err == nil {
+		rw.statusCode = http.StatusSwitchingProtocols
+	}
+
+	return conn, buf, err
+}
+
+// Push initiates an HTTP/2 server push through the underlying ResponseWriter
+// if it supports it.
+func (rw *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := rw.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying ResponseWriter, so http.ResponseController
+// can reach the methods the wrapper doesn't override.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -1,10 +1,21 @@
 package rest
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/rabellamy/server/metrics"
 	"github.com/stretchr/testify/assert"
 )
@@ -152,3 +163,455 @@ func TestREDMiddlewareServeHTTP(t *testing.T) {
 		})
 	}
 }
+
+// slowFlushRecorder simulates a slow client by delaying every flush.
+type slowFlushRecorder struct {
+	*httptest.ResponseRecorder
+	delay time.Duration
+}
+
+func (r *slowFlushRecorder) Flush() {
+	time.Sleep(r.delay)
+	r.ResponseRecorder.Flush()
+}
+
+func TestREDMiddlewareFlushStreams(t *testing.T) {
+	t.Parallel()
+
+	const flushDelay = 50 * time.Millisecond
+
+	tests := map[string]struct {
+		namespace    string
+		flushStreams bool
+		handler      http.HandlerFunc
+		wantMin      time.Duration
+		wantMax      time.Duration
+	}{
+		"buffered response": {
+			namespace:    "test_flush_streams_buffered",
+			flushStreams: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("done"))
+			},
+			wantMin: 0,
+			wantMax: flushDelay,
+		},
+		"event stream recorded after final flush": {
+			namespace:    "test_flush_streams_event_stream",
+			flushStreams: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: done\n\n"))
+			},
+			wantMin: flushDelay,
+		},
+		"flushed stream recorded after final flush": {
+			namespace:    "test_flush_streams_flushed",
+			flushStreams: true,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("chunk"))
+				w.(http.Flusher).Flush()
+				w.Write([]byte("last chunk"))
+			},
+			wantMin: 2 * flushDelay,
+		},
+		"event stream recorded on handler return when disabled": {
+			namespace:    "test_flush_streams_disabled",
+			flushStreams: false,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: done\n\n"))
+			},
+			wantMin: 0,
+			wantMax: flushDelay,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			middleware, err := NewREDMiddleware(tt.namespace, tt.handler)
+			assert.NoError(t, err)
+			if tt.flushStreams {
+				middleware.FlushStreams()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+			rec := &slowFlushRecorder{ResponseRecorder: httptest.NewRecorder(), delay: flushDelay}
+
+			middleware.ServeHTTP(rec, req)
+
+			var m dto.Metric
+			err = middleware.red.Duration.Histogram.WithLabelValues(unmatchedRoute).(prometheus.Metric).Write(&m)
+			assert.NoError(t, err)
+
+			got := time.Duration(m.GetHistogram().GetSampleSum() * float64(time.Second))
+			assert.GreaterOrEqual(t, got, tt.wantMin)
+			if tt.wantMax > 0 {
+				assert.Less(t, got, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestREDMiddlewareFoldHEAD(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fold     bool
+		wantGET  float64
+		wantHEAD float64
+	}{
+		"HEAD and GET share a label when folding": {
+			fold:     true,
+			wantGET:  2,
+			wantHEAD: 0,
+		},
+		"HEAD labelled separately by default": {
+			fold:     false,
+			wantGET:  1,
+			wantHEAD: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /probe", func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+
+			middleware, err := newREDMiddleware(prometheus.NewRegistry(), "test_fold_head", mux, nil)
+			assert.NoError(t, err)
+			if tt.fold {
+				middleware.FoldHEAD()
+			}
+
+			for _, method := range []string{http.MethodGet, http.MethodHead} {
+				rec := httptest.NewRecorder()
+				middleware.ServeHTTP(rec, httptest.NewRequest(method, "/probe", nil))
+				assert.Equal(t, http.StatusOK, rec.Code)
+			}
+
+			assert.Equal(t, tt.wantGET, testutil.ToFloat64(middleware.red.Requests.WithLabelValues("/probe", http.MethodGet)))
+			assert.Equal(t, tt.wantHEAD, testutil.ToFloat64(middleware.red.Requests.WithLabelValues("/probe", http.MethodHead)))
+		})
+	}
+}
+
+// fakeClock is a metrics.Clock that only moves when advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestREDMiddlewareClock(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		elapsed   time.Duration
+	}{
+		"sub-second request": {
+			namespace: "test_clock_sub_second",
+			elapsed:   250 * time.Millisecond,
+		},
+		"multi-second request": {
+			namespace: "test_clock_multi_second",
+			elapsed:   3 * time.Second,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(tt.elapsed)
+			})
+
+			middleware, err := NewREDMiddleware(tt.namespace, handler)
+			assert.NoError(t, err)
+			middleware.SetClock(clock)
+
+			req := httptest.NewRequest(http.MethodGet, "/clock", nil)
+			rec := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rec, req)
+
+			var m dto.Metric
+			err = middleware.red.Duration.Histogram.WithLabelValues(unmatchedRoute).(prometheus.Metric).Write(&m)
+			assert.NoError(t, err)
+			assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+			assert.Equal(t, tt.elapsed.Seconds(), m.GetHistogram().GetSampleSum())
+		})
+	}
+}
+
+func TestREDMiddlewareLogSlowRequests(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace string
+		threshold time.Duration
+		elapsed   time.Duration
+		wantLog   bool
+	}{
+		"slow request": {
+			namespace: "test_slow_request",
+			threshold: 100 * time.Millisecond,
+			elapsed:   250 * time.Millisecond,
+			wantLog:   true,
+		},
+		"fast request": {
+			namespace: "test_fast_request",
+			threshold: 100 * time.Millisecond,
+			elapsed:   50 * time.Millisecond,
+		},
+		"disabled": {
+			namespace: "test_slow_request_disabled",
+			elapsed:   time.Hour,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			clock := &fakeClock{now: time.Unix(0, 0)}
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				clock.Advance(tt.elapsed)
+			})
+
+			middleware, err := NewREDMiddleware(tt.namespace, handler)
+			assert.NoError(t, err)
+			middleware.SetClock(clock)
+
+			var buf bytes.Buffer
+			middleware.LogSlowRequests(slog.New(slog.NewTextHandler(&buf, nil)), tt.threshold)
+
+			middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+			if tt.wantLog {
+				assert.Contains(t, buf.String(), "level=WARN")
+				assert.Contains(t, buf.String(), "slow=true")
+				assert.Contains(t, buf.String(), "path=/slow")
+				assert.Contains(t, buf.String(), "duration="+tt.elapsed.String())
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+func TestREDMiddlewareClientCancelled(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace     string
+		cancel        bool
+		status        int
+		wantCancelled float64
+		wantErrors    float64
+		wantLog       string
+	}{
+		"client cancelled mid request": {
+			namespace:     "test_client_cancelled",
+			cancel:        true,
+			status:        http.StatusInternalServerError,
+			wantCancelled: 1,
+			wantLog:       "client gone",
+		},
+		"server error": {
+			namespace:  "test_client_not_cancelled",
+			status:     http.StatusInternalServerError,
+			wantErrors: 1,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.cancel {
+					// The client disconnects while the handler is working
+					cancel()
+					<-r.Context().Done()
+				}
+				w.WriteHeader(tt.status)
+			})
+
+			middleware, err := NewREDMiddleware(tt.namespace, handler)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			middleware.SetLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+			req := httptest.NewRequest(http.MethodGet, "/work", nil).WithContext(ctx)
+			middleware.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.wantCancelled, testutil.ToFloat64(middleware.cancelled.WithLabelValues(unmatchedRoute)))
+			assert.Equal(t, tt.wantErrors, testutil.ToFloat64(middleware.red.Errors.WithLabelValues("500")))
+			if tt.wantLog != "" {
+				assert.Contains(t, buf.String(), tt.wantLog)
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+func TestREDMiddlewarePooledResponseWriter(t *testing.T) {
+	t.Parallel()
+
+	// Odd requests fail and flush, even ones succeed without flushing, so a
+	// writer that isn't reset between requests leaks state into the next
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fail") == "true" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.(http.Flusher).Flush()
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	middleware, err := newREDMiddleware(prometheus.NewRegistry(), "test_pooled_writer", handler, nil)
+	assert.NoError(t, err)
+
+	const requests = 500
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			fail := i%2 == 1
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/pooled?fail=%t", fail), nil))
+
+			if fail {
+				assert.Equal(t, http.StatusInternalServerError, rec.Code)
+			} else {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.False(t, rec.Flushed)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, float64(requests), testutil.ToFloat64(middleware.red.Requests.WithLabelValues(unmatchedRoute, http.MethodGet)))
+	assert.Equal(t, float64(requests/2), testutil.ToFloat64(middleware.red.Errors.WithLabelValues("500")))
+}
+
+// hijackRecorder is a ResponseRecorder that also supports hijacking and
+// HTTP/2 push.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	pushed   []string
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func (r *hijackRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = append(r.pushed, target)
+	return nil
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		underlying   func() http.ResponseWriter
+		wantHijacked bool
+		wantPushed   bool
+	}{
+		"supported": {
+			underlying: func() http.ResponseWriter {
+				return &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+			},
+			wantHijacked: true,
+			wantPushed:   true,
+		},
+		"unsupported": {
+			underlying: func() http.ResponseWriter {
+				return httptest.NewRecorder()
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			underlying := tt.underlying()
+			var wrapped http.ResponseWriter = newResponseWriter(underlying)
+
+			_, isFlusher := wrapped.(http.Flusher)
+			assert.True(t, isFlusher)
+			hijacker, isHijacker := wrapped.(http.Hijacker)
+			assert.True(t, isHijacker)
+			pusher, isPusher := wrapped.(http.Pusher)
+			assert.True(t, isPusher)
+
+			conn, _, err := hijacker.Hijack()
+			pushErr := pusher.Push("/style.css", nil)
+			if tt.wantHijacked {
+				assert.NoError(t, err)
+				conn.Close()
+				assert.True(t, underlying.(*hijackRecorder).hijacked)
+				assert.Equal(t, http.StatusSwitchingProtocols, wrapped.(*responseWriter).statusCode)
+			} else {
+				assert.ErrorIs(t, err, http.ErrNotSupported)
+			}
+			if tt.wantPushed {
+				assert.NoError(t, pushErr)
+				assert.Equal(t, []string{"/style.css"}, underlying.(*hijackRecorder).pushed)
+			} else {
+				assert.ErrorIs(t, pushErr, http.ErrNotSupported)
+			}
+
+			// http.ResponseController reaches the underlying writer too
+			assert.NoError(t, http.NewResponseController(wrapped).Flush())
+		})
+	}
+}
+
+func BenchmarkREDMiddleware(b *testing.B) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	middleware, err := newREDMiddleware(prometheus.NewRegistry(), "bench_red_middleware", handler, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/bench", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for b.Loop() {
+		middleware.ServeHTTP(w, req)
+	}
+}
@@ -0,0 +1,105 @@
+package rest
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	swaggerfiles "github.com/swaggo/files/v2"
+)
+
+//go:embed swaggerui.html
+var swaggerUITemplate string
+
+var swaggerUI = template.Must(template.New("swaggerui").Parse(swaggerUITemplate))
+
+// swaggerUIAssets are the Swagger UI files the page loads, served from the
+// embedded swagger-ui-dist rather than a CDN, so the UI works offline and
+// under a strict Content Security Policy.
+var swaggerUIAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// ServeOpenAPI serves the OpenAPI spec found at specPath alongside a
+// Swagger UI page mounted at uiPath. The spec is served next to the UI using
+// its file name, e.g. specPath "api/openapi.yaml" with uiPath "/docs" serves
+// the spec at "/docs/openapi.yaml". The Swagger UI assets are served next to
+// it too. These paths are excluded from RED metrics. It panics if called after
+// Run.
+func (s *httpServer) ServeOpenAPI(specPath string, uiPath string) error {
+	s.mustNotBeStarted("ServeOpenAPI")
+
+	contentType, err := openAPIContentType(specPath)
+	if err != nil {
+		return err
+	}
+
+	spec, err := os.ReadFile(specPath)
+	if err != nil {
+		return fmt.Errorf("failed to read OpenAPI spec: %w", err)
+	}
+
+	if !strings.HasPrefix(uiPath, "/") {
+		return fmt.Errorf("invalid OpenAPI UI path: %s", uiPath)
+	}
+
+	specURL := path.Join(uiPath, filepath.Base(specPath))
+	skip := []string{specURL, uiPath}
+
+	for name, contentType := range swaggerUIAssets {
+		asset, err := fs.ReadFile(swaggerfiles.FS, name)
+		if err != nil {
+			return fmt.Errorf("failed to read Swagger UI asset %s: %w", name, err)
+		}
+		assetURL := path.Join(uiPath, name)
+		s.mux.HandleFunc(assetURL, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", contentType)
+			w.Write(asset)
+		})
+		skip = append(skip, assetURL)
+	}
+
+	var page bytes.Buffer
+	data := struct{ SpecURL, CSSURL, BundleURL string }{
+		SpecURL:   specURL,
+		CSSURL:    path.Join(uiPath, "swagger-ui.css"),
+		BundleURL: path.Join(uiPath, "swagger-ui-bundle.js"),
+	}
+	if err := swaggerUI.Execute(&page, data); err != nil {
+		return fmt.Errorf("failed to render Swagger UI: %w", err)
+	}
+
+	s.mux.HandleFunc(specURL, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(spec)
+	})
+	s.mux.HandleFunc(uiPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+	})
+
+	if s.red != nil {
+		s.red.Skip(skip...)
+	}
+
+	return nil
+}
+
+func openAPIContentType(specPath string) (string, error) {
+	switch strings.ToLower(filepath.Ext(specPath)) {
+	case ".json":
+		return "application/json", nil
+	case ".yaml", ".yml":
+		return "application/yaml", nil
+	default:
+		return "", fmt.Errorf("unsupported OpenAPI spec format: %s", specPath)
+	}
+}
@@ -0,0 +1,137 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServeOpenAPI(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace       string
+		specFile        string
+		uiPath          string
+		path            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+		wantErr         bool
+	}{
+		"json spec": {
+			namespace:       "test_openapi_json",
+			specFile:        "openapi.json",
+			uiPath:          "/docs",
+			path:            "/docs/openapi.json",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        `"openapi"`,
+		},
+		"yaml spec": {
+			namespace:       "test_openapi_yaml",
+			specFile:        "openapi.yaml",
+			uiPath:          "/docs",
+			path:            "/docs/openapi.yaml",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/yaml",
+			wantBody:        "openapi:",
+		},
+		"ui page": {
+			namespace:       "test_openapi_ui",
+			specFile:        "openapi.yaml",
+			uiPath:          "/swagger",
+			path:            "/swagger",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        "swagger-ui",
+		},
+		"ui page loads embedded assets": {
+			namespace:       "test_openapi_ui_assets",
+			specFile:        "openapi.yaml",
+			uiPath:          "/swagger",
+			path:            "/swagger",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        `<script src="/swagger/swagger-ui-bundle.js">`,
+		},
+		"ui script": {
+			namespace:       "test_openapi_ui_script",
+			specFile:        "openapi.yaml",
+			uiPath:          "/docs",
+			path:            "/docs/swagger-ui-bundle.js",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/javascript; charset=utf-8",
+			wantBody:        "SwaggerUIBundle",
+		},
+		"ui stylesheet": {
+			namespace:       "test_openapi_ui_stylesheet",
+			specFile:        "openapi.yaml",
+			uiPath:          "/docs",
+			path:            "/docs/swagger-ui.css",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/css; charset=utf-8",
+			wantBody:        ".swagger-ui",
+		},
+		"unsupported format": {
+			namespace: "test_openapi_unsupported",
+			specFile:  "openapi.txt",
+			uiPath:    "/docs",
+			wantErr:   true,
+		},
+		"invalid ui path": {
+			namespace: "test_openapi_invalid_ui",
+			specFile:  "openapi.json",
+			uiPath:    "docs",
+			wantErr:   true,
+		},
+	}
+
+	specs := map[string]string{
+		".json": `{"openapi": "3.0.0"}`,
+		".yaml": "openapi: 3.0.0\n",
+		".txt":  "openapi",
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			specPath := filepath.Join(t.TempDir(), tt.specFile)
+			err := os.WriteFile(specPath, []byte(specs[filepath.Ext(tt.specFile)]), 0o600)
+			assert.NoError(t, err)
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			server, err := NewServer(context.Background(), Config{Namespace: tt.namespace}, Routes{}, logger)
+			assert.NoError(t, err)
+
+			err = server.ServeOpenAPI(specPath, tt.uiPath)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			server.mainServer.Handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+			assert.NotContains(t, rec.Body.String(), "unpkg.com")
+
+			// OpenAPI paths must not show up in RED metrics
+			assert.Equal(t, 0, testutil.CollectAndCount(server.red.red.Requests))
+		})
+	}
+}
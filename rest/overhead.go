@@ -0,0 +1,78 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/server/internal/ctxkey"
+	"github.com/rabellamy/server/metrics"
+)
+
+// handlerTimingKey is the context key under which the REDMiddleware stores
+// the handlerTiming that the innermost HandlerTimer fills in.
+var handlerTimingKey = ctxkey.New[*handlerTiming]("handler timing")
+
+type handlerTiming struct {
+	clock    metrics.Clock
+	duration time.Duration
+}
+
+// EnableOverheadMetrics registers a histogram splitting each request's total
+// duration into the time spent in the wrapped handler and the time spent in
+// the surrounding middleware chain. It only has an effect when the innermost
+// handler is wrapped with HandlerTimer.
+func (m *REDMiddleware) EnableOverheadMetrics(namespace string) error {
+	overhead := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "middleware_overhead_seconds",
+		Help:      "Duration of requests split between the middleware chain and the wrapped handler",
+		Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1, 5},
+	}, []string{"component"})
+
+	if err := m.registerer.Register(overhead); err != nil {
+		return fmt.Errorf("failed to register middleware overhead metric: %w", err)
+	}
+
+	m.overhead = overhead
+	return nil
+}
+
+// HandlerTimer wraps the innermost handler of a middleware chain so the
+// REDMiddleware can tell the handler's own duration apart from the chain's.
+func HandlerTimer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timing, ok := handlerTimingKey.Value(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := timing.clock.Now()
+		next.ServeHTTP(w, r)
+		timing.duration = timing.clock.Now().Sub(start)
+	})
+}
+
+// withHandlerTiming attaches a handlerTiming to the request when overhead
+// metrics are enabled.
+func (m *REDMiddleware) withHandlerTiming(r *http.Request) (*http.Request, *handlerTiming) {
+	if m.overhead == nil {
+		return r, nil
+	}
+
+	timing := &handlerTiming{clock: m.clock}
+	return r.WithContext(handlerTimingKey.WithValue(r.Context(), timing)), timing
+}
+
+// observeOverhead records the handler and middleware share of total.
+func (m *REDMiddleware) observeOverhead(timing *handlerTiming, total time.Duration) {
+	if timing == nil {
+		return
+	}
+
+	m.overhead.WithLabelValues("handler").Observe(timing.duration.Seconds())
+	m.overhead.WithLabelValues("middleware").Observe((total - timing.duration).Seconds())
+}
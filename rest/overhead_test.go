@@ -0,0 +1,75 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOverheadMetrics(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		namespace       string
+		handlerDelay    time.Duration
+		middlewareDelay time.Duration
+	}{
+		"slow handler": {
+			namespace:    "test_overhead_slow_handler",
+			handlerDelay: 50 * time.Millisecond,
+		},
+		"slow middleware": {
+			namespace:       "test_overhead_slow_middleware",
+			handlerDelay:    10 * time.Millisecond,
+			middlewareDelay: 50 * time.Millisecond,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.handlerDelay)
+			})
+
+			// A middleware sitting between the RED middleware and the handler
+			chain := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(tt.middlewareDelay)
+				HandlerTimer(handler).ServeHTTP(w, r)
+			})
+
+			middleware, err := NewREDMiddleware(tt.namespace, chain)
+			assert.NoError(t, err)
+			assert.NoError(t, middleware.EnableOverheadMetrics(tt.namespace))
+
+			req := httptest.NewRequest(http.MethodGet, "/overhead", nil)
+			rec := httptest.NewRecorder()
+
+			middleware.ServeHTTP(rec, req)
+
+			handlerSum := histogramSum(t, middleware.overhead.WithLabelValues("handler"))
+			middlewareSum := histogramSum(t, middleware.overhead.WithLabelValues("middleware"))
+			totalSum := histogramSum(t, middleware.red.Duration.Histogram.WithLabelValues(unmatchedRoute))
+
+			assert.GreaterOrEqual(t, handlerSum, tt.handlerDelay.Seconds())
+			assert.GreaterOrEqual(t, middlewareSum, tt.middlewareDelay.Seconds())
+			assert.InDelta(t, totalSum-handlerSum, middlewareSum, 1e-9)
+		})
+	}
+}
+
+func histogramSum(t *testing.T, observer prometheus.Observer) float64 {
+	t.Helper()
+
+	var m dto.Metric
+	assert.NoError(t, observer.(prometheus.Metric).Write(&m))
+	assert.Equal(t, uint64(1), m.GetHistogram().GetSampleCount())
+
+	return m.GetHistogram().GetSampleSum()
+}
@@ -0,0 +1,95 @@
+package rest
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PathMiddleware canonicalizes request paths before they are routed and
+// recorded in metrics. Each behavior is off unless enabled.
+type PathMiddleware struct {
+	// Clean collapses repeated slashes and resolves "." and ".." segments,
+	// e.g. "//double//slashes" becomes "/double/slashes". A trailing slash
+	// is kept so subtree routes still match.
+	Clean bool
+
+	// RejectInvalid answers 400 for paths containing a null byte or a ".."
+	// segment, rather than letting a traversal attempt reach a handler.
+	RejectInvalid bool
+
+	// Lowercase lowercases paths for case-insensitive routing.
+	Lowercase bool
+
+	next http.Handler
+}
+
+// NewPathMiddleware creates a new path canonicalization middleware with
+// every behavior disabled.
+func NewPathMiddleware(next http.Handler) *PathMiddleware {
+	return &PathMiddleware{next: next}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *PathMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := r.URL.Path
+
+	// Check before cleaning, which would resolve the ".." segments away
+	if m.RejectInvalid && invalidPath(p) {
+		WriteError(w, r, http.StatusBadRequest, "invalid path")
+		return
+	}
+
+	if m.Clean {
+		p = cleanPath(p)
+	}
+	if m.Lowercase {
+		p = strings.ToLower(p)
+	}
+
+	if p == r.URL.Path {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Like http.StripPrefix, leave the caller's request untouched
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = ""
+	m.next.ServeHTTP(w, r2)
+}
+
+// invalidPath reports whether p contains a null byte or a ".." segment.
+func invalidPath(p string) bool {
+	if strings.ContainsRune(p, 0) {
+		return true
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanPath returns the canonical form of p, keeping a trailing slash.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	return cleaned
+}
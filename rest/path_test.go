@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		clean, reject, lower bool
+		path                 string
+		wantStatus           int
+		wantPath             string
+	}{
+		"disabled passes path through": {
+			path:       "/a//b/../c",
+			wantStatus: http.StatusOK,
+			wantPath:   "/a//b/../c",
+		},
+		"cleans double slashes": {
+			clean:      true,
+			path:       "//double//slashes",
+			wantStatus: http.StatusOK,
+			wantPath:   "/double/slashes",
+		},
+		"cleans dot segments": {
+			clean:      true,
+			path:       "/a/./b/../c",
+			wantStatus: http.StatusOK,
+			wantPath:   "/a/c",
+		},
+		"clean keeps trailing slash": {
+			clean:      true,
+			path:       "/docs//",
+			wantStatus: http.StatusOK,
+			wantPath:   "/docs/",
+		},
+		"rejects traversal": {
+			reject:     true,
+			path:       "/static/../../etc/passwd",
+			wantStatus: http.StatusBadRequest,
+		},
+		"rejects traversal before cleaning": {
+			clean:      true,
+			reject:     true,
+			path:       "/static/../etc/passwd",
+			wantStatus: http.StatusBadRequest,
+		},
+		"rejects null byte": {
+			reject:     true,
+			path:       "/file\x00.txt",
+			wantStatus: http.StatusBadRequest,
+		},
+		"allows dots within segments": {
+			reject:     true,
+			path:       "/files/a..b",
+			wantStatus: http.StatusOK,
+			wantPath:   "/files/a..b",
+		},
+		"lowercases": {
+			lower:      true,
+			path:       "/Users/ABC",
+			wantStatus: http.StatusOK,
+			wantPath:   "/users/abc",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var gotPath string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath = r.URL.Path
+			})
+
+			m := NewPathMiddleware(next)
+			m.Clean = tt.clean
+			m.RejectInvalid = tt.reject
+			m.Lowercase = tt.lower
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.URL.Path = tt.path
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantPath, gotPath)
+			assert.Equal(t, tt.path, req.URL.Path, "caller's request should be untouched")
+		})
+	}
+}
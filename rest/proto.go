@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/munnerz/goautoneg"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentTypeProtobuf is the media type of binary protobuf responses.
+const ContentTypeProtobuf = "application/x-protobuf"
+
+// ProtoMarshaler encodes proto messages for one response media type.
+type ProtoMarshaler struct {
+	ContentType string
+	Marshal     func(proto.Message) ([]byte, error)
+}
+
+// DefaultProtoMarshalers respond with JSON or binary protobuf. JSON comes
+// first, so it is used when the Accept header allows either or none.
+var DefaultProtoMarshalers = []ProtoMarshaler{
+	{ContentType: "application/json", Marshal: protojson.Marshal},
+	{ContentType: ContentTypeProtobuf, Marshal: proto.Marshal},
+}
+
+// WriteProto writes msg with status, encoded by whichever of marshalers best
+// matches the request's Accept header, so JSON and protobuf clients can share
+// an endpoint. Without marshalers, DefaultProtoMarshalers are used. When
+// nothing matches, the first marshaler is used. Nothing is written if
+// encoding fails, so the caller can still respond with an error.
+func WriteProto(w http.ResponseWriter, r *http.Request, status int, msg proto.Message, marshalers ...ProtoMarshaler) error {
+	if len(marshalers) == 0 {
+		marshalers = DefaultProtoMarshalers
+	}
+
+	marshaler := negotiateProto(r.Header.Get("Accept"), marshalers)
+	body, err := marshaler.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s response: %w", marshaler.ContentType, err)
+	}
+
+	w.Header().Set("Content-Type", marshaler.ContentType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// negotiateProto returns the marshaler best matching accept.
+func negotiateProto(accept string, marshalers []ProtoMarshaler) ProtoMarshaler {
+	if accept == "" {
+		return marshalers[0]
+	}
+
+	alternatives := make([]string, len(marshalers))
+	for i, m := range marshalers {
+		alternatives[i] = m.ContentType
+	}
+
+	contentType := goautoneg.Negotiate(accept, alternatives)
+	for _, m := range marshalers {
+		if m.ContentType == contentType {
+			return m
+		}
+	}
+
+	return marshalers[0]
+}
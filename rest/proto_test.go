@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rabellamy/server/examples/grpc/helloworld"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWriteProto(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		accept          string
+		marshalers      []ProtoMarshaler
+		wantContentType string
+		wantStatus      int
+	}{
+		"json": {
+			accept:          "application/json",
+			wantContentType: "application/json",
+			wantStatus:      http.StatusOK,
+		},
+		"protobuf": {
+			accept:          "application/x-protobuf",
+			wantContentType: ContentTypeProtobuf,
+			wantStatus:      http.StatusOK,
+		},
+		"preferred by quality": {
+			accept:          "application/json;q=0.5, application/x-protobuf",
+			wantContentType: ContentTypeProtobuf,
+			wantStatus:      http.StatusOK,
+		},
+		"no accept header defaults to json": {
+			wantContentType: "application/json",
+			wantStatus:      http.StatusOK,
+		},
+		"wildcard defaults to json": {
+			accept:          "*/*",
+			wantContentType: "application/json",
+			wantStatus:      http.StatusOK,
+		},
+		"unsupported falls back to json": {
+			accept:          "text/html",
+			wantContentType: "application/json",
+			wantStatus:      http.StatusOK,
+		},
+		"marshal error writes nothing": {
+			accept: "application/json",
+			marshalers: []ProtoMarshaler{{
+				ContentType: "application/json",
+				Marshal: func(proto.Message) ([]byte, error) {
+					return nil, errors.New("boom")
+				},
+			}},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			want := &helloworld.HelloReply{Message: "Hello world"}
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				if err := WriteProto(w, r, http.StatusOK, want, tt.marshalers...); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				assert.Contains(t, rec.Body.String(), "failed to marshal application/json response: boom")
+				return
+			}
+
+			assert.Equal(t, tt.wantContentType, rec.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", rec.Header().Get("Vary"))
+
+			got := &helloworld.HelloReply{}
+			if tt.wantContentType == ContentTypeProtobuf {
+				assert.NoError(t, proto.Unmarshal(rec.Body.Bytes(), got))
+			} else {
+				assert.NoError(t, protojson.Unmarshal(rec.Body.Bytes(), got))
+			}
+			assert.True(t, proto.Equal(want, got))
+		})
+	}
+}
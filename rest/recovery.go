@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicHandler is called with the value recovered from a panicking handler
+// and the stack at the point of the panic, e.g. to report it to an error
+// tracker.
+type PanicHandler func(ctx context.Context, recovered any, stack []byte)
+
+// RecoveryMiddleware recovers panics in the wrapped handler, logs them and
+// responds with a 500 instead of dropping the connection.
+type RecoveryMiddleware struct {
+	// OnPanic, when set, is called before the 500 is written. A panic in
+	// OnPanic itself is recovered and logged.
+	OnPanic PanicHandler
+
+	logger *slog.Logger
+	next   http.Handler
+}
+
+// NewRecoveryMiddleware creates a new panic recovery middleware.
+func NewRecoveryMiddleware(logger *slog.Logger, next http.Handler) *RecoveryMiddleware {
+	return &RecoveryMiddleware{
+		logger: logger,
+		next:   next,
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *RecoveryMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		// ErrAbortHandler is how handlers deliberately abort a response;
+		// let net/http handle it
+		if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+			panic(recovered)
+		}
+
+		stack := debug.Stack()
+		m.logger.Error("request", "status", "panic recovered", "path", r.URL.Path, "method", r.Method, "panic", recovered, "stack", string(stack))
+		m.callOnPanic(r.Context(), recovered, stack)
+
+		WriteError(w, r, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}()
+
+	m.next.ServeHTTP(w, r)
+}
+
+func (m *RecoveryMiddleware) callOnPanic(ctx context.Context, recovered any, stack []byte) {
+	if m.OnPanic == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error("request", "status", "panic handler panicked", "panic", r)
+		}
+	}()
+
+	m.OnPanic(ctx, recovered, stack)
+}
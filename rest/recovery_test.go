@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoveryMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		handler    http.HandlerFunc
+		onPanic    func(t *testing.T, called *bool) PanicHandler
+		want       int
+		wantCalled bool
+		wantLog    string
+	}{
+		"no panic": {
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			want:    http.StatusOK,
+		},
+		"panic without callback": {
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			want:    http.StatusInternalServerError,
+			wantLog: "panic recovered",
+		},
+		"panic with callback": {
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			onPanic: func(t *testing.T, called *bool) PanicHandler {
+				return func(ctx context.Context, recovered any, stack []byte) {
+					*called = true
+					assert.Equal(t, "boom", recovered)
+					assert.Contains(t, string(stack), "recovery_test.go")
+					assert.NotNil(t, ctx)
+				}
+			},
+			want:       http.StatusInternalServerError,
+			wantCalled: true,
+			wantLog:    "panic recovered",
+		},
+		"panicking callback": {
+			handler: func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			onPanic: func(t *testing.T, called *bool) PanicHandler {
+				return func(ctx context.Context, recovered any, stack []byte) {
+					*called = true
+					panic("callback boom")
+				}
+			},
+			want:       http.StatusInternalServerError,
+			wantCalled: true,
+			wantLog:    "panic handler panicked",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+
+			var called bool
+			m := NewRecoveryMiddleware(logger, tt.handler)
+			if tt.onPanic != nil {
+				m.OnPanic = tt.onPanic(t, &called)
+			}
+
+			rec := httptest.NewRecorder()
+			m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.want, rec.Code)
+			assert.Equal(t, tt.wantCalled, called)
+			if tt.wantLog != "" {
+				assert.Contains(t, buf.String(), tt.wantLog)
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
+
+func TestRecoveryMiddlewareAbortHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	m := NewRecoveryMiddleware(slog.New(slog.DiscardHandler), handler)
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
+
+func TestRecoveryMiddlewareNegotiatesError(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	recovery := NewRecoveryMiddleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+
+	recovery.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"Internal Server Error"}`, rec.Body.String())
+}
+
+func TestNewServerRecoversPanics(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:   "test_new_server_recovers_panics",
+		MetricsHost: "127.0.0.1:2112",
+		Registry:    prometheus.NewRegistry(),
+	}
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	routes := Routes{
+		"/boom": func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		},
+	}
+
+	server, err := NewServer(context.Background(), config, routes, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rec := httptest.NewRecorder()
+	server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/boom", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, logs.String(), `"status":"panic recovered"`)
+
+	// Recovery sits inside RED, so the 500 it writes is counted as an error
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.red.red.Errors.WithLabelValues("500")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(server.red.red.Requests.WithLabelValues("/boom", http.MethodGet)))
+}
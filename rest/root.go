@@ -0,0 +1,59 @@
+package rest
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// rootInfo is the JSON body served at the root path when ServeRootInfo is
+// set.
+type rootInfo struct {
+	Description string            `json:"description"`
+	Build       string            `json:"build"`
+	Links       map[string]string `json:"links"`
+}
+
+// rootInfoHandler returns a handler describing the service, so a human
+// hitting / learns what is running and where to look next.
+func rootInfoHandler(config Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info := rootInfo{
+			Description: config.Desc,
+			Build:       config.Build,
+			Links:       map[string]string{},
+		}
+		if !config.DisableHealthRoute {
+			info.Links["health"] = healthPath
+		}
+		if !config.DisableMetricsServer {
+			info.Links["metrics"] = metricsURL(r, config)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+// metricsURL returns the URL of the metrics endpoint as seen by the client
+// of r. A metrics server listening on all interfaces is reached through the
+// host the client used for r.
+func metricsURL(r *http.Request, config Config) string {
+	scheme := "http"
+	if config.MetricsTLSCertFile != "" || (config.MetricsInheritTLS && config.TLSCertFile != "") {
+		scheme = "https"
+	}
+
+	host, port, err := net.SplitHostPort(config.MetricsHost)
+	if err != nil {
+		return scheme + "://" + config.MetricsHost + "/metrics"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, port) + "/metrics"
+}
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRootInfoHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config    Config
+		host      string
+		wantLinks map[string]string
+	}{
+		"metrics on all interfaces": {
+			config: Config{Desc: "orders", Build: "v1.2.3", MetricsHost: "0.0.0.0:2112"},
+			host:   "orders.internal:3000",
+			wantLinks: map[string]string{
+				"health":  "/health",
+				"metrics": "http://orders.internal:2112/metrics",
+			},
+		},
+		"metrics on a specific host": {
+			config: Config{Desc: "orders", Build: "v1.2.3", MetricsHost: "10.0.0.5:2112"},
+			host:   "orders.internal:3000",
+			wantLinks: map[string]string{
+				"health":  "/health",
+				"metrics": "http://10.0.0.5:2112/metrics",
+			},
+		},
+		"metrics over tls": {
+			config: Config{Desc: "orders", Build: "v1.2.3", MetricsHost: ":2112", MetricsTLSCertFile: "cert.pem"},
+			host:   "orders.internal",
+			wantLinks: map[string]string{
+				"health":  "/health",
+				"metrics": "https://orders.internal:2112/metrics",
+			},
+		},
+		"metrics server disabled": {
+			config: Config{Desc: "orders", Build: "v1.2.3", DisableMetricsServer: true},
+			host:   "orders.internal:3000",
+			wantLinks: map[string]string{
+				"health": "/health",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			rootInfoHandler(tt.config)(rec, req)
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+			var got rootInfo
+			assert.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+			assert.Equal(t, rootInfo{
+				Description: tt.config.Desc,
+				Build:       tt.config.Build,
+				Links:       tt.wantLinks,
+			}, got)
+		})
+	}
+}
+
+func TestServeRootInfo(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		enabled    bool
+		routes     Routes
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		"enabled": {
+			enabled:    true,
+			path:       "/",
+			wantStatus: http.StatusOK,
+			wantBody:   `"description":"example server"`,
+		},
+		"enabled only serves the root path": {
+			enabled:    true,
+			path:       "/missing",
+			wantStatus: http.StatusNotFound,
+		},
+		"disabled": {
+			enabled:    false,
+			path:       "/",
+			wantStatus: http.StatusNotFound,
+		},
+		"user root route takes precedence": {
+			enabled: true,
+			routes: Routes{
+				"/": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("custom root"))
+				},
+			},
+			path:       "/",
+			wantStatus: http.StatusOK,
+			wantBody:   "custom root",
+		},
+		"user exact root route takes precedence": {
+			enabled: true,
+			routes: Routes{
+				"/{$}": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("custom root"))
+				},
+			},
+			path:       "/",
+			wantStatus: http.StatusOK,
+			wantBody:   "custom root",
+		},
+		"user GET exact root route takes precedence": {
+			enabled: true,
+			routes: Routes{
+				"GET /{$}": func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte("custom root"))
+				},
+			},
+			path:       "/",
+			wantStatus: http.StatusOK,
+			wantBody:   "custom root",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace:     "test_root_info_" + strings.ReplaceAll(name, " ", "_"),
+				Desc:          "example server",
+				MetricsHost:   "127.0.0.1:2112",
+				ServeRootInfo: tt.enabled,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			server, err := NewServer(context.Background(), config, tt.routes, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.wantBody)
+		})
+	}
+}
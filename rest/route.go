@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rabellamy/server/internal/ctxkey"
+)
+
+// unmatchedRoute is the path label of requests that no route matched, such
+// as 404s, so scanners probing arbitrary paths can't inflate cardinality.
+const unmatchedRoute = "unmatched"
+
+// routePatternKey is the context key under which the REDMiddleware stores
+// the routePattern that RecordRoutePattern fills in.
+var routePatternKey = ctxkey.New[*routePattern]("route pattern")
+
+// routePattern is the pattern a ServeMux matched for a request.
+type routePattern struct {
+	pattern  string
+	recorded bool
+}
+
+// RecordRoutePattern wraps a ServeMux so a REDMiddleware ahead of it can
+// label metrics with the matched pattern, e.g. /users/{id}, rather than the
+// request path. The mux only sets Request.Pattern on the request it was
+// given, which middleware between the two may have copied.
+func RecordRoutePattern(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+
+		if route, ok := routePatternKey.Value(r.Context()); ok {
+			route.pattern = r.Pattern
+			route.recorded = true
+		}
+	})
+}
+
+// routeLabel returns the path label for a matched ServeMux pattern. The
+// method is dropped from the pattern, as the verb label already has it.
+func routeLabel(pattern string) string {
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return strings.TrimLeft(path, " \t")
+	}
+
+	return pattern
+}
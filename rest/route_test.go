@@ -0,0 +1,202 @@
+package rest
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+type testContextKey struct{}
+
+func TestREDMiddlewareRouteLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		recordPattern bool
+		pathLabel     func(*http.Request) string
+		method        string
+		path          string
+		wantLabel     string
+	}{
+		"matched pattern": {
+			recordPattern: true,
+			method:        http.MethodGet,
+			path:          "/users/123",
+			wantLabel:     "/users/{id}",
+		},
+		"matched pattern without a method": {
+			recordPattern: true,
+			method:        http.MethodDelete,
+			path:          "/orders/9",
+			wantLabel:     "/orders/",
+		},
+		"unmatched path": {
+			recordPattern: true,
+			method:        http.MethodGet,
+			path:          "/wp-login.php",
+			wantLabel:     unmatchedRoute,
+		},
+		"pattern not recorded": {
+			method:    http.MethodGet,
+			path:      "/users/123",
+			wantLabel: unmatchedRoute,
+		},
+		"raw path opted into": {
+			pathLabel: func(r *http.Request) string {
+				return r.URL.Path
+			},
+			method:    http.MethodGet,
+			path:      "/users/123",
+			wantLabel: "/users/123",
+		},
+		"custom path label": {
+			recordPattern: true,
+			pathLabel: func(r *http.Request) string {
+				return strings.ToUpper(r.Pattern)
+			},
+			method:    http.MethodGet,
+			path:      "/users/123",
+			wantLabel: "GET /USERS/{ID}",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+			mux.HandleFunc("/orders/", func(w http.ResponseWriter, r *http.Request) {})
+
+			var next http.Handler = mux
+			if tt.recordPattern {
+				next = RecordRoutePattern(mux)
+			}
+
+			// Middleware that copies the request hides the mux's
+			// Request.Pattern from the RED middleware
+			copying := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), testContextKey{}, true)))
+			})
+
+			middleware, err := newREDMiddleware(prometheus.NewRegistry(), "test_route_label", copying, nil)
+			if !assert.NoError(t, err) {
+				return
+			}
+			if tt.pathLabel != nil {
+				middleware.SetPathLabel(tt.pathLabel)
+			}
+
+			middleware.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, 1, testutil.CollectAndCount(middleware.red.Requests))
+			assert.Equal(t, 1.0, testutil.ToFloat64(middleware.red.Requests.WithLabelValues(tt.wantLabel, tt.method)))
+		})
+	}
+}
+
+func TestReplayedResponseRouteLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config     Config
+		concurrent bool
+	}{
+		"cache hits": {
+			config: Config{CacheTTL: time.Minute, CacheMaxEntries: 16},
+		},
+		"single-flight followers": {
+			config:     Config{SingleFlightPaths: []string{"/users/123"}},
+			concurrent: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			const requests = 3
+
+			var calls atomic.Int32
+			release := make(chan struct{})
+			routes := Routes{
+				"GET /users/{id}": func(w http.ResponseWriter, r *http.Request) {
+					calls.Add(1)
+					<-release
+					w.Write([]byte("user"))
+				},
+			}
+
+			config := tt.config
+			config.Namespace = "test_replayed_route_label"
+			config.Registry = prometheus.NewRegistry()
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, routes, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			serve := func() {
+				server.mainServer.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+			}
+			if tt.concurrent {
+				var wg sync.WaitGroup
+				for range requests {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						serve()
+					}()
+				}
+				// Let the followers join the first request before it returns
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+				wg.Wait()
+			} else {
+				close(release)
+				for range requests {
+					serve()
+				}
+			}
+
+			assert.Equal(t, int32(1), calls.Load())
+			assert.Equal(t, 1, testutil.CollectAndCount(server.red.red.Requests))
+			assert.Equal(t, float64(requests), testutil.ToFloat64(server.red.red.Requests.WithLabelValues("/users/{id}", http.MethodGet)))
+		})
+	}
+}
+
+func TestRouteLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		pattern string
+		want    string
+	}{
+		"path":            {pattern: "/users/{id}", want: "/users/{id}"},
+		"method and path": {pattern: "GET /users/{id}", want: "/users/{id}"},
+		"extra spaces":    {pattern: "POST \t/users", want: "/users"},
+		"host and path":   {pattern: "api.example.com/users", want: "api.example.com/users"},
+		"no pattern":      {pattern: "", want: unmatchedRoute},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, routeLabel(tt.pattern))
+		})
+	}
+}
@@ -2,115 +2,679 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rabellamy/promstrap/strategy"
+	"github.com/rabellamy/server/debug"
+	"github.com/rabellamy/server/internal/background"
+	"github.com/rabellamy/server/internal/listener"
+	"github.com/rabellamy/server/internal/logattrs"
+	"github.com/rabellamy/server/internal/proxyproto"
+	"github.com/rabellamy/server/internal/tlsutil"
+	"github.com/rabellamy/server/metrics"
+	"golang.org/x/net/netutil"
+	"golang.org/x/sync/errgroup"
 )
 
+// ErrAlreadyRunning is returned by Run when the server has already been run.
+var ErrAlreadyRunning = errors.New("server already running")
+
 type httpServer struct {
 	mainServer    http.Server
 	metricsServer http.Server
+	debugServer   http.Server
+	mux           *http.ServeMux
+	red           *REDMiddleware
+	registerer    prometheus.Registerer
+	connTracker   *connTracker
+	maintenance   *MaintenanceMiddleware
+	shutdownTime  prometheus.Gauge
+	started       atomic.Bool
+	draining      *atomic.Bool
+	ready         *atomic.Bool
+	readiness     *readinessHandler
+	background    *background.Group
 	ctx           context.Context
 	logger        *slog.Logger
 	config        Config
 }
 
+type namedServer struct {
+	name   string
+	server *http.Server
+}
+
+// Routes maps patterns to handlers. A key is either a path, matching every
+// method, or a method and a path such as "GET /users". A path registered only
+// with methods answers other methods with 405 Method Not Allowed and an
+// Allow header listing the registered ones.
 type Routes map[string]func(w http.ResponseWriter, r *http.Request)
 
+// handles reports whether routes has a route for method requests to path,
+// either by path alone or by method and path.
+func (routes Routes) handles(method, path string) bool {
+	if _, ok := routes[path]; ok {
+		return true
+	}
+	_, ok := routes[method+" "+path]
+
+	return ok
+}
+
+// Option customizes a server created by NewServer.
+type Option func(*serverOptions)
+
+type serverOptions struct {
+	metricsHandler  http.Handler
+	readinessChecks []readinessCheck
+}
+
+// WithReadinessCheck adds a dependency check, such as a database ping, to
+// the /readyz endpoint under name. The server is ready only while every
+// check returns nil.
+func WithReadinessCheck(name string, check ReadinessCheck) Option {
+	return func(o *serverOptions) {
+		o.readinessChecks = append(o.readinessChecks, readinessCheck{name: name, check: check})
+	}
+}
+
+// WithMetricsHandler mounts handler on the metrics server, alongside
+// /metrics, for routes such as a /healthz on the metrics port. Requests for
+// /metrics are still served by the server.
+func WithMetricsHandler(handler http.Handler) Option {
+	return func(o *serverOptions) {
+		o.metricsHandler = handler
+	}
+}
+
+// CreateRoutes returns a mux serving routes, a health endpoint at /health, a
+// liveness endpoint at /livez and a readiness endpoint at /readyz, all of
+// which always report healthy. Routes for GET /livez or GET /readyz take
+// precedence over the built-in ones.
 func CreateRoutes(routes Routes) *http.ServeMux {
+	return createRoutes(routes, healthHandler(nil), healthHandler(nil), &readinessHandler{})
+}
+
+// createRoutes is like CreateRoutes, but serves the given health, liveness
+// and readiness handlers. A nil handler leaves its endpoint out.
+func createRoutes(routes Routes, health, live, readiness http.Handler) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	for path, route := range routes {
 		mux.HandleFunc(path, route)
 	}
 
-	health := func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "Status: %v", http.StatusOK)
+	if health != nil {
+		mux.Handle(healthPath, health)
+	}
+	if live != nil && !routes.handles(http.MethodGet, livePath) {
+		mux.Handle("GET "+livePath, live)
+	}
+	if readiness != nil && !routes.handles(http.MethodGet, readyPath) {
+		mux.Handle("GET "+readyPath, readiness)
 	}
-
-	mux.HandleFunc("/health", health)
 
 	return mux
 }
 
-func NewServer(ctx context.Context, config Config, routes Routes, logger *slog.Logger) (*httpServer, error) {
-	mainMux := CreateRoutes(routes)
-	handler, err := NewREDMiddleware(config.Namespace, mainMux)
+func NewServer(ctx context.Context, config Config, routes Routes, logger *slog.Logger, opts ...Option) (*httpServer, error) {
+	logger = logattrs.With(logger, config.LogAttrs)
+
+	var options serverOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if len(routes) == 0 {
+		if config.RequireRoutes {
+			return nil, errors.New("no routes registered")
+		}
+		logger.Warn("startup", "status", "no routes registered, only built-in endpoints will be served")
+	}
+
+	// These are implemented by the RED middleware, which DisableMetrics
+	// leaves out, so they would otherwise be silently dropped
+	if config.DisableMetrics {
+		var needRED []string
+		if config.FlushStreams {
+			needRED = append(needRED, "FlushStreams")
+		}
+		if config.SlowRequestThreshold > 0 {
+			needRED = append(needRED, "SlowRequestThreshold")
+		}
+		if config.OverheadMetrics {
+			needRED = append(needRED, "OverheadMetrics")
+		}
+		if len(needRED) > 0 {
+			return nil, fmt.Errorf("DisableMetrics can't be combined with %s", strings.Join(needRED, ", "))
+		}
+	}
+
+	network, err := listener.Network(config.ListenNetwork)
 	if err != nil {
 		return nil, err
 	}
+	config.ListenNetwork = network
+
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = DefaultShutdownTimeout
+	}
+
+	order, err := parseShutdownOrder(config.ShutdownOrder)
+	if err != nil {
+		return nil, err
+	}
+	config.ShutdownOrder = order
+
+	// ListenNetwork only applies to the API listener; the metrics and debug
+	// servers always listen on DefaultNetwork
+	hosts := []listener.Addr{{Name: "api", Address: config.APIHost, Network: network}}
+	if !config.DisableMetricsServer {
+		hosts = append(hosts, listener.Addr{Name: "metrics", Address: config.MetricsHost, Network: listener.DefaultNetwork})
+	}
+	if config.EnableDebug {
+		hosts = append(hosts, listener.Addr{Name: "debug", Address: config.DebugHost, Network: listener.DefaultNetwork})
+	}
+	if err := listener.CheckConflicts(hosts...); err != nil {
+		return nil, err
+	}
+
+	mainTLS, err := tlsutil.Load(config.TLSCertFile, config.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("main server: %w", err)
+	}
+
+	metricsTLS, err := tlsutil.Load(config.MetricsTLSCertFile, config.MetricsTLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("metrics server: %w", err)
+	}
+	if metricsTLS == nil && config.MetricsInheritTLS {
+		metricsTLS = mainTLS
+	}
+
+	reg := metrics.Registerer(config.Registry)
+
+	// The health and readiness endpoints fail once shutdown starts, so load
+	// balancers stop routing here while requests drain. Readiness also
+	// follows SetReady.
+	var draining, ready atomic.Bool
+	ready.Store(true)
+	readiness := &readinessHandler{
+		checks:  options.readinessChecks,
+		ready:   func() bool { return ready.Load() && !draining.Load() },
+		detail:  config.ReadinessDetail,
+		timeout: config.ReadinessTimeout,
+	}
+	var health, live, readinessRoute http.Handler
+	if !config.DisableHealthRoute {
+		health = healthHandler(func() bool { return !draining.Load() })
+	}
+	// Liveness only tells whether the process can serve at all, so it stays
+	// up through shutdown and while dependencies are down
+	if !config.DisableLivenessRoute {
+		live = healthHandler(nil)
+	}
+	if !config.DisableReadinessRoute {
+		readinessRoute = readiness
+	}
+	mainMux := createRoutes(routes, health, live, readinessRoute)
+	if config.ServeRootInfo {
+		// A route registered for / or exactly /{$} takes precedence
+		if !routes.handles(http.MethodGet, "/") && !routes.handles(http.MethodGet, "/{$}") {
+			mainMux.HandleFunc("GET /{$}", rootInfoHandler(config))
+		}
+	}
+
+	// Crawler and browser requests that would otherwise 404, so routes
+	// registered for the same paths take precedence
+	var assetPaths []string
+	if config.ServeRobotsTxt && !routes.handles(http.MethodGet, robotsPath) {
+		mainMux.HandleFunc("GET "+robotsPath, robotsHandler(config.RobotsTxt))
+		assetPaths = append(assetPaths, robotsPath)
+	}
+	if config.ServeFavicon && !routes.handles(http.MethodGet, faviconPath) {
+		var icon []byte
+		if config.FaviconFile != "" {
+			icon, err = os.ReadFile(config.FaviconFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read favicon: %w", err)
+			}
+		}
+		mainMux.HandleFunc("GET "+faviconPath, faviconHandler(config.FaviconFile, icon))
+		assetPaths = append(assetPaths, faviconPath)
+	}
+
+	var next http.Handler = RecordRoutePattern(negotiateMuxErrors(mainMux))
+	if config.OverheadMetrics {
+		next = HandlerTimer(next)
+	}
+	if config.HandlerTimeout > 0 || len(config.MethodTimeouts) > 0 {
+		next = NewTimeoutMiddleware(config.HandlerTimeout, config.MethodTimeouts, next)
+	}
+	if len(config.SingleFlightPaths) > 0 {
+		next = NewSingleFlightMiddleware(config.SingleFlightPaths, next)
+	}
+	if config.CacheTTL > 0 {
+		cache := NewCacheMiddleware(config.CacheTTL, next)
+		cache.MaxEntries = config.CacheMaxEntries
+		next = cache
+	}
+	if len(config.AllowedHosts) > 0 {
+		next = NewHostMiddleware(config.AllowedHosts, next)
+	}
+	if config.MaxConcurrentRequests > 0 {
+		limiter, err := newConcurrencyLimitMiddleware(reg, config.MetricsNamespace(), config.MaxConcurrentRequests, next)
+		if err != nil {
+			return nil, err
+		}
+		next = limiter
+	}
+
+	recovery := NewRecoveryMiddleware(logger, next)
+	recovery.OnPanic = config.OnPanic
+	next = recovery
+
+	maintenance := NewMaintenanceMiddleware(config.MaintenanceMode, next)
+	maintenance.RetryAfter = config.MaintenanceRetryAfter
+	maintenance.FailHealth = config.MaintenanceFailHealth
+	if config.MaintenanceBody != "" {
+		maintenance.Body = config.MaintenanceBody
+	}
+	next = maintenance
+
+	if config.HSTSMaxAge > 0 || config.HSTSIncludeSubDomains || config.HSTSPreload {
+		hsts, err := NewHSTSMiddleware(config.HSTSMaxAge, config.HSTSIncludeSubDomains, config.HSTSPreload, next)
+		if err != nil {
+			return nil, err
+		}
+		if config.HSTSTrustForwardedProto {
+			hsts.TrustForwardedProto()
+		}
+		next = hsts
+	}
+
+	// CORS sits inside RED so preflights it answers are still recorded
+	if len(config.CorsAllowedOrigins) > 0 {
+		next = NewCORSMiddleware(config.CorsAllowedOrigins, next)
+	}
+
+	// With metrics disabled the RED middleware isn't built at all, saving its
+	// per-request clock reads and label lookups
+	var red *REDMiddleware
+	if !config.DisableMetrics {
+		redOpts := []metrics.REDOption{
+			metrics.WithNativeHistogram(config.NativeHistogramBucketFactor),
+			metrics.WithConstLabels(config.MetricConstLabels),
+		}
+		red, err = newREDMiddleware(reg, config.MetricsNamespace(), next, redOpts, config.MetricLabels...)
+		if err != nil {
+			return nil, err
+		}
+		red.SetLogger(logger)
+		if config.OverheadMetrics {
+			if err := red.EnableOverheadMetrics(config.MetricsNamespace()); err != nil {
+				return nil, err
+			}
+		}
+		if config.FlushStreams {
+			red.FlushStreams()
+		}
+		if config.FoldHeadMetrics {
+			red.FoldHEAD()
+		}
+		// Keep crawler and browser noise off the dashboards
+		red.Skip(assetPaths...)
+		if config.SlowRequestThreshold > 0 {
+			red.LogSlowRequests(logger, config.SlowRequestThreshold)
+		}
+		next = red
+	}
+
+	// Canonicalize paths ahead of RED so metrics are labelled with the path
+	// that was routed
+	if config.CleanPaths || config.RejectInvalidPaths || config.LowercasePaths {
+		paths := NewPathMiddleware(next)
+		paths.Clean = config.CleanPaths
+		paths.RejectInvalid = config.RejectInvalidPaths
+		paths.Lowercase = config.LowercasePaths
+		next = paths
+	}
+
+	// Outermost, so requests rejected by any other middleware are logged too
+	if config.AccessLog {
+		accessLog := NewAccessLogMiddleware(logger, next)
+		accessLog.Skip(config.AccessLogSkipPaths...)
+		next = accessLog
+	}
+
+	shutdownTime := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: config.MetricsNamespace(),
+		Subsystem: "http",
+		Name:      "shutdown_duration_seconds",
+		Help:      "How long the last shutdown of the server took",
+	})
+	if err := reg.Register(shutdownTime); err != nil {
+		return nil, fmt.Errorf("failed to register shutdown metric: %w", err)
+	}
+
+	debugMux := debug.NewMux()
+	debugMux.Handle("/admin/maintenance", maintenance.AdminHandler())
 
 	metricsMux := http.NewServeMux()
-	metricsMux.Handle("/metrics", promhttp.Handler())
+	metricsMux.Handle("/metrics", metrics.HandlerWithOpts(config.Registry, promhttp.HandlerOpts{
+		Timeout:             config.MetricsScrapeTimeout,
+		MaxRequestsInFlight: config.MetricsMaxRequestsInFlight,
+	}))
+	if options.metricsHandler != nil {
+		metricsMux.Handle("/", options.metricsHandler)
+	}
 
 	s := httpServer{
 		mainServer: http.Server{
 			Addr:           config.APIHost,
-			Handler:        handler,
+			Handler:        next,
 			ReadTimeout:    config.ReadTimeout,
 			WriteTimeout:   config.WriteTimeout,
 			IdleTimeout:    config.IdleTimeout,
 			MaxHeaderBytes: config.MaxHeaderBytes,
+			TLSConfig:      mainTLS,
 		},
 		metricsServer: http.Server{
-			Addr:    config.MetricsHost,
-			Handler: metricsMux,
+			Addr:      config.MetricsHost,
+			Handler:   metricsMux,
+			TLSConfig: metricsTLS,
+		},
+		debugServer: http.Server{
+			Addr:    config.DebugHost,
+			Handler: debugMux,
 		},
-		logger: logger,
-		ctx:    ctx,
-		config: config,
+		mux:          mainMux,
+		red:          red,
+		registerer:   reg,
+		maintenance:  maintenance,
+		draining:     &draining,
+		ready:        &ready,
+		readiness:    readiness,
+		background:   background.New(ctx, logger),
+		shutdownTime: shutdownTime,
+		logger:       logger,
+		ctx:          ctx,
+		config:       config,
+	}
+
+	if config.TrackConnections {
+		tracker, err := newConnTracker(reg, config.MetricsNamespace(), logger)
+		if err != nil {
+			return nil, err
+		}
+		s.mainServer.ConnState = tracker.connState
+		s.connTracker = tracker
 	}
 
 	return &s, nil
 }
 
+// Go runs fn in a goroutine tied to the server's lifecycle, for background
+// loops such as cache refreshes or queue consumers. fn starts when the server
+// runs, or right away if it already is, and its context is cancelled once
+// the servers have shut down. Shutdown waits for fn to return, within
+// ShutdownTimeout, and Run returns any error fn returned.
+func (s *httpServer) Go(fn func(ctx context.Context) error) {
+	s.background.Go(fn)
+}
+
+// SetReady sets whether /readyz reports the server ready, e.g. to gate
+// traffic on the app's own dependency checks or warm-up. The server starts
+// ready. Shutdown fails readiness regardless, and readiness checks added with
+// WithReadinessCheck must pass as well. It is safe to call at any time.
+func (s *httpServer) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+// AddReadinessCheck adds a dependency check, such as a database ping, to the
+// /readyz endpoint under name, like WithReadinessCheck. It is safe to call at
+// any time, e.g. once a dependency has been set up after NewServer.
+func (s *httpServer) AddReadinessCheck(name string, check ReadinessCheck) {
+	s.readiness.add(readinessCheck{name: name, check: check})
+}
+
+// SetMaintenance turns maintenance mode on or off at runtime. While on, all
+// routes except the health endpoint return 503. It can also be toggled via
+// PUT /admin/maintenance on the debug server.
+func (s *httpServer) SetMaintenance(enabled bool) {
+	s.maintenance.SetEnabled(enabled)
+}
+
+// Registerer returns the registry the server's metrics are registered with,
+// Config.Registry or the Prometheus default registry, so apps can add their
+// own collectors and have them served on /metrics alongside the server's. It
+// is safe for concurrent use.
+func (s *httpServer) Registerer() prometheus.Registerer {
+	return s.registerer
+}
+
+// RED returns the server's RED metrics, e.g. to record requests handled
+// outside the middleware chain, or nil when DisableMetrics is set. Its
+// vectors are safe for concurrent use.
+func (s *httpServer) RED() *strategy.RED {
+	if s.red == nil {
+		return nil
+	}
+
+	return s.red.red
+}
+
+// Mux returns the main server's mux, so handlers can be added or routes
+// inspected after NewServer. Handlers added to it are served behind the same
+// middleware as Routes. It panics if called after Run.
+func (s *httpServer) Mux() *http.ServeMux {
+	s.mustNotBeStarted("Mux")
+
+	return s.mux
+}
+
+// mustNotBeStarted panics if Run has been called.
+func (s *httpServer) mustNotBeStarted(method string) {
+	if s.started.Load() {
+		panic("rest: " + method + " called after Run")
+	}
+}
+
 func (s *httpServer) Run() error {
+	return s.RunUntil(context.Background(), nil)
+}
+
+// RunUntil runs the server like Run, but also shuts it down gracefully once
+// ctx is done or stop is closed, so embedding applications can trigger
+// shutdown from their own orchestration. A nil stop never fires.
+func (s *httpServer) RunUntil(ctx context.Context, stop <-chan struct{}) error {
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(shutdown)
 
-	return s.run(shutdown)
+	return s.runUntil(ctx, stop, shutdown)
 }
 
 func (s *httpServer) run(shutdown <-chan os.Signal) error {
-	// With a buffer of 2, matching the number of producers, guarantees
-	// that neither goroutine will ever block on sending
-	serverErrors := make(chan error, 2)
+	return s.runUntil(context.Background(), nil, shutdown)
+}
 
-	go func() {
-		s.logger.Info("startup", "status", "metrics server started", "host", s.config.MetricsHost)
-		serverErrors <- s.metricsServer.ListenAndServe()
-	}()
+func (s *httpServer) runUntil(ctx context.Context, stop <-chan struct{}, shutdown <-chan os.Signal) error {
+	if s.started.Swap(true) {
+		return ErrAlreadyRunning
+	}
+
+	// With a buffer of 3, matching the number of producers, guarantees
+	// that no goroutine will ever block on sending
+	serverErrors := make(chan error, 3)
+
+	s.background.Start()
+
+	// Cancelled once shutdown begins, so binds still being retried don't
+	// outlive it
+	listenCtx, stopListening := context.WithCancel(context.Background())
+	defer stopListening()
+
+	if s.config.EnableDebug {
+		go func() {
+			s.logger.Info("startup", "status", "debug server started", "host", s.config.DebugHost)
+			serverErrors <- s.listenAndServe(listenCtx, &s.debugServer)
+		}()
+	}
+
+	if !s.config.DisableMetricsServer {
+		go func() {
+			s.logger.Info("startup", "status", "metrics server started", "host", s.config.MetricsHost)
+			serverErrors <- s.listenAndServe(listenCtx, &s.metricsServer)
+		}()
+	}
 
 	go func() {
 		s.logger.Info("startup", "status", "main server started", "host", s.config.APIHost)
-		serverErrors <- s.mainServer.ListenAndServe()
+		serverErrors <- s.listenAndServe(listenCtx, &s.mainServer)
 	}()
 
 	select {
 	case <-s.ctx.Done():
-		return s.shutdownServers(s.ctx, nil)
+		stopListening()
+		ctx, cancel := s.shutdownContext()
+		defer cancel()
+
+		return s.shutdownAndSnapshot(ctx, nil)
 	case err := <-serverErrors:
-		return fmt.Errorf("server error: %w", err)
+		stopListening()
+		ctx, cancel := s.shutdownContext()
+		defer cancel()
+		return errors.Join(fmt.Errorf("server error: %w", err), s.background.Stop(ctx))
 	case sig := <-shutdown:
-		ctx, cancel := context.WithTimeout(s.ctx, s.config.ShutdownTimeout)
+		stopListening()
+		ctx, cancel := s.shutdownContext()
+		defer cancel()
+
+		return s.shutdownAndSnapshot(ctx, sig)
+	case <-ctx.Done():
+	case <-stop:
+	}
+
+	// Stopped by the caller of RunUntil
+	stopListening()
+	shutdownCtx, cancel := s.shutdownContext()
+	defer cancel()
+
+	return s.shutdownAndSnapshot(shutdownCtx, nil)
+}
+
+// shutdownContext returns the context bounding a graceful shutdown. It isn't
+// derived from the server's context, which is already cancelled when that is
+// what triggered the shutdown, so in-flight requests still get the whole
+// ShutdownTimeout to drain.
+func (s *httpServer) shutdownContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+}
+
+// shutdownAndSnapshot shuts the servers down and writes the metrics snapshot.
+func (s *httpServer) shutdownAndSnapshot(ctx context.Context, signal os.Signal) error {
+	s.draining.Store(true)
+
+	start := time.Now()
+	err := s.shutdownServers(ctx, signal)
+	// Background goroutines stop after the servers, as in-flight requests may
+	// still rely on them. ctx is already done when the servers used up the
+	// whole timeout, so they then get a deadline of their own.
+	bctx := ctx
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		bctx, cancel = context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
 		defer cancel()
+	}
+	if berr := s.background.Stop(bctx); berr != nil {
+		err = errors.Join(err, berr)
+	}
+	s.shutdownTime.Set(time.Since(start).Seconds())
 
-		return s.shutdownServers(ctx, sig)
+	if s.config.MetricsSnapshotFile != "" {
+		if serr := metrics.WriteSnapshot(s.config.Registry, s.config.MetricsSnapshotFile); serr != nil {
+			err = errors.Join(err, fmt.Errorf("metrics snapshot: %w", serr))
+		}
 	}
+
+	return err
+}
+
+func (s *httpServer) listenOptions() listener.Options {
+	return listener.Options{
+		ReusePort:    s.config.ReusePort,
+		Retries:      s.config.ListenRetries,
+		RetryBackoff: s.config.ListenRetryBackoff,
+		KeepAlive:    s.config.TCPKeepAlive,
+	}
+}
+
+// listenAndServe is like http.Server.ListenAndServe, but creates the
+// listener with the server's configured listener options. Only the main
+// server listens on ListenNetwork. Cancelling ctx stops bind retries. Servers
+// with a TLSConfig are served over HTTPS.
+func (s *httpServer) listenAndServe(ctx context.Context, srv *http.Server) error {
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+
+	network := listener.DefaultNetwork
+	if srv == &s.mainServer {
+		network = s.config.ListenNetwork
+	}
+	ln, err := listener.Listen(ctx, network, addr, s.listenOptions())
+	if err != nil {
+		return err
+	}
+
+	// Further connections wait in the accept queue until one closes
+	if s.config.MaxConnections > 0 && srv == &s.mainServer {
+		ln = netutil.LimitListener(ln, s.config.MaxConnections)
+	}
+
+	// Only the main server sits behind the load balancer
+	if s.config.EnableProxyProtocol && srv == &s.mainServer {
+		ln = proxyproto.NewListener(ln)
+	}
+
+	if srv.TLSConfig != nil {
+		return srv.ServeTLS(ln, "", "")
+	}
+	return srv.Serve(ln)
 }
 
 func (s *httpServer) shutdownServers(ctx context.Context, signal os.Signal) error {
-	servers := []struct {
-		name   string
-		server *http.Server
-	}{
-		{"main", &s.mainServer},
-		{"metrics", &s.metricsServer},
+	main := []namedServer{{"main", &s.mainServer}}
+	if s.config.EnableDebug {
+		main = append(main, namedServer{"debug", &s.debugServer})
+	}
+	var metricsServers []namedServer
+	if !s.config.DisableMetricsServer {
+		metricsServers = []namedServer{{"metrics", &s.metricsServer}}
+	}
+
+	// Each phase is shut down after the previous one has finished
+	var phases [][]namedServer
+	switch s.config.ShutdownOrder {
+	case ShutdownMetricsFirst:
+		phases = [][]namedServer{metricsServers, main}
+	case ShutdownConcurrent:
+		phases = [][]namedServer{append(main, metricsServers...)}
+	default:
+		phases = [][]namedServer{main, metricsServers}
 	}
 
 	// We can assume that if the signal is nil, it is context cancelled
@@ -120,13 +684,42 @@ func (s *httpServer) shutdownServers(ctx context.Context, signal os.Signal) erro
 		sig = signal.String()
 	}
 
-	for _, srv := range servers {
-		s.logger.Info("shutdown", "server", srv.name, "status", "shutdown started", "signal", sig)
-		defer s.logger.Info("shutdown", "server", srv.name, "status", "shutdown complete", "signal", sig)
-		if err := srv.server.Shutdown(ctx); err != nil {
-			srv.server.Close()
-			return fmt.Errorf("%s server could not stopped gracefully: %w", srv.name, err)
+	// Quiet shutdowns log each server's steps at debug level and only the
+	// overall completion at info
+	level := slog.LevelInfo
+	if s.config.QuietShutdown {
+		level = slog.LevelDebug
+	}
+
+	// The servers within a phase shut down concurrently so they all get the
+	// whole deadline, rather than one slow server using up another's budget.
+	// A failed phase doesn't stop later ones, so no server is left running.
+	var errs []error
+	var names []string
+	for _, phase := range phases {
+		var g errgroup.Group
+		for _, srv := range phase {
+			names = append(names, srv.name)
+			g.Go(func() error {
+				s.logger.Log(context.Background(), level, "shutdown", "server", srv.name, "status", "shutdown started", "signal", sig)
+				if err := srv.server.Shutdown(ctx); err != nil {
+					srv.server.Close()
+					return fmt.Errorf("%s server could not stopped gracefully: %w", srv.name, err)
+				}
+				s.logger.Log(context.Background(), level, "shutdown", "server", srv.name, "status", "shutdown complete", "signal", sig)
+				return nil
+			})
+		}
+		if err := g.Wait(); err != nil {
+			errs = append(errs, err)
 		}
 	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if s.config.QuietShutdown {
+		s.logger.Info("shutdown", "servers", names, "status", "shutdown complete", "signal", sig)
+	}
 	return nil
 }
@@ -1,16 +1,29 @@
 package rest
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rabellamy/server/internal/testcert"
+	"github.com/rabellamy/server/servertest"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,14 +31,39 @@ func TestCreateRoutes(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		routes Routes
-		path   string
-		want   int
+		routes    Routes
+		method    string
+		path      string
+		want      int
+		wantBody  string
+		wantAllow string
 	}{
 		"health check exists by default": {
-			routes: Routes{},
-			path:   "/health",
-			want:   http.StatusOK,
+			routes:   Routes{},
+			path:     "/health",
+			want:     http.StatusOK,
+			wantBody: `{"status":"ok"}` + "\n",
+		},
+		"liveness check exists by default": {
+			routes:   Routes{},
+			path:     "/livez",
+			want:     http.StatusOK,
+			wantBody: `{"status":"ok"}` + "\n",
+		},
+		"readiness check exists by default": {
+			routes:   Routes{},
+			path:     "/readyz",
+			want:     http.StatusOK,
+			wantBody: `{"status":"ok"}` + "\n",
+		},
+		"custom readiness route takes precedence": {
+			routes: Routes{
+				"GET /readyz": func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusTeapot)
+				},
+			},
+			path: "/readyz",
+			want: http.StatusTeapot,
 		},
 		"custom route works": {
 			routes: Routes{
@@ -40,8 +78,37 @@ func TestCreateRoutes(t *testing.T) {
 			routes: Routes{
 				"/bar": func(w http.ResponseWriter, r *http.Request) {},
 			},
-			path: "/health",
-			want: http.StatusOK,
+			path:     "/health",
+			want:     http.StatusOK,
+			wantBody: `{"status":"ok"}` + "\n",
+		},
+		"method route": {
+			routes: Routes{
+				"GET /users":  func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("list")) },
+				"POST /users": func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) },
+			},
+			method: http.MethodPost,
+			path:   "/users",
+			want:   http.StatusCreated,
+		},
+		"unregistered method on a method route": {
+			routes: Routes{
+				"GET /users":  func(w http.ResponseWriter, r *http.Request) {},
+				"POST /users": func(w http.ResponseWriter, r *http.Request) {},
+			},
+			method:    http.MethodDelete,
+			path:      "/users",
+			want:      http.StatusMethodNotAllowed,
+			wantAllow: "GET, HEAD, POST",
+		},
+		"path route alongside a method route": {
+			routes: Routes{
+				"/users":      func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusAccepted) },
+				"POST /users": func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) },
+			},
+			method: http.MethodDelete,
+			path:   "/users",
+			want:   http.StatusAccepted,
 		},
 	}
 
@@ -51,12 +118,20 @@ func TestCreateRoutes(t *testing.T) {
 
 			mux := CreateRoutes(tt.routes)
 
-			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, tt.path, nil)
 			rec := httptest.NewRecorder()
 
 			mux.ServeHTTP(rec, req)
 
 			assert.Equal(t, tt.want, rec.Code)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, rec.Body.String())
+			}
+			assert.Equal(t, tt.wantAllow, rec.Header().Get("Allow"))
 		})
 	}
 }
@@ -84,6 +159,64 @@ func TestNewServer(t *testing.T) {
 			routes:  Routes{},
 			wantErr: true,
 		},
+		"native histograms": {
+			config: Config{
+				Namespace:                   "test_server_native_histogram",
+				NativeHistogramBucketFactor: 1.1,
+			},
+			routes: Routes{},
+		},
+		"invalid native histogram bucket factor": {
+			config: Config{
+				Namespace:                   "test_server_invalid_bucket_factor",
+				NativeHistogramBucketFactor: 0.5,
+			},
+			routes:  Routes{},
+			wantErr: true,
+		},
+		"unsupported listen network": {
+			config: Config{
+				Namespace:     "test_server_listen_network",
+				ListenNetwork: "udp",
+			},
+			routes:  Routes{},
+			wantErr: true,
+		},
+		"hsts": {
+			config: Config{
+				Namespace:             "test_server_hsts",
+				HSTSMaxAge:            HSTSPreloadMinMaxAge,
+				HSTSIncludeSubDomains: true,
+				HSTSPreload:           true,
+			},
+			routes: Routes{},
+		},
+		"hsts preload without max-age": {
+			config: Config{
+				Namespace:   "test_server_hsts_invalid",
+				HSTSPreload: true,
+			},
+			routes:  Routes{},
+			wantErr: true,
+		},
+		"api and metrics hosts collide": {
+			config: Config{
+				Namespace:   "test_server_host_collision",
+				APIHost:     "0.0.0.0:3000",
+				MetricsHost: "0.0.0.0:3000",
+			},
+			routes:  Routes{},
+			wantErr: true,
+		},
+		"metrics host unused when disabled": {
+			config: Config{
+				Namespace:            "test_server_host_collision_disabled",
+				APIHost:              "0.0.0.0:3000",
+				MetricsHost:          "0.0.0.0:3000",
+				DisableMetricsServer: true,
+			},
+			routes: Routes{},
+		},
 	}
 
 	for name, tt := range tests {
@@ -104,6 +237,105 @@ func TestNewServer(t *testing.T) {
 	}
 }
 
+func TestNewServerNoRoutes(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config   Config
+		routes   Routes
+		wantWarn bool
+		wantErr  bool
+	}{
+		"routes registered": {
+			config: Config{Namespace: "test_no_routes_registered"},
+			routes: Routes{
+				"/foo": func(w http.ResponseWriter, r *http.Request) {},
+			},
+		},
+		"no routes warns": {
+			config:   Config{Namespace: "test_no_routes_warn"},
+			routes:   Routes{},
+			wantWarn: true,
+		},
+		"no routes with RequireRoutes fails": {
+			config:  Config{Namespace: "test_no_routes_required", RequireRoutes: true},
+			routes:  nil,
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+			got, err := NewServer(context.Background(), tt.config, tt.routes, logger)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Nil(t, got)
+				return
+			}
+			assert.NoError(t, err)
+
+			if tt.wantWarn {
+				assert.Contains(t, logs.String(), "level=WARN")
+				assert.Contains(t, logs.String(), "no routes registered")
+			} else {
+				assert.NotContains(t, logs.String(), "level=WARN")
+			}
+		})
+	}
+}
+
+func TestNewServerSubsystems(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		subsystems []string
+		wantErr    bool
+	}{
+		"distinct subsystems": {
+			subsystems: []string{"public", "admin"},
+			wantErr:    false,
+		},
+		"same subsystem collides": {
+			subsystems: []string{"public", "public"},
+			wantErr:    true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Sanitize namespace for Prometheus
+			ns := "test_subsystems_" + strings.ReplaceAll(name, " ", "_")
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			var err error
+			for _, subsystem := range tt.subsystems {
+				config := Config{
+					Namespace:        ns,
+					Subsystem:        subsystem,
+					OverheadMetrics:  true,
+					TrackConnections: true,
+				}
+				if _, err = NewServer(context.Background(), config, Routes{}, logger); err != nil {
+					break
+				}
+			}
+
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestRun(t *testing.T) {
 	t.Parallel()
 
@@ -156,6 +388,29 @@ func TestRun(t *testing.T) {
 			wantErr:   false,
 			preCancel: true,
 		},
+		"debug server enabled": {
+			config: Config{
+				Namespace:       "test_run_debug",
+				APIHost:         "localhost:0",
+				MetricsHost:     "localhost:0",
+				DebugHost:       "localhost:0",
+				EnableDebug:     true,
+				ShutdownTimeout: 5 * time.Second,
+			},
+			wantErr:   false,
+			cancelCtx: true,
+		},
+		"invalid debug host": {
+			config: Config{
+				Namespace:       "test_run_invalid_debug",
+				APIHost:         "localhost:0",
+				MetricsHost:     "localhost:0",
+				DebugHost:       "invalid-host:port",
+				EnableDebug:     true,
+				ShutdownTimeout: 5 * time.Second,
+			},
+			wantErr: true,
+		},
 		"invalid api host": {
 			config: Config{
 				Namespace:       "test_run_invalid_api",
@@ -337,3 +592,1401 @@ func TestShutdownServers(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricsTLS(t *testing.T) {
+	t.Parallel()
+
+	cert := testcert.New(t)
+
+	tests := map[string]struct {
+		namespace    string
+		certFile     string
+		keyFile      string
+		mainCertFile string
+		mainKeyFile  string
+		inheritTLS   bool
+		wantErr      bool
+		scheme       string
+	}{
+		"tls": {
+			namespace: "test_metrics_tls",
+			certFile:  cert.CertFile,
+			keyFile:   cert.KeyFile,
+			scheme:    "https",
+		},
+		"plaintext": {
+			namespace: "test_metrics_plaintext",
+			scheme:    "http",
+		},
+		"missing key": {
+			namespace: "test_metrics_missing_key",
+			certFile:  cert.CertFile,
+			wantErr:   true,
+		},
+		"inherits main tls": {
+			namespace:    "test_metrics_inherit_tls",
+			mainCertFile: cert.CertFile,
+			mainKeyFile:  cert.KeyFile,
+			inheritTLS:   true,
+			scheme:       "https",
+		},
+		"inherits plaintext main": {
+			namespace:  "test_metrics_inherit_plaintext",
+			inheritTLS: true,
+			scheme:     "http",
+		},
+		"main tls without inheritance": {
+			namespace:    "test_metrics_no_inherit",
+			mainCertFile: cert.CertFile,
+			mainKeyFile:  cert.KeyFile,
+			scheme:       "http",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			metricsAddr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:          tt.namespace,
+				APIHost:            "127.0.0.1:0",
+				MetricsHost:        metricsAddr,
+				ShutdownTimeout:    5 * time.Second,
+				MetricsTLSCertFile: tt.certFile,
+				MetricsTLSKeyFile:  tt.keyFile,
+				TLSCertFile:        tt.mainCertFile,
+				TLSKeyFile:         tt.mainKeyFile,
+				MetricsInheritTLS:  tt.inheritTLS,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, Routes{"/": func(w http.ResponseWriter, r *http.Request) {}}, logger)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			client := &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: cert.Pool},
+				},
+			}
+			resp, err := client.Get(tt.scheme + "://" + metricsAddr + "/metrics")
+			if assert.NoError(t, err) {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				assert.Contains(t, string(body), "go_goroutines")
+			}
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
+
+func TestRunCancelsListenRetries(t *testing.T) {
+	// Not parallel: goroutines from other tests would be reported as leaks
+	servertest.CheckGoroutines(t)
+
+	// Keep the API port taken so binding it is retried
+	held, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer held.Close()
+
+	config := Config{
+		Namespace:          "test_run_cancels_listen_retries",
+		APIHost:            held.Addr().String(),
+		MetricsHost:        "127.0.0.1:0",
+		ListenRetries:      10,
+		ListenRetryBackoff: time.Minute,
+		ShutdownTimeout:    5 * time.Second,
+		Registry:           prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give the first bind time to fail
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestRunNoGoroutineLeaks(t *testing.T) {
+	// Not parallel: goroutines from other tests would be reported as leaks
+	servertest.CheckGoroutines(t)
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_run_no_leaks",
+		APIHost:         addr,
+		DebugHost:       "127.0.0.1:0",
+		EnableDebug:     true,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, Routes{}, logger)
+	assert.NoError(t, err)
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + addr + "/health")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestListenNetwork(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "api.sock")
+
+	// Find a random free port
+	lis, err := net.Listen("tcp4", "127.0.0.1:0")
+	assert.NoError(t, err)
+	port := lis.Addr().(*net.TCPAddr).Port
+	lis.Close()
+
+	tests := map[string]struct {
+		network string
+		apiHost string
+		dial    func(ctx context.Context, network, addr string) (net.Conn, error)
+	}{
+		"tcp4": {
+			network: "tcp4",
+			apiHost: fmt.Sprintf(":%d", port),
+			dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "tcp4", fmt.Sprintf("127.0.0.1:%d", port))
+			},
+		},
+		"unix": {
+			network: "unix",
+			apiHost: socket,
+			dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace:       "test_listen_network_" + name,
+				APIHost:         tt.apiHost,
+				ListenNetwork:   tt.network,
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, Routes{}, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			client := &http.Client{Transport: &http.Transport{DialContext: tt.dial}}
+			resp, err := client.Get("http://api/health")
+			if assert.NoError(t, err) {
+				resp.Body.Close()
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+			}
+			client.CloseIdleConnections()
+
+			cancel()
+			assert.NoError(t, <-errChan)
+		})
+	}
+}
+
+func TestMux(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_mux",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// Add a handler through the accessor before Run
+	server.Mux().HandleFunc("/extra", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "extra")
+	})
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := http.Get("http://" + addr + "/extra")
+	if assert.NoError(t, err) {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "extra", string(body))
+	}
+
+	assert.PanicsWithValue(t, "rest: Mux called after Run", func() {
+		server.Mux()
+	})
+	assert.PanicsWithValue(t, "rest: ServeOpenAPI called after Run", func() {
+		server.ServeOpenAPI("openapi.yaml", "/docs")
+	})
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestNewServerHostCollision(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:   "test_host_collision_error",
+		APIHost:     "0.0.0.0:3000",
+		MetricsHost: ":3000",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewServer(context.Background(), config, Routes{}, logger)
+	assert.EqualError(t, err, `api host "0.0.0.0:3000" and metrics host ":3000" listen on the same address`)
+}
+
+func TestQuietShutdown(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		quiet     bool
+		wantLines int
+	}{
+		"every step at info": {
+			quiet:     false,
+			wantLines: 6, // started and complete for main, metrics and debug
+		},
+		"quiet": {
+			quiet:     true,
+			wantLines: 1, // overall completion only
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&buf, nil))
+			s := &httpServer{
+				logger: logger,
+				config: Config{
+					EnableDebug:   true,
+					QuietShutdown: tt.quiet,
+				},
+			}
+
+			err := s.shutdownServers(context.Background(), nil)
+			assert.NoError(t, err)
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			assert.Len(t, lines, tt.wantLines)
+			if tt.quiet {
+				assert.Contains(t, lines[0], "level=INFO")
+				assert.Contains(t, lines[0], `servers="[main debug metrics]"`)
+				assert.Contains(t, lines[0], `status="shutdown complete"`)
+			}
+		})
+	}
+}
+
+func TestMetricsSnapshot(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		registry bool
+	}{
+		"custom registry":  {registry: true},
+		"default registry": {registry: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ns := "test_metrics_snapshot_" + strings.ReplaceAll(name, " ", "_")
+			path := filepath.Join(t.TempDir(), "metrics.prom")
+			config := Config{
+				Namespace:           ns,
+				APIHost:             "127.0.0.1:0",
+				MetricsHost:         "127.0.0.1:0",
+				ShutdownTimeout:     5 * time.Second,
+				MetricsSnapshotFile: path,
+			}
+			if tt.registry {
+				config.Registry = prometheus.NewRegistry()
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			ctx, cancel := context.WithCancel(context.Background())
+
+			server, err := NewServer(ctx, config, Routes{}, logger)
+			if !assert.NoError(t, err) {
+				cancel()
+				return
+			}
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			cancel()
+			assert.NoError(t, <-errChan)
+
+			snapshot, err := os.ReadFile(path)
+			if assert.NoError(t, err) {
+				assert.Contains(t, string(snapshot), ns+"_http_shutdown_duration_seconds ")
+			}
+		})
+	}
+}
+
+func TestShutdownServersConcurrently(t *testing.T) {
+	t.Parallel()
+
+	// Each server has a request in flight that only finishes once the other
+	// server has started shutting down, so shutting them down one after the
+	// other would run out of budget
+	mainStarted := make(chan struct{})
+	metricsStarted := make(chan struct{})
+
+	blockUntil := func(ch chan struct{}) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			<-ch
+		}
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := &httpServer{
+		logger: logger,
+		config: Config{ShutdownOrder: ShutdownConcurrent},
+		mainServer: http.Server{
+			Handler: blockUntil(metricsStarted),
+		},
+		metricsServer: http.Server{
+			Handler: blockUntil(mainStarted),
+		},
+	}
+	s.mainServer.RegisterOnShutdown(func() { close(mainStarted) })
+	s.metricsServer.RegisterOnShutdown(func() { close(metricsStarted) })
+
+	for _, srv := range []*http.Server{&s.mainServer, &s.metricsServer} {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if !assert.NoError(t, err) {
+			return
+		}
+		go srv.Serve(ln)
+		go http.Get("http://" + ln.Addr().String() + "/")
+	}
+
+	// Give the requests time to reach the handlers
+	time.Sleep(50 * time.Millisecond)
+
+	budget := 2 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), budget)
+	defer cancel()
+
+	start := time.Now()
+	err := s.shutdownServers(ctx, nil)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), budget)
+}
+
+func TestMaxConnections(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_max_connections",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		MaxConnections:  1,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	// get sends a request on conn and reads the response, giving up after
+	// timeout
+	get := func(conn net.Conn, timeout time.Duration) error {
+		assert.NoError(t, conn.SetDeadline(time.Now().Add(timeout)))
+		if _, err := io.WriteString(conn, "GET /health HTTP/1.1\r\nHost: test\r\n\r\n"); err != nil {
+			return err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		return nil
+	}
+
+	first, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer first.Close()
+	assert.NoError(t, get(first, 2*time.Second))
+
+	// The first connection is kept alive, so the second isn't accepted
+	second, err := net.Dial("tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer second.Close()
+	var netErr net.Error
+	if assert.ErrorAs(t, get(second, 200*time.Millisecond), &netErr) {
+		assert.True(t, netErr.Timeout())
+	}
+
+	// Closing the first connection lets the queued one through
+	first.Close()
+	assert.NoError(t, get(second, 2*time.Second))
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestProxyProtocol(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:           "test_proxy_protocol",
+		APIHost:             addr,
+		MetricsHost:         "127.0.0.1:0",
+		ShutdownTimeout:     5 * time.Second,
+		EnableProxyProtocol: true,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	routes := Routes{
+		"/ip": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.RemoteAddr)
+		},
+	}
+	server, err := NewServer(ctx, config, routes, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", addr)
+	if assert.NoError(t, err) {
+		fmt.Fprint(conn, "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n")
+		fmt.Fprint(conn, "GET /ip HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+
+		resp, err := io.ReadAll(conn)
+		conn.Close()
+		assert.NoError(t, err)
+		assert.Contains(t, string(resp), "200 OK")
+		assert.True(t, strings.HasSuffix(string(resp), "203.0.113.7:56324"), string(resp))
+	}
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestProxyProtocolSilentConnection(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:           "test_proxy_protocol_silent",
+		APIHost:             addr,
+		MetricsHost:         "127.0.0.1:0",
+		ShutdownTimeout:     5 * time.Second,
+		EnableProxyProtocol: true,
+		TrackConnections:    true,
+		Registry:            prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	routes := Routes{
+		"/ip": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, r.RemoteAddr)
+		},
+	}
+	server, err := NewServer(ctx, config, routes, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	// A client that connects and never sends its header mustn't hold up
+	// accepting others for the header timeout
+	silent, err := net.Dial("tcp", addr)
+	if assert.NoError(t, err) {
+		defer silent.Close()
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	conn, err := net.Dial("tcp", addr)
+	if assert.NoError(t, err) {
+		conn.SetDeadline(time.Now().Add(2 * time.Second))
+		fmt.Fprint(conn, "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n")
+		fmt.Fprint(conn, "GET /ip HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+
+		resp, err := io.ReadAll(conn)
+		conn.Close()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasSuffix(string(resp), "203.0.113.7:56324"), string(resp))
+	}
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestWithMetricsHandler(t *testing.T) {
+	t.Parallel()
+
+	// Find random free ports
+	var addrs []string
+	for range 2 {
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		addrs = append(addrs, lis.Addr().String())
+		lis.Close()
+	}
+
+	config := Config{
+		Namespace:       "test_metrics_handler",
+		APIHost:         addrs[0],
+		MetricsHost:     addrs[1],
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	custom := http.NewServeMux()
+	custom.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "ok")
+	})
+
+	server, err := NewServer(ctx, config, Routes{}, logger, WithMetricsHandler(custom))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	tests := map[string]struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		"custom route": {
+			path:       "/healthz",
+			wantStatus: http.StatusOK,
+			wantBody:   "ok",
+		},
+		"metrics still served": {
+			path:       "/metrics",
+			wantStatus: http.StatusOK,
+			wantBody:   "go_goroutines",
+		},
+		"unknown route": {
+			path:       "/missing",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Get("http://" + addrs[1] + tt.path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Contains(t, string(body), tt.wantBody)
+		})
+	}
+
+	cancel()
+	assert.NoError(t, <-errChan)
+}
+
+func TestDisableMetrics(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		disable     bool
+		wantMetrics bool
+	}{
+		"enabled": {
+			disable:     false,
+			wantMetrics: true,
+		},
+		"disabled": {
+			disable:     true,
+			wantMetrics: false,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			reg := prometheus.NewRegistry()
+			config := Config{
+				Namespace:      "test_disable_metrics",
+				APIHost:        "127.0.0.1:0",
+				MetricsHost:    "127.0.0.1:0",
+				DisableMetrics: tt.disable,
+				Registry:       reg,
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			routes := Routes{"/hello": func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "hello")
+			}}
+
+			server, err := NewServer(context.Background(), config, routes, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			rec := httptest.NewRecorder()
+			server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/hello", nil))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "hello", rec.Body.String())
+
+			families, err := reg.Gather()
+			assert.NoError(t, err)
+			var red []string
+			for _, family := range families {
+				if strings.HasPrefix(family.GetName(), "test_disable_metrics_http_requests") {
+					red = append(red, family.GetName())
+				}
+			}
+			assert.Equal(t, tt.wantMetrics, len(red) > 0, "RED metrics: %v", red)
+		})
+	}
+}
+
+func TestDisableMetricsConflicts(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		config  Config
+		wantErr string
+	}{
+		"flush streams": {
+			config:  Config{FlushStreams: true},
+			wantErr: "DisableMetrics can't be combined with FlushStreams",
+		},
+		"slow request logging": {
+			config:  Config{SlowRequestThreshold: time.Second},
+			wantErr: "DisableMetrics can't be combined with SlowRequestThreshold",
+		},
+		"overhead metrics": {
+			config:  Config{OverheadMetrics: true},
+			wantErr: "DisableMetrics can't be combined with OverheadMetrics",
+		},
+		"several": {
+			config:  Config{FlushStreams: true, OverheadMetrics: true},
+			wantErr: "DisableMetrics can't be combined with FlushStreams, OverheadMetrics",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := tt.config
+			config.Namespace = "test_disable_metrics_conflicts"
+			config.DisableMetrics = true
+			config.Registry = prometheus.NewRegistry()
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, Routes{}, logger)
+			assert.EqualError(t, err, tt.wantErr)
+			assert.Nil(t, server)
+		})
+	}
+}
+
+func BenchmarkDisableMetrics(b *testing.B) {
+	for _, disable := range []bool{false, true} {
+		b.Run(fmt.Sprintf("disabled=%t", disable), func(b *testing.B) {
+			config := Config{
+				Namespace:      "bench_disable_metrics",
+				APIHost:        "127.0.0.1:0",
+				MetricsHost:    "127.0.0.1:0",
+				DisableMetrics: disable,
+				Registry:       prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			routes := Routes{"/hello": func(w http.ResponseWriter, r *http.Request) {}}
+
+			server, err := NewServer(context.Background(), config, routes, logger)
+			if err != nil {
+				b.Fatal(err)
+			}
+			handler := server.mainServer.Handler
+			req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for b.Loop() {
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		})
+	}
+}
+
+func TestZeroShutdownTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	// ShutdownTimeout is left unset
+	config := Config{
+		Namespace:   "test_zero_shutdown_timeout",
+		APIHost:     addr,
+		MetricsHost: "127.0.0.1:0",
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	routes := Routes{"/slow": func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprint(w, "done")
+	}}
+
+	server, err := NewServer(context.Background(), config, routes, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, DefaultShutdownTimeout, server.config.ShutdownTimeout)
+
+	shutdown := make(chan os.Signal, 1)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(shutdown)
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	respChan := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			respChan <- 0
+			return
+		}
+		resp.Body.Close()
+		respChan <- resp.StatusCode
+	}()
+
+	// Signal while the request is in flight; it should still complete
+	time.Sleep(50 * time.Millisecond)
+	shutdown <- syscall.SIGTERM
+
+	assert.NoError(t, <-errChan)
+	assert.Equal(t, http.StatusOK, <-respChan)
+}
+
+func TestLogAttrs(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_log_attrs",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		LogAttrs:        map[string]string{"service": "orders", "env": "prod"},
+	}
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	out := buf.String()
+	assert.Contains(t, out, "msg=startup")
+	assert.Contains(t, out, "msg=shutdown")
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		assert.Contains(t, line, "env=prod service=orders")
+	}
+}
+
+func TestMetricConstLabels(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:         "test_metric_const_labels",
+		MetricsHost:       "127.0.0.1:2112",
+		MetricConstLabels: map[string]string{"env": "prod", "region": "us-east"},
+		Registry:          prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	server, err := NewServer(context.Background(), config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	rec := httptest.NewRecorder()
+	server.mainServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	scrape := httptest.NewRecorder()
+	server.metricsServer.Handler.ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := scrape.Body.String()
+	for _, series := range []string{
+		`test_metric_const_labels_http_requests_total{env="prod",path="unmatched",region="us-east",verb="GET"} 1`,
+		`test_metric_const_labels_http_errors_total{env="prod",error="404",region="us-east"} 1`,
+		`test_metric_const_labels_http_request_duration_seconds_hist_count{env="prod",path="unmatched",region="us-east"} 1`,
+		`test_metric_const_labels_http_request_duration_seconds_sum_count{env="prod",path="unmatched",region="us-east"} 1`,
+	} {
+		assert.Contains(t, body, series)
+	}
+}
+
+func TestRegisterer(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		disableMetrics bool
+	}{
+		"metrics enabled":  {},
+		"metrics disabled": {disableMetrics: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := Config{
+				Namespace:      "test_registerer",
+				APIHost:        "127.0.0.1:0",
+				DisableMetrics: tt.disableMetrics,
+				Registry:       prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			server, err := NewServer(context.Background(), config, nil, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			if tt.disableMetrics {
+				assert.Nil(t, server.RED())
+			} else {
+				assert.NotNil(t, server.RED())
+			}
+
+			orders := prometheus.NewCounter(prometheus.CounterOpts{
+				Namespace: config.Namespace,
+				Name:      "orders_total",
+				Help:      "Number of orders placed",
+			})
+			assert.NoError(t, server.Registerer().Register(orders))
+			orders.Add(3)
+
+			scrape := httptest.NewRecorder()
+			server.metricsServer.Handler.ServeHTTP(scrape, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+			assert.Contains(t, scrape.Body.String(), "test_registerer_orders_total 3")
+		})
+	}
+}
+
+func TestGo(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		fn      func(stopped *atomic.Bool) func(ctx context.Context) error
+		wantErr string
+	}{
+		"cancelled and awaited": {
+			fn: func(stopped *atomic.Bool) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					<-ctx.Done()
+					// Shutdown must wait for cleanup after cancellation
+					time.Sleep(50 * time.Millisecond)
+					stopped.Store(true)
+					return ctx.Err()
+				}
+			},
+		},
+		"error is returned from run": {
+			fn: func(stopped *atomic.Bool) func(ctx context.Context) error {
+				return func(ctx context.Context) error {
+					stopped.Store(true)
+					return errors.New("consumer failed")
+				}
+			},
+			wantErr: "consumer failed",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       "test_go_" + strings.ReplaceAll(name, " ", "_"),
+				APIHost:         addr,
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				Registry:        prometheus.NewRegistry(),
+			}
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			server, err := NewServer(ctx, config, Routes{}, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			var stopped atomic.Bool
+			server.Go(tt.fn(&stopped))
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.run(make(chan os.Signal, 1))
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			cancel()
+			err = <-errChan
+			assert.True(t, stopped.Load())
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		stopWithContext bool
+	}{
+		"stop channel closed": {},
+		"context cancelled": {
+			stopWithContext: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// Find a random free port
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			assert.NoError(t, err)
+			addr := lis.Addr().String()
+			lis.Close()
+
+			config := Config{
+				Namespace:       "test_run_until_" + strings.ReplaceAll(name, " ", "_"),
+				APIHost:         addr,
+				MetricsHost:     "127.0.0.1:0",
+				ShutdownTimeout: 5 * time.Second,
+				Registry:        prometheus.NewRegistry(),
+			}
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+			server, err := NewServer(context.Background(), config, Routes{}, logger)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			stop := make(chan struct{})
+
+			errChan := make(chan error, 1)
+			go func() {
+				errChan <- server.RunUntil(ctx, stop)
+			}()
+
+			// Give server time to start
+			time.Sleep(50 * time.Millisecond)
+
+			// Still running until told to stop
+			select {
+			case err := <-errChan:
+				t.Fatalf("server stopped early: %v", err)
+			default:
+			}
+
+			if tt.stopWithContext {
+				cancel()
+			} else {
+				close(stop)
+			}
+
+			select {
+			case err := <-errChan:
+				assert.NoError(t, err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("server did not shut down")
+			}
+			assert.Contains(t, logs.String(), "shutdown complete")
+		})
+	}
+}
+
+func TestContextCancelDrainsRequests(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_context_cancel_drains_requests",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+		Registry:        prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	started := make(chan struct{})
+	routes := Routes{
+		"/slow": func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusOK)
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, routes, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	respErr := make(chan error, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err == nil {
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		}
+		respErr <- err
+	}()
+
+	// Cancelling the server's context mid-request still lets it finish
+	<-started
+	cancel()
+	assert.NoError(t, <-respErr)
+	assert.NoError(t, <-errChan)
+}
+
+func TestRunTwice(t *testing.T) {
+	t.Parallel()
+
+	// Find a random free port
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	config := Config{
+		Namespace:       "test_run_twice",
+		APIHost:         addr,
+		MetricsHost:     "127.0.0.1:0",
+		ShutdownTimeout: 5 * time.Second,
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := NewServer(ctx, config, Routes{}, logger)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.run(make(chan os.Signal, 1))
+	}()
+
+	// Give server time to start
+	time.Sleep(50 * time.Millisecond)
+
+	assert.ErrorIs(t, server.Run(), ErrAlreadyRunning)
+
+	cancel()
+	assert.NoError(t, <-errChan)
+
+	// A server that has stopped can't be run again either
+	assert.ErrorIs(t, server.run(make(chan os.Signal, 1)), ErrAlreadyRunning)
+}
+
+func TestShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		order ShutdownOrder
+		want  []string
+	}{
+		"metrics last": {
+			order: ShutdownMetricsLast,
+			want: []string{
+				"main shutdown started", "main shutdown complete",
+				"metrics shutdown started", "metrics shutdown complete",
+			},
+		},
+		"metrics first": {
+			order: ShutdownMetricsFirst,
+			want: []string{
+				"metrics shutdown started", "metrics shutdown complete",
+				"main shutdown started", "main shutdown complete",
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+			s := &httpServer{
+				logger: logger,
+				config: Config{ShutdownOrder: tt.order},
+			}
+
+			err := s.shutdownServers(context.Background(), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, shutdownSteps(buf.String()))
+		})
+	}
+}
+
+func TestNewServerInvalidShutdownOrder(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		Namespace:     "test_invalid_shutdown_order",
+		APIHost:       "127.0.0.1:0",
+		MetricsHost:   "127.0.0.1:0",
+		ShutdownOrder: "metrics-sometimes",
+		Registry:      prometheus.NewRegistry(),
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	_, err := NewServer(context.Background(), config, Routes{}, logger)
+	assert.EqualError(t, err, `invalid shutdown order "metrics-sometimes"`)
+}
+
+// shutdownSteps returns the server and status of each JSON shutdown log
+// line, e.g. "main shutdown started".
+func shutdownSteps(logs string) []string {
+	var steps []string
+	for _, line := range strings.Split(strings.TrimSpace(logs), "\n") {
+		var record struct {
+			Server string `json:"server"`
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil || record.Server == "" {
+			continue
+		}
+		steps = append(steps, record.Server+" "+record.Status)
+	}
+
+	return steps
+}
@@ -0,0 +1,48 @@
+package rest
+
+import (
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// SingleFlightMiddleware coalesces concurrent identical GET requests so only
+// one of them reaches the wrapped handler while the others wait and share its
+// response. Only the configured paths are coalesced, and because the response
+// is shared, those paths must be idempotent and not vary per caller.
+type SingleFlightMiddleware struct {
+	group singleflight.Group
+	paths map[string]struct{}
+	next  http.Handler
+}
+
+// NewSingleFlightMiddleware creates a new single-flight middleware for the given paths.
+func NewSingleFlightMiddleware(paths []string, next http.Handler) *SingleFlightMiddleware {
+	m := &SingleFlightMiddleware{
+		paths: make(map[string]struct{}, len(paths)),
+		next:  next,
+	}
+	for _, path := range paths {
+		m.paths[path] = struct{}{}
+	}
+
+	return m
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *SingleFlightMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, ok := m.paths[r.URL.Path]; !ok || r.Method != http.MethodGet {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	key := r.URL.Path + "?" + r.URL.RawQuery
+	v, _, _ := m.group.Do(key, func() (interface{}, error) {
+		resp := newBufferedResponse()
+		m.next.ServeHTTP(resp, r)
+		resp.recordRoute(r)
+		return resp, nil
+	})
+
+	v.(*bufferedResponse).writeTo(w, r)
+}
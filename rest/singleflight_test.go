@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSingleFlightMiddleware(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		paths     []string
+		method    string
+		path      string
+		requests  int
+		wantCalls int32
+	}{
+		"concurrent GETs are coalesced": {
+			paths:     []string{"/expensive"},
+			method:    http.MethodGet,
+			path:      "/expensive?page=1",
+			requests:  10,
+			wantCalls: 1,
+		},
+		"path not opted in": {
+			paths:     []string{"/expensive"},
+			method:    http.MethodGet,
+			path:      "/cheap",
+			requests:  10,
+			wantCalls: 10,
+		},
+		"POST is never coalesced": {
+			paths:     []string{"/expensive"},
+			method:    http.MethodPost,
+			path:      "/expensive",
+			requests:  10,
+			wantCalls: 10,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls atomic.Int32
+			release := make(chan struct{})
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				<-release
+				w.Header().Set("X-Test", "shared")
+				w.WriteHeader(http.StatusAccepted)
+				w.Write([]byte("response"))
+			})
+
+			middleware := NewSingleFlightMiddleware(tt.paths, handler)
+
+			var wg sync.WaitGroup
+			recs := make([]*httptest.ResponseRecorder, tt.requests)
+			for i := range recs {
+				recs[i] = httptest.NewRecorder()
+				wg.Add(1)
+				go func(rec *httptest.ResponseRecorder) {
+					defer wg.Done()
+					middleware.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+				}(recs[i])
+			}
+
+			// Give all requests time to reach the middleware before releasing the handler
+			time.Sleep(100 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			assert.Equal(t, tt.wantCalls, calls.Load())
+			for _, rec := range recs {
+				assert.Equal(t, http.StatusAccepted, rec.Code)
+				assert.Equal(t, "shared", rec.Header().Get("X-Test"))
+				assert.Equal(t, "response", rec.Body.String())
+			}
+		})
+	}
+}
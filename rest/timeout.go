@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TimeoutMiddleware sets a deadline on each request's context, so handlers
+// that honour it give up on work the client can no longer use. Writes often
+// legitimately take longer than reads, so the deadline can vary by method.
+type TimeoutMiddleware struct {
+	timeout time.Duration
+	methods map[string]time.Duration
+	next    http.Handler
+}
+
+// NewTimeoutMiddleware creates a new request timeout middleware. Requests get
+// the timeout for their method in methodTimeouts, falling back to
+// defaultTimeout. Method names are case-insensitive, and a zero timeout means
+// no deadline.
+func NewTimeoutMiddleware(defaultTimeout time.Duration, methodTimeouts map[string]time.Duration, next http.Handler) *TimeoutMiddleware {
+	m := &TimeoutMiddleware{
+		timeout: defaultTimeout,
+		methods: make(map[string]time.Duration, len(methodTimeouts)),
+		next:    next,
+	}
+
+	for method, timeout := range methodTimeouts {
+		m.methods[strings.ToUpper(method)] = timeout
+	}
+
+	return m
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (m *TimeoutMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	timeout, ok := m.methods[r.Method]
+	if !ok {
+		timeout = m.timeout
+	}
+	if timeout <= 0 {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	m.next.ServeHTTP(w, r.WithContext(ctx))
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	t.Parallel()
+
+	methodTimeouts := map[string]time.Duration{
+		"get":  5 * time.Second,
+		"POST": 30 * time.Second,
+	}
+
+	tests := map[string]struct {
+		defaultTimeout time.Duration
+		method         string
+		wantTimeout    time.Duration
+	}{
+		"GET gets its method timeout": {
+			defaultTimeout: time.Second,
+			method:         http.MethodGet,
+			wantTimeout:    5 * time.Second,
+		},
+		"POST gets the longer timeout": {
+			defaultTimeout: time.Second,
+			method:         http.MethodPost,
+			wantTimeout:    30 * time.Second,
+		},
+		"other methods get the default": {
+			defaultTimeout: time.Second,
+			method:         http.MethodDelete,
+			wantTimeout:    time.Second,
+		},
+		"no default means no deadline": {
+			method: http.MethodDelete,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				deadline    time.Time
+				hasDeadline bool
+			)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				deadline, hasDeadline = r.Context().Deadline()
+			})
+
+			// All requests go to the same route
+			m := NewTimeoutMiddleware(tt.defaultTimeout, methodTimeouts, next)
+			start := time.Now()
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/orders", nil))
+
+			if tt.wantTimeout == 0 {
+				assert.False(t, hasDeadline)
+				return
+			}
+			if assert.True(t, hasDeadline) {
+				assert.WithinDuration(t, start.Add(tt.wantTimeout), deadline, 100*time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestTimeoutMiddlewareExpires(t *testing.T) {
+	t.Parallel()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		http.Error(w, r.Context().Err().Error(), http.StatusServiceUnavailable)
+	})
+
+	m := NewTimeoutMiddleware(10*time.Millisecond, nil, next)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "deadline exceeded")
+}
@@ -0,0 +1,91 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// FieldError describes why one field of a request failed validation. Field
+// is empty for errors that concern the request as a whole, such as a body
+// that isn't valid JSON.
+type FieldError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects the field errors found validating a request.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Add records that field failed validation with message.
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Err returns e if any field errors were added, and nil otherwise, so a
+// Validate method can build the error up and return it in one go.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		if f.Field == "" {
+			msgs = append(msgs, f.Message)
+			continue
+		}
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Validator is implemented by request types that can check their own
+// fields. DecodeJSON calls Validate after decoding.
+type Validator interface {
+	Validate() error
+}
+
+// DecodeJSON decodes the JSON request body into v. If v implements
+// Validator, it is validated once decoded and the error from Validate is
+// returned as is.
+func DecodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode request body: %w", err)
+	}
+
+	if validator, ok := v.(Validator); ok {
+		return validator.Validate()
+	}
+
+	return nil
+}
+
+// WriteValidationError writes err as a JSON array of field errors. A
+// ValidationError is sent with 422 Unprocessable Entity; any other error,
+// such as a malformed body from DecodeJSON, is sent as a single error with
+// 400 Bad Request.
+func WriteValidationError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	fields := []FieldError{{Message: err.Error()}}
+
+	var verr *ValidationError
+	if errors.As(err, &verr) && len(verr.Fields) > 0 {
+		status = http.StatusUnprocessableEntity
+		fields = verr.Fields
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(fields)
+}
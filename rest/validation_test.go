@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type signupRequest struct {
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+func (r signupRequest) Validate() error {
+	var verr ValidationError
+	if !strings.Contains(r.Email, "@") {
+		verr.Add("email", "must be an email address")
+	}
+	if r.Age < 18 {
+		verr.Add("age", "must be at least 18")
+	}
+
+	return verr.Err()
+}
+
+func TestDecodeJSONWriteValidationError(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		body       string
+		wantStatus int
+		wantFields []FieldError
+	}{
+		"valid": {
+			body:       `{"email": "a@example.com", "age": 30}`,
+			wantStatus: http.StatusOK,
+		},
+		"one invalid field": {
+			body:       `{"email": "a@example.com", "age": 12}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []FieldError{
+				{Field: "age", Message: "must be at least 18"},
+			},
+		},
+		"all fields invalid": {
+			body:       `{"email": "nope", "age": 12}`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantFields: []FieldError{
+				{Field: "email", Message: "must be an email address"},
+				{Field: "age", Message: "must be at least 18"},
+			},
+		},
+		"malformed body": {
+			body:       `{"email": `,
+			wantStatus: http.StatusBadRequest,
+			wantFields: []FieldError{
+				{Message: "failed to decode request body: unexpected EOF"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				var req signupRequest
+				if err := DecodeJSON(r, &req); err != nil {
+					WriteValidationError(w, err)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}
+
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantFields == nil {
+				return
+			}
+
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			var fields []FieldError
+			if assert.NoError(t, json.NewDecoder(rec.Body).Decode(&fields)) {
+				assert.Equal(t, tt.wantFields, fields)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	t.Parallel()
+
+	var verr ValidationError
+	assert.NoError(t, verr.Err())
+
+	verr.Add("name", "is required")
+	verr.Add("", "body too large")
+	err := fmt.Errorf("signup: %w", verr.Err())
+
+	var target *ValidationError
+	assert.True(t, errors.As(err, &target))
+	assert.Equal(t, "signup: validation failed: name: is required; body too large", err.Error())
+}
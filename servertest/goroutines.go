@@ -0,0 +1,128 @@
+// Package servertest provides helpers for testing servers built with this
+// module.
+package servertest
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// DefaultLeakTimeout is how long CheckGoroutines waits by default for
+// goroutines started during a test to exit before reporting them as leaked.
+const DefaultLeakTimeout = 2 * time.Second
+
+// Option configures CheckGoroutines.
+type Option func(*options)
+
+type options struct {
+	leakTimeout time.Duration
+}
+
+// WithLeakTimeout replaces DefaultLeakTimeout as how long leaked goroutines
+// are given to exit.
+func WithLeakTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.leakTimeout = timeout
+	}
+}
+
+// ignoredFrames are functions whose goroutines belong to the runtime or the
+// testing package rather than the code under test.
+var ignoredFrames = []string{
+	"testing.tRunner(",
+	"testing.(*T).Run(",
+	"testing.runTests(",
+	"testing.(*M).",
+	"runtime.goexit0(",
+	"os/signal.signal_recv(",
+	"os/signal.loop(",
+}
+
+// CheckGoroutines snapshots the running goroutines and, when the test
+// finishes, fails it if goroutines started since are still running. Leaked
+// goroutines are given DefaultLeakTimeout, or the WithLeakTimeout option, to
+// exit, so servers still finishing their shutdown aren't reported.
+//
+// Call it first in the test so its check runs after every other cleanup.
+// Goroutines from tests running in parallel can't be told apart, so don't use
+// it in tests that call t.Parallel.
+func CheckGoroutines(t testing.TB, opts ...Option) {
+	t.Helper()
+
+	o := options{leakTimeout: DefaultLeakTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	before := goroutines()
+
+	t.Cleanup(func() {
+		var leaked []string
+		deadline := time.Now().Add(o.leakTimeout)
+		for {
+			leaked = leaked[:0]
+			for id, stack := range goroutines() {
+				if _, ok := before[id]; !ok {
+					leaked = append(leaked, stack)
+				}
+			}
+			if len(leaked) == 0 || time.Now().After(deadline) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if len(leaked) > 0 {
+			t.Errorf("found %d leaked goroutines:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+		}
+	})
+}
+
+// goroutines returns the stacks of all running goroutines except the calling
+// one and those in ignoredFrames, keyed by goroutine ID.
+func goroutines() map[string]string {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := bytes.Split(buf, []byte("\n\n"))
+	result := make(map[string]string, len(stacks))
+	for i, stack := range stacks {
+		// The first stack is always the calling goroutine
+		if i == 0 {
+			continue
+		}
+
+		s := string(stack)
+		if ignored(s) {
+			continue
+		}
+
+		var id string
+		if _, err := fmt.Sscanf(s, "goroutine %s", &id); err != nil {
+			continue
+		}
+		result[id] = s
+	}
+
+	return result
+}
+
+func ignored(stack string) bool {
+	for _, frame := range ignoredFrames {
+		if strings.Contains(stack, frame) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package servertest
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingTB captures the cleanups and errors of a test.
+type recordingTB struct {
+	testing.TB
+	cleanups []func()
+	errors   []string
+}
+
+func (r *recordingTB) Cleanup(f func()) {
+	r.cleanups = append(r.cleanups, f)
+}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recordingTB) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+func TestCheckGoroutines(t *testing.T) {
+	tests := map[string]struct {
+		run      func(stop <-chan struct{})
+		wantLeak bool
+	}{
+		"no goroutines": {
+			run: func(stop <-chan struct{}) {},
+		},
+		"goroutine exits": {
+			run: func(stop <-chan struct{}) {
+				done := make(chan struct{})
+				go func() {
+					close(done)
+				}()
+				<-done
+			},
+		},
+		"goroutine exits during timeout": {
+			run: func(stop <-chan struct{}) {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+				}()
+			},
+		},
+		"goroutine leaked": {
+			run: func(stop <-chan struct{}) {
+				go func() {
+					<-stop
+				}()
+			},
+			wantLeak: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			stop := make(chan struct{})
+			defer close(stop)
+
+			tb := &recordingTB{TB: t}
+			CheckGoroutines(tb, WithLeakTimeout(100*time.Millisecond))
+			tt.run(stop)
+			tb.finish()
+
+			if tt.wantLeak {
+				assert.Len(t, tb.errors, 1)
+				assert.Contains(t, tb.errors[0], "found 1 leaked goroutines")
+			} else {
+				assert.Empty(t, tb.errors)
+			}
+		})
+	}
+}